@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/proxy"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Check endpoint parameters
+	authzKeyHeader string
+	pathPrefix     string
+	denyStatus     int
+)
+
+// authzCmd represents the authz command
+var authzCmd = &cobra.Command{
+	Use:   "authz",
+	Short: "Start a rate limiting check endpoint for Envoy ext_authz and NGINX auth_request",
+	Long: `A command to start a HTTP endpoint checking the limits of the requests of a proxy, compatible with Envoy HTTP ext_authz
+and NGINX auth_request. Limits are written like the --limit flags of the proxy command. Requests are keyed by the key header,
+or by the client IP read from X-Forwarded-For or X-Real-IP when the check request comes from a trusted proxy. Allowed requests get a 200 OK response, denied requests
+a 429 Too Many Requests response, both with RateLimit-Policy, RateLimit and X-RateLimit-* headers the proxy can copy to the client.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateEngineFlags(); err != nil {
+			return err
+		}
+
+		if err := validateLimitFlags(); err != nil {
+			return err
+		}
+
+		if _, err := proxy.ParseTrustedProxies(trustedProxies); err != nil {
+			return err
+		}
+
+		if denyStatus < 400 || denyStatus > 599 {
+			return fmt.Errorf("deny status must be a 4xx or 5xx status code")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+		serveMetrics(stopCh)
+
+		checkLimits, err := newLimitsFromFlags(stopCh)
+		if err != nil {
+			return err
+		}
+
+		trusted, err := proxy.ParseTrustedProxies(trustedProxies)
+		if err != nil {
+			return err
+		}
+
+		check := proxy.NewCheck(checkLimits,
+			proxy.WithTrustedProxies(trusted),
+			proxy.WithKeyHeader(authzKeyHeader),
+			proxy.WithPathPrefix(pathPrefix),
+			proxy.WithDenyStatus(denyStatus),
+			proxy.WithQuiet(quiet),
+		)
+		server := &http.Server{
+			Addr:              listenAddr,
+			Handler:           check,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		return serve(server, stopCh)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authzCmd)
+
+	addEngineFlags(authzCmd.PersistentFlags())
+	addMetricsFlag(authzCmd.PersistentFlags())
+
+	authzCmd.PersistentFlags().StringVar(&listenAddr, "listen", ":8080", "Authz: Address to listen on")
+	authzCmd.PersistentFlags().StringArrayVar(&limits, "limit", nil, "Authz: Limit written \"<scope>=<engine>[:key=value,...]\", the scope being global, ip (a limit per key) or a path prefix. Repeat for each limit, a request must be allowed by every matching limit")
+	authzCmd.PersistentFlags().StringVar(&trustedProxies, "trusted-proxies", "", "Authz: IP addresses and CIDR ranges of the proxy and of the proxies in front of it, e.g. \"127.0.0.1\". X-Forwarded-For and X-Real-IP are only read from them, and they are skipped in X-Forwarded-For")
+	authzCmd.PersistentFlags().StringVar(&authzKeyHeader, "key-header", "", "Authz: Header carrying the key of a request, e.g. X-Api-Key. Default is the client IP")
+	authzCmd.PersistentFlags().StringVar(&pathPrefix, "path-prefix", "", "Authz: Prefix of the path of the check requests, the path_prefix of Envoy ext_authz")
+	authzCmd.PersistentFlags().IntVar(&denyStatus, "deny-status", http.StatusTooManyRequests, "Authz: Status code of the denied requests. NGINX auth_request only accepts 401 and 403")
+	authzCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "Authz: Don't log every request")
+}
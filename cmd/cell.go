@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/minhthong582000/rate-limiter/internal/resp"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// CL.THROTTLE server parameters
+	respAddr string
+)
+
+// cellCmd represents the cell command
+var cellCmd = &cobra.Command{
+	Use:   "cell",
+	Short: "Start a Redis server answering the CL.THROTTLE command of redis-cell",
+	Long: `A command to start a server speaking the Redis protocol and implementing CL.THROTTLE key max_burst count period [quantity],
+the command of the redis-cell module, on a token bucket per key. The reply is the same five integers: limited (0 or 1),
+limit, remaining, retry after and reset after in seconds. The services calling redis-cell can use it with their Redis client.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+
+		listener, err := net.Listen("tcp", respAddr)
+		if err != nil {
+			return err
+		}
+		server := resp.NewServer(resp.WithQuiet(quiet))
+
+		errCh := make(chan error, 1)
+		go func() {
+			fmt.Printf("Listening on %s\n", listener.Addr())
+			errCh <- server.Serve(listener)
+		}()
+
+		select {
+		case err := <-errCh:
+			return err
+		case <-stopCh:
+		}
+
+		if err := server.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+		fmt.Println("Server stopped")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cellCmd)
+
+	cellCmd.PersistentFlags().StringVar(&respAddr, "listen", ":6379", "Cell: Address to listen on")
+	cellCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "Cell: Don't log every CL.THROTTLE call")
+}
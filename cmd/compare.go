@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Comparison parameters
+	peakWindow int64 // in milliseconds
+
+	// Accuracy parameters
+	accuracy       bool
+	oracleCapacity int64 // default is the capacity flag
+	oracleWindow   int64 // in milliseconds, default is the window size flag
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <engine>[:key=value,...]...",
+	Short: "Compare several rate limiters on the same traffic",
+	Long: `A command to drive the same generated or replayed traffic through several engine configurations, in virtual time,
+and print a comparison table. Engines are written like the --shadow flag, e.g. "token-bucket:capacity=10,fill-duration=100",
+unset parameters are taken from the engine flags. Add "name=..." to label an engine in the table.`,
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateEngineFlags(); err != nil {
+			return err
+		}
+
+		if err := validateCompareFlags(); err != nil {
+			return err
+		}
+
+		for _, spec := range args {
+			if err := validateSpec(spec); err != nil {
+				return fmt.Errorf("invalid engine %q: %w", spec, err)
+			}
+		}
+
+		if peakWindow <= 0 {
+			return fmt.Errorf("peak window must be greater than 0")
+		}
+
+		if oracleCapacity < 0 || oracleWindow < 0 {
+			return fmt.Errorf("oracle capacity and window must be greater than 0")
+		}
+
+		if err := validateOutputFile(".json", ".csv"); err != nil {
+			return err
+		}
+
+		if traceFile != "" {
+			return validateTraceFlags()
+		}
+		return validateSimulationFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+
+		// Every engine must see the same arrivals
+		if seed == 0 {
+			seed = rand.Uint64()
+		}
+
+		comparison := simulator.NewComparison(time.Duration(peakWindow) * time.Millisecond)
+		if accuracy {
+			comparison.Oracle = newOracleFromFlags()
+		}
+		for _, spec := range args {
+			result, err := compareOne(spec, stopCh)
+			if err != nil {
+				return fmt.Errorf("engine %q: %w", spec, err)
+			}
+			comparison.Add(engine.SpecName(spec), result)
+		}
+
+		if traceFile != "" {
+			fmt.Printf("Replayed %s on %d engines\n\n", traceFile, len(args))
+		} else {
+			fmt.Printf("Simulated %s on %d engines, seed=%d\n\n", trafficDescription(), len(args), seed)
+		}
+		comparison.Print(os.Stdout)
+
+		return writeExport(comparison)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	addEngineFlags(compareCmd.PersistentFlags())
+	addSimulationFlags(compareCmd.PersistentFlags())
+	addTraceFlags(compareCmd.PersistentFlags())
+
+	compareCmd.PersistentFlags().Int64Var(&peakWindow, "peak-window", 1000, "Compare: Size of the sliding window of the peak admitted rate in milliseconds")
+	compareCmd.PersistentFlags().BoolVar(&accuracy, "accuracy", false, "Compare: Measure how far each engine is from an exact \"at most capacity requests in any window\" limit")
+	compareCmd.PersistentFlags().Int64Var(&oracleCapacity, "oracle-capacity", 0, "Compare: Capacity of the exact limit. Default is the capacity flag")
+	compareCmd.PersistentFlags().Int64Var(&oracleWindow, "oracle-window", 0, "Compare: Window of the exact limit in milliseconds. Default is the window size flag")
+	compareCmd.PersistentFlags().StringVar(&outputFile, "output", "", "Compare: Export the comparison to a file as JSON (.json) or CSV (.csv)")
+}
+
+// newOracleFromFlags returns the exact limit engines are compared to
+func newOracleFromFlags() *simulator.Oracle {
+	oracle := &simulator.Oracle{
+		Capacity: capacity,
+		Window:   time.Duration(windowSize) * time.Millisecond,
+	}
+	if oracleCapacity > 0 {
+		oracle.Capacity = oracleCapacity
+	}
+	if oracleWindow > 0 {
+		oracle.Window = time.Duration(oracleWindow) * time.Millisecond
+	}
+	return oracle
+}
+
+// compareOne runs the traffic through one engine in virtual time
+func compareOne(spec string, stopCh <-chan struct{}) (*simulator.Result, error) {
+	specOpts, err := engine.ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if traceFile != "" {
+		reader, closer, err := openTrace()
+		if err != nil {
+			return nil, err
+		}
+		defer closer.Close()
+
+		first, err := reader.Peek()
+		if err != nil {
+			return nil, fmt.Errorf("empty or invalid trace: %w", err)
+		}
+
+		ratelimiter, err := newCompareEngine(stopCh, first.Time, specOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		sim, err := simulator.NewSimulator(
+			simulator.WithRateLimiter(ratelimiter),
+			simulator.WithSpeed(speed),
+			simulator.WithVirtualTime(first.Time),
+			simulator.WithQuiet(true),
+			simulator.WithStopChannel(stopCh),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return sim.Replay(reader)
+	}
+
+	start, err := time.Parse(time.RFC3339, startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	ratelimiter, err := newCompareEngine(stopCh, start, specOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	simOpts, err := simulationOptions(stopCh)
+	if err != nil {
+		return nil, err
+	}
+	simOpts = append(simOpts,
+		simulator.WithRateLimiter(ratelimiter),
+		simulator.WithVirtualTime(start),
+		simulator.WithQuiet(true),
+	)
+
+	sim, err := simulator.NewSimulator(simOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return sim.Run(), nil
+}
+
+// validateCompareFlags rejects the dry run and shadow flags, the compared engines are created without them
+func validateCompareFlags() error {
+	if dryRun || shadowSpec != "" {
+		return fmt.Errorf("--dry-run and --shadow are not supported, compare the engines instead")
+	}
+	return nil
+}
+
+// newCompareEngine creates an engine from the flags overridden by the engine configuration
+func newCompareEngine(stopCh <-chan struct{}, start time.Time, specOpts []engine.Option) (engine.Engine, error) {
+	opts, err := baseEngineOptions(stopCh)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, specOpts...)
+	opts = append(opts, engine.WithVirtualTime(start), engine.WithQuiet(true))
+
+	return engine.EngineFactory(opts...)
+}
+
+// exporter is a result that can be exported as JSON or CSV
+type exporter interface {
+	WriteJSON(w io.Writer) error
+	WriteCSV(w io.Writer) error
+}
+
+// writeExport exports the result if an output file is set, as CSV if the file is .csv and as JSON otherwise
+func writeExport(e exporter) error {
+	if outputFile == "" {
+		return nil
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(outputFile)) == ".csv" {
+		err = e.WriteCSV(f)
+	} else {
+		err = e.WriteJSON(f)
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", outputFile, err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/fairqueue"
+	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/spf13/pflag"
+)
+
+var (
+	engineType string
+	capacity   int64
+
+	// Token bucket specific configuration
+	fillDuration float64 // in milliseconds
+	consumeRate  float64
+
+	// Leaky bucket and fair queue specific configuration
+	drainDuration int64 // in milliseconds
+
+	// Fair queue specific configuration
+	tenantWeights   string
+	tenantQueueCaps string
+	tenantQueueCap  int64
+
+	// Fixed size window and sliding window specific configuration
+	windowSize int64 // in milliseconds
+
+	// Calendar window specific configuration
+	period   string
+	timezone string
+
+	// Priority classes configuration
+	priorityShares string
+
+	// Keyed configuration
+	perKey bool
+
+	// Dry run and shadow configuration
+	dryRun     bool
+	shadowSpec string
+)
+
+// addEngineFlags registers the flags used to configure the rate-limiter engine
+func addEngineFlags(flags *pflag.FlagSet) {
+	// Shared flags
+	flags.StringVar(&engineType, "engine", "token-bucket", "Rate limiting engine (fixed-window, sliding-window-log, sliding-window-counter, token-bucket, leaky-bucket, calendar-window, fair-queue)")
+	flags.Int64Var(&capacity, "capacity", 5, "All: Maximum number of requests allowed")
+
+	// Token bucket specific flags
+	flags.Float64Var(&fillDuration, "fill-duration", 500, "Token bucket: token refill duration in milliseconds. Default is 500ms (2 tokens/second)")
+	flags.Float64Var(&consumeRate, "consume-rate", 1, "Token bucket: Token consume rate per request")
+
+	// Leaky bucket and fair queue specific flags
+	flags.Int64Var(&drainDuration, "drain-duration", 500, "Leaky bucket/Fair queue: Drain duration in milliseconds")
+
+	// Fair queue specific flags
+	flags.StringVar(&tenantWeights, "tenant-weights", "", "Fair queue: Per-tenant weights, e.g. \"a=2,b=1\". Default weight is 1")
+	flags.StringVar(&tenantQueueCaps, "tenant-queue-caps", "", "Fair queue: Per-tenant queue capacities, e.g. \"a=10,b=5\"")
+	flags.Int64Var(&tenantQueueCap, "tenant-queue-cap", 0, "Fair queue: Default queue capacity of a tenant. Default is the engine capacity")
+
+	// Fixed size window and sliding window specific flags
+	flags.Int64Var(&windowSize, "window-size", 1000, "Fixed/Sliding window: Window size in milliseconds")
+
+	// Calendar window specific flags
+	flags.StringVar(&period, "period", "day", "Calendar window: Window period aligned to the wall clock (second, minute, hour, day, month)")
+	flags.StringVar(&timezone, "timezone", "UTC", "Calendar window: IANA timezone used to align day and month windows")
+
+	// Priority classes flags
+	flags.StringVar(&priorityShares, "priority-shares", "", "All: Share of capacity each priority class can use, e.g. \"normal=0.9,sheddable=0.7\". Default is no reservation")
+
+	// Keyed flags
+	flags.BoolVar(&perKey, "per-key", false, "All: Give every key (tenant, client IP...) its own limit. Not supported by the fair queue, which already has a queue per tenant")
+
+	// Dry run and shadow flags
+	flags.BoolVar(&dryRun, "dry-run", false, "All: Always allow requests, only log and count the requests that would be denied")
+	flags.StringVar(&shadowSpec, "shadow", "", "All: Candidate engine evaluated alongside the enforcing one, e.g. \"token-bucket:capacity=10,fill-duration=100\". Unset parameters are taken from the flags")
+}
+
+// validateEngineFlags validates the flags registered by addEngineFlags
+func validateEngineFlags() error {
+	if capacity <= 0 {
+		return fmt.Errorf("capacity must be greater than 0")
+	}
+
+	if fillDuration <= 0 {
+		return fmt.Errorf("fill duration must be greater than 0")
+	}
+
+	if consumeRate <= 0 {
+		return fmt.Errorf("consume rate must be greater than 0")
+	}
+
+	if drainDuration <= 0 {
+		return fmt.Errorf("drain duration must be greater than 0")
+	}
+
+	if tenantQueueCap < 0 {
+		return fmt.Errorf("tenant queue capacity must be greater than or equal to 0")
+	}
+
+	if _, err := fairqueue.ParseTenants(tenantWeights, tenantQueueCaps, fairqueue.Tenant{Weight: 1, QueueCapacity: 1}); err != nil {
+		return err
+	}
+
+	if windowSize <= 0 {
+		return fmt.Errorf("window size must be greater than 0")
+	}
+
+	if _, err := fixedsizewindow.ParsePeriod(period); err != nil {
+		return err
+	}
+
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	if _, err := priority.ParseShares(priorityShares); err != nil {
+		return fmt.Errorf("invalid priority shares: %w", err)
+	}
+
+	if perKey && engine.StringToEngineType(engineType) == engine.FairQueue {
+		return fmt.Errorf("fair queue can't be used per key, it already has a queue per tenant")
+	}
+
+	// Check the engines as a whole, e.g. a consume rate above the capacity
+	base, err := baseEngineOptions(nil)
+	if err != nil {
+		return err
+	}
+	if err := engine.NewConfig(base...).Validate(); err != nil {
+		return err
+	}
+
+	if shadowSpec != "" {
+		if err := validateSpec(shadowSpec); err != nil {
+			return fmt.Errorf("invalid shadow engine: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateSpec checks an engine configuration as a whole, on top of the engine flags
+func validateSpec(spec string) error {
+	specOpts, err := engine.ParseSpec(spec)
+	if err != nil {
+		return err
+	}
+	base, err := baseEngineOptions(nil)
+	if err != nil {
+		return err
+	}
+	return engine.NewConfig(append(base, specOpts...)...).Validate()
+}
+
+// baseEngineOptions returns the engine configuration from the flags, without dry run and shadow
+func baseEngineOptions(stopCh <-chan struct{}) ([]engine.Option, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := priority.ParseShares(priorityShares)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultTenant := fairqueue.Tenant{Weight: 1, QueueCapacity: uint64(capacity)}
+	if tenantQueueCap > 0 {
+		defaultTenant.QueueCapacity = uint64(tenantQueueCap)
+	}
+	tenants, err := fairqueue.ParseTenants(tenantWeights, tenantQueueCaps, defaultTenant)
+	if err != nil {
+		return nil, err
+	}
+
+	return []engine.Option{
+		engine.WithEngineType(engine.StringToEngineType(engineType)),
+		engine.WithStopChannel(stopCh),
+		engine.WithCapacity(uint64(capacity)),
+
+		// Token bucket specific configuration
+		engine.WithFillRate(1.0 / fillDuration),
+		engine.WithConsumeRate(consumeRate),
+
+		// Leaky bucket and fair queue specific configuration
+		engine.WithLeakRate(time.Duration(drainDuration) * time.Millisecond),
+
+		// Fair queue specific configuration
+		engine.WithDefaultTenant(defaultTenant),
+		engine.WithTenants(tenants),
+
+		// Fixed size or sliding window specific configuration
+		engine.WithWindowSize(windowSize),
+
+		// Calendar window specific configuration
+		engine.WithPeriod(fixedsizewindow.Period(period)),
+		engine.WithLocation(location),
+
+		// Priority classes configuration
+		engine.WithPriorityShares(shares),
+
+		// Keyed configuration
+		engine.WithPerKey(perKey),
+	}, nil
+}
+
+// newEngineFromFlags creates the rate-limiter engine configured by the flags.
+// The extra options are applied to both the enforcing and the shadow engines.
+func newEngineFromFlags(stopCh <-chan struct{}, extra ...engine.Option) (engine.Engine, error) {
+	opts, err := baseEngineOptions(stopCh)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, extra...)
+
+	if shadowSpec != "" {
+		shadowOpts, err := engine.ParseSpec(shadowSpec)
+		if err != nil {
+			return nil, err
+		}
+		shadowOpts = append(append([]engine.Option{}, opts...), shadowOpts...)
+		opts = append(opts, engine.WithShadow(shadowOpts...))
+	}
+
+	opts = append(opts, engine.WithDryRun(dryRun))
+
+	return engine.EngineFactory(opts...)
+}
+
+// printDryRunStats prints the summary of a dry-run or shadow engine
+func printDryRunStats(e engine.Engine) {
+	s, ok := e.(interface{ Stats() engine.DryRunStats })
+	if !ok {
+		return
+	}
+
+	stats := s.Stats()
+	if dryRun {
+		fmt.Printf("Dry run: evaluated=%d would_deny=%d\n", stats.Evaluated, stats.WouldDeny)
+		return
+	}
+	fmt.Printf("Shadow: evaluated=%d shadow_denied=%d shadow_allowed=%d\n", stats.Evaluated, stats.ShadowDenied, stats.ShadowAllowed)
+}
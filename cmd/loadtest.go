@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/loadtest"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Load test parameters
+	method         string
+	keyHeader      string
+	requestTimeout int64 // in milliseconds
+	expectPolicy   string
+	tolerance      float64
+)
+
+// loadtestCmd represents the loadtest command
+var loadtestCmd = &cobra.Command{
+	Use:   "loadtest <url>",
+	Short: "Send real HTTP traffic to a rate limited server",
+	Long: `A command to send the traffic of the simulator to a real server instead of an engine, to verify its limits end to end.
+Every request is a HTTP request to the URL, allowed unless the server answers 429 Too Many Requests. The keys of the
+tenants are sent in the key header. With --expect, the same arrivals are sent to an engine configuration written like
+the --shadow flag, and the command fails if the admit rate of the server is not within the tolerance.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		u, err := url.Parse(args[0])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid URL %q, must be an absolute http or https URL", args[0])
+		}
+
+		if requestTimeout <= 0 {
+			return fmt.Errorf("timeout must be greater than 0")
+		}
+
+		if expectPolicy != "" {
+			if err := validateEngineFlags(); err != nil {
+				return err
+			}
+			if _, err := engine.ParseSpec(expectPolicy); err != nil {
+				return fmt.Errorf("invalid expected policy %q: %w", expectPolicy, err)
+			}
+		}
+
+		if tolerance < 0 || tolerance > 1 {
+			return fmt.Errorf("tolerance must be between 0 and 1")
+		}
+
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+
+		return validateSimulationFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+
+		client := loadtest.NewClient(args[0],
+			loadtest.WithMethod(method),
+			loadtest.WithKeyHeader(keyHeader),
+			loadtest.WithTimeout(time.Duration(requestTimeout)*time.Millisecond),
+			loadtest.WithStopChannel(stopCh),
+		)
+
+		simOpts, err := simulationOptions(stopCh)
+		if err != nil {
+			return err
+		}
+		simOpts = append(simOpts, simulator.WithRateLimiter(client), simulator.WithQuiet(quiet))
+
+		sim, err := simulator.NewSimulator(simOpts...)
+		if err != nil {
+			return err
+		}
+		result := sim.Run() // Blocking call
+		if err := printReport(result); err != nil {
+			return err
+		}
+		client.Stats().Print(os.Stdout)
+
+		if expectPolicy == "" || len(result.Requests) == 0 {
+			return nil
+		}
+
+		// The policy sees the arrivals in virtual time, from the first request
+		specOpts, err := engine.ParseSpec(expectPolicy)
+		if err != nil {
+			return err
+		}
+		policy, err := newCompareEngine(stopCh, result.Requests[0].ArriveAt, specOpts)
+		if err != nil {
+			return fmt.Errorf("expected policy: %w", err)
+		}
+
+		v := loadtest.Verify(engine.SpecName(expectPolicy), policy, result, tolerance)
+		v.Print(os.Stdout)
+		if !v.Passed {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("admit rate of the server %.1f%% is not within %.1f%% of the expected %.1f%%",
+				100*v.Observed, 100*tolerance, 100*v.Expected)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loadtestCmd)
+
+	addEngineFlags(loadtestCmd.PersistentFlags())
+	addSimulationFlags(loadtestCmd.PersistentFlags())
+	addReportFlags(loadtestCmd.PersistentFlags())
+
+	loadtestCmd.PersistentFlags().StringVar(&method, "method", "GET", "Load test: HTTP method of the requests")
+	loadtestCmd.PersistentFlags().StringVar(&keyHeader, "key-header", "X-Forwarded-For", "Load test: Header carrying the key of the tenant of a request")
+	loadtestCmd.PersistentFlags().Int64Var(&requestTimeout, "timeout", 5000, "Load test: Timeout of a request in milliseconds")
+	loadtestCmd.PersistentFlags().StringVar(&expectPolicy, "expect", "", "Load test: Engine configuration the server is expected to enforce, e.g. \"token-bucket:capacity=10,fill-duration=100\". Unset parameters are taken from the engine flags")
+	loadtestCmd.PersistentFlags().Float64Var(&tolerance, "tolerance", 0.05, "Load test: Max difference between the admit rates of the server and of the expected policy")
+}
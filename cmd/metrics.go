@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var metricsAddr string
+
+// addMetricsFlag registers the address of the metrics of the servers
+func addMetricsFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&metricsAddr, "metrics-listen", "", "Address serving the dry-run and shadow counters (would_deny, shadow_mismatch) at /debug/vars, e.g. \":9090\". Disabled if empty")
+}
+
+// serveMetrics serves the expvar counters in the background until the stop channel is closed
+func serveMetrics(stopCh <-chan struct{}) {
+	if metricsAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{
+		Addr:              metricsAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := serve(server, stopCh); err != nil {
+			fmt.Printf("Metrics server failed: %v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/proxy"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Proxy parameters
+	upstream       string
+	listenAddr     string
+	limits         []string
+	trustedProxies string
+)
+
+// proxyCmd represents the proxy command
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Start a rate limiting reverse proxy",
+	Long: `A command to start a HTTP reverse proxy applying rate limits before forwarding the requests to the upstream.
+Limits are written "<scope>=<engine>[:key=value,...]", the scope being global, ip (a limit per client IP) or a path prefix,
+e.g. "ip=token-bucket:capacity=10,fill-duration=100". Unset parameters are taken from the engine flags.
+Responses have RateLimit-Policy, RateLimit and X-RateLimit-* headers, and denied requests get a 429 Too Many Requests response with Retry-After.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateEngineFlags(); err != nil {
+			return err
+		}
+
+		u, err := url.Parse(upstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid upstream %q, must be an absolute http or https URL", upstream)
+		}
+
+		if err := validateLimitFlags(); err != nil {
+			return err
+		}
+
+		if _, err := proxy.ParseTrustedProxies(trustedProxies); err != nil {
+			return err
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+		serveMetrics(stopCh)
+
+		proxyLimits, err := newLimitsFromFlags(stopCh)
+		if err != nil {
+			return err
+		}
+
+		upstreamURL, err := url.Parse(upstream)
+		if err != nil {
+			return err
+		}
+		trusted, err := proxy.ParseTrustedProxies(trustedProxies)
+		if err != nil {
+			return err
+		}
+
+		server := &http.Server{
+			Addr:              listenAddr,
+			Handler:           proxy.NewProxy(upstreamURL, proxyLimits, proxy.WithTrustedProxies(trusted), proxy.WithQuiet(quiet)),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		return serve(server, stopCh)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+
+	addEngineFlags(proxyCmd.PersistentFlags())
+	addMetricsFlag(proxyCmd.PersistentFlags())
+
+	proxyCmd.PersistentFlags().StringVar(&upstream, "upstream", "", "Proxy: URL of the upstream the allowed requests are forwarded to")
+	proxyCmd.PersistentFlags().StringVar(&listenAddr, "listen", ":8080", "Proxy: Address to listen on")
+	proxyCmd.PersistentFlags().StringArrayVar(&limits, "limit", nil, "Proxy: Limit written \"<scope>=<engine>[:key=value,...]\", the scope being global, ip or a path prefix, e.g. \"/api/search=token-bucket:capacity=5\". Repeat for each limit, a request must be allowed by every matching limit")
+	proxyCmd.PersistentFlags().StringVar(&trustedProxies, "trusted-proxies", "", "Proxy: IP addresses and CIDR ranges of the proxies whose X-Forwarded-For header is trusted, e.g. \"10.0.0.0/8,127.0.0.1\"")
+	proxyCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "Proxy: Don't log every request")
+}
+
+// validateLimitFlags checks the --limit flags by creating their engines, then stopping them
+func validateLimitFlags() error {
+	if len(limits) == 0 {
+		return fmt.Errorf("at least one limit is required")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	_, err := newLimitsFromFlags(stopCh)
+	return err
+}
+
+// newLimitsFromFlags creates the limits of the --limit flags, on top of the engine flags
+func newLimitsFromFlags(stopCh <-chan struct{}) ([]*proxy.Limit, error) {
+	var proxyLimits []*proxy.Limit
+	for _, l := range limits {
+		route, specOpts, err := proxy.ParseLimit(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit %q: %w", l, err)
+		}
+		opts, err := baseEngineOptions(stopCh)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, engine.WithDryRun(dryRun))
+		opts = append(opts, specOpts...)
+		opts = append(opts, engine.WithQuiet(quiet))
+
+		limit, err := proxy.NewLimit(l, route, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit %q: %w", l, err)
+		}
+		proxyLimits = append(proxyLimits, limit)
+	}
+	return proxyLimits, nil
+}
+
+// serve runs the server until the stop channel is closed, then waits for the requests in flight
+func serve(server *http.Server, stopCh <-chan struct{}) error {
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Printf("Listening on %s\n", server.Addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-stopCh:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	fmt.Println("Server stopped")
+	return nil
+}
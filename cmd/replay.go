@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/trace"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// Replay parameters
+	traceFile   string
+	traceFormat string // guessed from the file extension if empty
+	speed       float64
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay a recorded traffic trace against the rate limiter",
+	Long: `A command to feed the requests recorded in a trace (CSV, JSONL or access log) to the selected rate limiting engine,
+to see what a limit would have done to real traffic before deploying it.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateEngineFlags(); err != nil {
+			return err
+		}
+
+		if err := validateReportFlags(); err != nil {
+			return err
+		}
+
+		if traceFile == "" {
+			return fmt.Errorf("trace file is required")
+		}
+
+		return validateTraceFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+
+		reader, closer, err := openTrace()
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		engineOpts := []engine.Option{engine.WithQuiet(quiet)}
+		simOpts := []simulator.Option{
+			simulator.WithSpeed(speed),
+			simulator.WithQuiet(quiet),
+			simulator.WithStopChannel(stopCh),
+		}
+		if virtualTime {
+			// The engine clock starts at the first request of the trace
+			first, err := reader.Peek()
+			if err != nil {
+				return fmt.Errorf("empty or invalid trace: %w", err)
+			}
+			engineOpts = append(engineOpts, engine.WithVirtualTime(first.Time))
+			simOpts = append(simOpts, simulator.WithVirtualTime(first.Time))
+		}
+
+		ratelimiter, err := newEngineFromFlags(stopCh, engineOpts...)
+		if err != nil {
+			return err
+		}
+		simOpts = append(simOpts, simulator.WithRateLimiter(ratelimiter))
+
+		simulator, err := simulator.NewSimulator(simOpts...)
+		if err != nil {
+			return err
+		}
+		result, err := simulator.Replay(reader) // Blocking call
+		if err != nil {
+			return fmt.Errorf("replay %s: %w", traceFile, err)
+		}
+		printDryRunStats(ratelimiter)
+
+		return printReport(result)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	addEngineFlags(replayCmd.PersistentFlags())
+	addTraceFlags(replayCmd.PersistentFlags())
+	addReportFlags(replayCmd.PersistentFlags())
+
+	replayCmd.PersistentFlags().BoolVar(&virtualTime, "virtual", false, "Replay: Replay in virtual time, with the timestamps of the trace and without sleeping")
+}
+
+// addTraceFlags registers the flags used to read a trace
+func addTraceFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&traceFile, "trace", "", "Replay: Path of the trace to replay")
+	flags.StringVar(&traceFormat, "format", "", "Replay: Format of the trace (csv, jsonl, clf). Guessed from the file extension if empty")
+	flags.Float64Var(&speed, "speed", 1, "Replay: Speed of the replay, e.g. 2 replays the trace twice as fast")
+}
+
+// validateTraceFlags validates the flags registered by addTraceFlags
+func validateTraceFlags() error {
+	if traceFormat != "" {
+		if _, err := trace.ParseFormat(traceFormat); err != nil {
+			return err
+		}
+	}
+
+	if speed <= 0 {
+		return fmt.Errorf("speed must be greater than 0")
+	}
+
+	return nil
+}
+
+// openTrace opens the trace configured by the flags, the caller must close it
+func openTrace() (*trace.Reader, io.Closer, error) {
+	f, err := os.Open(traceFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format := trace.FormatFromPath(traceFile)
+	if traceFormat != "" {
+		format = trace.Format(traceFormat)
+	}
+	reader, err := trace.NewReader(f, format)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return reader, f, nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+	"github.com/spf13/pflag"
+)
+
+var (
+	outputFile string
+	bucketSize int64 // in milliseconds
+	quiet      bool
+	chart      bool
+)
+
+// addReportFlags registers the flags used to report the results of a simulation
+func addReportFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&outputFile, "output", "", "Report: Export the results to a file, the summary as JSON (.json), every request as CSV (.csv) or charts as HTML (.html)")
+	flags.Int64Var(&bucketSize, "bucket", 1000, "Report: Size of the time buckets of the admit rate in milliseconds")
+	flags.BoolVar(&quiet, "quiet", false, "Report: Only print the summary, not every request")
+	flags.BoolVar(&chart, "chart", false, "Report: Print the requests and the engine level over time as ASCII charts")
+}
+
+// validateReportFlags validates the flags registered by addReportFlags
+func validateReportFlags() error {
+	if bucketSize <= 0 {
+		return fmt.Errorf("bucket size must be greater than 0")
+	}
+
+	return validateOutputFile(".json", ".csv", ".html")
+}
+
+// validateOutputFile checks that the output file has one of the supported extensions
+func validateOutputFile(extensions ...string) error {
+	if outputFile == "" {
+		return nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(outputFile))
+	for _, e := range extensions {
+		if ext == e {
+			return nil
+		}
+	}
+	return fmt.Errorf("output file must be %s", strings.Join(extensions, ", "))
+}
+
+// printReport prints the summary of the results, and exports them if an output file is set
+func printReport(result *simulator.Result) error {
+	summary := result.Summary(time.Duration(bucketSize) * time.Millisecond)
+	summary.Print(os.Stdout)
+	if chart {
+		summary.PrintChart(os.Stdout)
+	}
+
+	if outputFile == "" {
+		return nil
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case ".csv":
+		err = result.WriteCSV(f)
+	case ".html":
+		err = summary.WriteHTML(f)
+	default:
+		err = summary.WriteJSON(f)
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", outputFile, err)
+	}
+
+	return f.Close()
+}
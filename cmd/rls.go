@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+
+	rlspb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/rls"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+var (
+	// Rate limit service parameters
+	policyFiles []string
+	grpcAddr    string
+)
+
+// rlsCmd represents the rls command
+var rlsCmd = &cobra.Command{
+	Use:   "rls",
+	Short: "Start an Envoy rate limit service",
+	Long: `A command to start a gRPC server implementing the Envoy rate limit service (envoy.service.ratelimit.v3.RateLimitService).
+Policy files map the descriptors sent by Envoy to limits, in the layout of the configuration of the Lyft rate limit service,
+with an engine configuration like "token-bucket:capacity=10,fill-duration=100" or a Lyft rate_limit for each limit.
+Unset engine parameters are taken from the engine flags.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateEngineFlags(); err != nil {
+			return err
+		}
+
+		if len(policyFiles) == 0 {
+			return fmt.Errorf("at least one policy file is required")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+		serveMetrics(stopCh)
+
+		var policies []*rls.Policy
+		for _, path := range policyFiles {
+			p, err := rls.LoadFile(path)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, p)
+		}
+
+		base, err := baseEngineOptions(stopCh)
+		if err != nil {
+			return err
+		}
+		base = append(base, engine.WithDryRun(dryRun), engine.WithQuiet(quiet))
+
+		service, err := rls.NewServer(policies, base, rls.WithQuiet(quiet))
+		if err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return err
+		}
+		server := grpc.NewServer()
+		rlspb.RegisterRateLimitServiceServer(server, service)
+
+		errCh := make(chan error, 1)
+		go func() {
+			fmt.Printf("Listening on %s\n", listener.Addr())
+			errCh <- server.Serve(listener)
+		}()
+
+		select {
+		case err := <-errCh:
+			return err
+		case <-stopCh:
+		}
+
+		// Wait for the requests in flight
+		server.GracefulStop()
+		fmt.Println("Server stopped")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rlsCmd)
+
+	addEngineFlags(rlsCmd.PersistentFlags())
+	addMetricsFlag(rlsCmd.PersistentFlags())
+
+	rlsCmd.PersistentFlags().StringArrayVar(&policyFiles, "policy", nil, "RLS: YAML policy file of a domain. Repeat for each domain")
+	rlsCmd.PersistentFlags().StringVar(&grpcAddr, "listen", ":8081", "RLS: Address of the gRPC server")
+	rlsCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "RLS: Don't log every descriptor")
+}
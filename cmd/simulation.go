@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// Priority classes configuration
+	priorityMix string
+
+	// Simulation parameters
+	numRequests int64
+	waitTime    int64 // in milliseconds
+	jitter      int64 // in milliseconds
+	parallel    int64 // number of parallel workers
+	tenants     []string
+
+	// Open loop parameters
+	openLoopRate     float64 // in requests/s
+	openLoopDuration int64   // in milliseconds
+
+	// Service parameters
+	concurrency     int64
+	serviceTime     int64 // in milliseconds
+	serviceTimeDist string
+
+	// Virtual time parameters
+	virtualTime bool
+	seed        uint64
+	startTime   string // RFC3339
+
+	// Traffic profile parameters
+	profileType      string
+	profileRate      float64 // in requests/s
+	profilePeakRate  float64 // in requests/s
+	profilePeriod    int64   // in milliseconds
+	profileOn        int64   // in milliseconds
+	profileOff       int64   // in milliseconds
+	profileBucket    int64   // in milliseconds
+	profileHistogram string
+)
+
+// addSimulationFlags registers the flags used to generate the simulated traffic
+func addSimulationFlags(flags *pflag.FlagSet) {
+	// Priority classes flags
+	flags.StringVar(&priorityMix, "priority-mix", "", "Simulator: Proportion of requests in each priority class, e.g. \"critical=1,normal=6,sheddable=3\"")
+
+	// Simulation parameters
+	flags.Int64Var(&numRequests, "num-requests", 100, "Simulator: Number of requests to simulate")
+	flags.Int64Var(&waitTime, "wait-time", 100, "Simulator: Wait time between requests in milliseconds")
+	flags.Int64Var(&jitter, "jitter", 0, "Simulator: Random jitter in milliseconds")
+	flags.Int64Var(&parallel, "parallel", 1, "Simulator: Number of parallel workers")
+	flags.StringArrayVar(&tenants, "tenant", nil, "Simulator: Tenant sending its share of the requests with its own key and profile instead of the workers, e.g. \"noisy:share=8,profile=poisson,rate=100\". Repeat for each tenant, unset profile parameters are taken from the profile flags")
+
+	// Open loop parameters
+	flags.Float64Var(&openLoopRate, "rate", 0, "Simulator: Send requests at this rate in requests/s for --duration, even when the workers are busy, instead of --num-requests (open loop)")
+	flags.Int64Var(&openLoopDuration, "duration", 0, "Simulator: Duration of the open loop traffic in milliseconds")
+
+	// Service parameters
+	flags.Int64Var(&concurrency, "concurrency", 0, "Simulator: Process the allowed requests with at most this many at a time, to measure the queueing delay. Default is the queue of the engine, if any")
+	flags.Int64Var(&serviceTime, "service-time", 100, "Simulator: Mean time to process an allowed request in milliseconds, with --concurrency")
+	flags.StringVar(&serviceTimeDist, "service-time-dist", "constant", "Simulator: Distribution of the service time (constant, exponential)")
+
+	// Virtual time parameters
+	flags.Uint64Var(&seed, "seed", 0, "Simulator: Seed of the random generators, for reproducible runs. Random if 0")
+	flags.StringVar(&startTime, "start-time", "2025-01-01T00:00:00Z", "Simulator: Start of the virtual time simulation (RFC3339)")
+
+	// Traffic profile parameters
+	flags.StringVar(&profileType, "profile", "constant", "Simulator: Arrival process of each worker (constant, poisson, on-off, ramp, diurnal, spike, histogram)")
+	flags.Float64Var(&profileRate, "profile-rate", 10, "Simulator: Rate in requests/s. Start rate of ramp, mean rate of diurnal, base rate of spike")
+	flags.Float64Var(&profilePeakRate, "profile-peak-rate", 50, "Simulator: End rate of ramp, peak rate of diurnal and spike in requests/s")
+	flags.Int64Var(&profilePeriod, "profile-period", 10000, "Simulator: Duration of ramp, period of diurnal, time between spikes in milliseconds")
+	flags.Int64Var(&profileOn, "profile-on", 1000, "Simulator: Duration of on-off bursts and spikes in milliseconds")
+	flags.Int64Var(&profileOff, "profile-off", 4000, "Simulator: Duration of on-off silences in milliseconds")
+	flags.Int64Var(&profileBucket, "profile-bucket", 1000, "Simulator: Duration of a histogram bucket in milliseconds")
+	flags.StringVar(&profileHistogram, "profile-histogram", "", "Simulator: Number of requests in each histogram bucket, replayed in a loop, e.g. \"5,10,50,10\"")
+}
+
+// validateSimulationFlags validates the flags registered by addSimulationFlags
+func validateSimulationFlags() error {
+	if priorityMix != "" {
+		if _, err := priority.ParseMix(priorityMix); err != nil {
+			return fmt.Errorf("invalid priority mix: %w", err)
+		}
+	}
+
+	if numRequests <= 0 {
+		return fmt.Errorf("number of requests must be greater than 0")
+	}
+
+	if waitTime <= 0 {
+		return fmt.Errorf("wait time must be greater than 0")
+	}
+
+	if jitter < 0 || jitter > waitTime {
+		return fmt.Errorf("jitter must be between 0 and wait time")
+	}
+
+	if parallel <= 0 {
+		return fmt.Errorf("number of parallel workers must be greater than 0")
+	}
+
+	if _, err := newProfileFromFlags(); err != nil {
+		return fmt.Errorf("invalid %s profile: %w", profileType, err)
+	}
+
+	if _, err := time.Parse(time.RFC3339, startTime); err != nil {
+		return fmt.Errorf("invalid start time: %w", err)
+	}
+
+	t, err := newTenantsFromFlags()
+	if err != nil {
+		return err
+	}
+
+	// The simulated requests only have a key when they are sent by tenants
+	if (tenantWeights != "" || tenantQueueCaps != "") && len(t) == 0 {
+		return fmt.Errorf("tenant weights and queue capacities need keyed requests, set --tenant")
+	}
+
+	if openLoopRate < 0 || openLoopDuration < 0 {
+		return fmt.Errorf("rate and duration must not be negative")
+	}
+	if (openLoopRate > 0) != (openLoopDuration > 0) {
+		return fmt.Errorf("rate and duration must be set together")
+	}
+	if openLoopRate > 0 && len(t) > 0 {
+		return fmt.Errorf("tenants can't be combined with an open loop rate")
+	}
+	// The open loop sends requests at a constant rate instead of the arrival process of the workers
+	if openLoopRate > 0 && profile.Type(profileType) != profile.Constant {
+		return fmt.Errorf("%s profile can't be combined with an open loop rate", profileType)
+	}
+
+	if concurrency < 0 {
+		return fmt.Errorf("concurrency must not be negative")
+	}
+	if _, err := newServiceFromFlags(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// simulationOptions returns the traffic configuration from the flags, without the rate limiter
+func simulationOptions(stopCh <-chan struct{}) ([]simulator.Option, error) {
+	p, err := newProfileFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []simulator.Option{
+		simulator.WithNumWorker(parallel),
+		simulator.WithNumRequests(numRequests),
+		simulator.WithSeed(seed),
+		simulator.WithStopChannel(stopCh),
+	}
+	if priorityMix != "" {
+		mix, err := priority.ParseMix(priorityMix)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, simulator.WithPriorityMix(mix))
+	}
+
+	t, err := newTenantsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	if len(t) > 0 {
+		opts = append(opts, simulator.WithTenants(t...))
+	}
+
+	if openLoopRate > 0 {
+		opts = append(opts, simulator.WithOpenLoop(openLoopRate, time.Duration(openLoopDuration)*time.Millisecond))
+	} else {
+		opts = append(opts, simulator.WithProfile(p))
+	}
+
+	service, err := newServiceFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	if service != nil {
+		opts = append(opts, simulator.WithService(service))
+	}
+
+	return opts, nil
+}
+
+// newServiceFromFlags returns the concurrency limiter processing the allowed requests, nil if not set
+func newServiceFromFlags() (*simulator.Service, error) {
+	if concurrency == 0 {
+		return nil, nil
+	}
+	return simulator.NewService(concurrency, time.Duration(serviceTime)*time.Millisecond, simulator.ServiceDistribution(serviceTimeDist))
+}
+
+// newTenantsFromFlags parses the tenants, their profile defaults to the profile flags
+func newTenantsFromFlags() ([]simulator.Tenant, error) {
+	params, err := profileParamsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []simulator.Tenant
+	keys := map[string]bool{}
+	for _, spec := range tenants {
+		t, err := simulator.ParseTenant(spec, profile.Type(profileType), params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenant %q: %w", spec, err)
+		}
+		if keys[t.Key] {
+			return nil, fmt.Errorf("duplicate tenant %q", t.Key)
+		}
+		keys[t.Key] = true
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// newProfileFromFlags creates the arrival process configured by the flags
+func newProfileFromFlags() (profile.Profile, error) {
+	params, err := profileParamsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.New(profile.Type(profileType), params)
+}
+
+// profileParamsFromFlags returns the parameters of the profile flags
+func profileParamsFromFlags() (profile.Params, error) {
+	histogram, err := profile.ParseHistogram(profileHistogram)
+	if err != nil {
+		return profile.Params{}, err
+	}
+
+	return profile.Params{
+		Interval:  time.Duration(waitTime) * time.Millisecond,
+		Jitter:    time.Duration(jitter) * time.Millisecond,
+		Rate:      profileRate,
+		PeakRate:  profilePeakRate,
+		Period:    time.Duration(profilePeriod) * time.Millisecond,
+		On:        time.Duration(profileOn) * time.Millisecond,
+		Off:       time.Duration(profileOff) * time.Millisecond,
+		Bucket:    time.Duration(profileBucket) * time.Millisecond,
+		Histogram: histogram,
+	}, nil
+}
+
+// trafficDescription describes the amount of simulated traffic
+func trafficDescription() string {
+	if openLoopRate > 0 {
+		return fmt.Sprintf("%g requests/s for %v", openLoopRate, time.Duration(openLoopDuration)*time.Millisecond)
+	}
+	return fmt.Sprintf("%d requests", numRequests)
+}
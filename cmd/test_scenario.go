@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/minhthong582000/rate-limiter/internal/simulator/scenario"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+)
+
+// testScenarioCmd represents the test-scenario command
+var testScenarioCmd = &cobra.Command{
+	Use:   "test-scenario <file|directory>...",
+	Short: "Check rate limiting policies against YAML scenarios",
+	Long: `A command to run YAML scenarios in virtual time and check the decisions of the engine against their expectations.
+A scenario describes the engine configuration, requests sent at exact times or generated traffic, and the expected
+decisions or bounds on the admit rate, peak and fairness. Directories are searched for .yaml and .yml files.
+Exits with a non-zero status if a scenario fails, to be used as regression tests in CI.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+
+		files, err := scenarioFiles(args)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, file := range files {
+			s, err := scenario.LoadFile(file)
+			if err != nil {
+				return err
+			}
+
+			outcome, err := s.Run(stopCh)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+
+			if outcome.Passed() {
+				fmt.Printf("PASS  %s (%d requests)\n", s.Name, len(outcome.Result.Requests))
+				continue
+			}
+
+			failed++
+			fmt.Printf("FAIL  %s (%s)\n", s.Name, file)
+			for _, failure := range outcome.Failures {
+				fmt.Printf("      %s\n", failure)
+			}
+		}
+
+		fmt.Printf("\n%d passed, %d failed\n", len(files)-failed, failed)
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d of %d scenarios failed", failed, len(files))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testScenarioCmd)
+}
+
+// scenarioFiles returns the given files and the YAML files of the given directories
+func scenarioFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no scenario found")
+	}
+	return files, nil
+}
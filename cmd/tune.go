@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+	"github.com/minhthong582000/soa-404/pkg/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Sweep parameters
+	sweeps []string
+
+	// Target parameters
+	minAdmitRate float64
+	maxPeak      int64
+	targetKeys   []string
+)
+
+// tuneCmd represents the tune command
+var tuneCmd = &cobra.Command{
+	Use:   "tune",
+	Short: "Sweep engine parameters to find the best configurations for a target",
+	Long: `A command to try every combination of engine parameters given with --sweep on the same generated or replayed traffic,
+in virtual time, and print the Pareto-best configurations: the ones admitting the most requests of the normal users
+for the lowest peak, among the configurations meeting the target. Unswept parameters are taken from the engine flags.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateEngineFlags(); err != nil {
+			return err
+		}
+
+		if err := validateCompareFlags(); err != nil {
+			return err
+		}
+
+		specs, err := sweepSpecsFromFlags()
+		if err != nil {
+			return err
+		}
+		for _, spec := range specs {
+			if err := validateSpec(spec); err != nil {
+				return fmt.Errorf("invalid engine %q: %w", spec, err)
+			}
+		}
+
+		if minAdmitRate < 0 || minAdmitRate > 1 {
+			return fmt.Errorf("min admit rate must be between 0 and 1")
+		}
+
+		if maxPeak < 0 {
+			return fmt.Errorf("max peak must be greater than or equal to 0")
+		}
+
+		if peakWindow <= 0 {
+			return fmt.Errorf("peak window must be greater than 0")
+		}
+
+		if err := validateOutputFile(".json", ".csv"); err != nil {
+			return err
+		}
+
+		if traceFile != "" {
+			return validateTraceFlags()
+		}
+		return validateSimulationFlags()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stopCh := signals.SetupSignalHandler()
+
+		// Every configuration must see the same arrivals
+		if seed == 0 {
+			seed = rand.Uint64()
+		}
+
+		specs, err := sweepSpecsFromFlags()
+		if err != nil {
+			return err
+		}
+
+		target := simulator.Target{
+			Keys:         targetKeys,
+			MinAdmitRate: minAdmitRate,
+			MaxPeak:      maxPeak,
+			PeakWindow:   time.Duration(peakWindow) * time.Millisecond,
+		}
+
+		if traceFile != "" {
+			fmt.Printf("Replaying %s on %d configurations\n", traceFile, len(specs))
+		} else {
+			fmt.Printf("Simulating %s on %d configurations, seed=%d\n", trafficDescription(), len(specs), seed)
+		}
+
+		candidates := make([]simulator.Candidate, 0, len(specs))
+		for _, spec := range specs {
+			select {
+			case <-stopCh:
+				return fmt.Errorf("interrupted after %d configurations", len(candidates))
+			default:
+			}
+
+			result, err := compareOne(spec, stopCh)
+			if err != nil {
+				return fmt.Errorf("engine %q: %w", spec, err)
+			}
+			candidates = append(candidates, target.Evaluate(spec, result))
+		}
+
+		tuning := simulator.NewTuning(target, candidates)
+		tuning.Print(os.Stdout)
+
+		return writeExport(tuning)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tuneCmd)
+
+	addEngineFlags(tuneCmd.PersistentFlags())
+	addSimulationFlags(tuneCmd.PersistentFlags())
+	addTraceFlags(tuneCmd.PersistentFlags())
+
+	tuneCmd.PersistentFlags().StringArrayVar(&sweeps, "sweep", nil, "Tune: Values of an engine parameter to try, as a list \"capacity=5,10,20\" or a range \"fill-duration=50..500:50\". Repeat for each parameter (capacity, fill-duration, consume-rate, window-size, drain-duration)")
+	tuneCmd.PersistentFlags().Float64Var(&minAdmitRate, "min-admit", 0.99, "Tune: Admit rate of the normal users to reach, between 0 and 1")
+	tuneCmd.PersistentFlags().Int64Var(&maxPeak, "max-peak", 0, "Tune: Max requests allowed in any peak window. No cap if 0")
+	tuneCmd.PersistentFlags().Int64Var(&peakWindow, "peak-window", 1000, "Tune: Size of the sliding window of the peak in milliseconds")
+	tuneCmd.PersistentFlags().StringArrayVar(&targetKeys, "target-key", nil, "Tune: Key (tenant, client IP...) of the normal users. Default is all the requests")
+	tuneCmd.PersistentFlags().StringVar(&outputFile, "output", "", "Tune: Export the best configurations to a file as JSON (.json) or CSV (.csv)")
+}
+
+// sweepSpecsFromFlags returns the engine configuration of every combination of the swept values
+func sweepSpecsFromFlags() ([]string, error) {
+	if len(sweeps) == 0 {
+		return nil, fmt.Errorf("at least one --sweep is required")
+	}
+
+	params := make([]simulator.SweepParam, 0, len(sweeps))
+	names := map[string]bool{}
+	configs := 1
+	for _, s := range sweeps {
+		p, err := simulator.ParseSweepParam(s)
+		if err != nil {
+			return nil, err
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("parameter %q is swept twice", p.Name)
+		}
+		names[p.Name] = true
+		params = append(params, p)
+
+		configs *= len(p.Values)
+		if configs > simulator.MaxSweepConfigs {
+			return nil, fmt.Errorf("sweep has more than %d configurations", simulator.MaxSweepConfigs)
+		}
+	}
+
+	return simulator.SweepSpecs(string(engine.StringToEngineType(engineType)), params), nil
+}
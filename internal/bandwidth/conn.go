@@ -0,0 +1,165 @@
+package bandwidth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Conn throttles the bytes read from and written to a connection. Closing the connection stops the reads
+// and writes waiting for their bytes.
+type Conn struct {
+	net.Conn
+	reader *Reader
+	writer *Writer
+
+	cancel  context.CancelFunc
+	onClose func()
+	once    sync.Once
+}
+
+// NewConn wraps the connection with the limiters of the reads and of the writes
+func NewConn(conn net.Conn, readLimiters []*Limiter, writeLimiters []*Limiter) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Conn{
+		Conn:   conn,
+		reader: NewReader(ctx, conn, readLimiters...),
+		writer: NewWriter(ctx, conn, writeLimiters...),
+		cancel: cancel,
+	}
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	return n, closedError(err)
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	return n, closedError(err)
+}
+
+func (c *Conn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.cancel()
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return err
+}
+
+// closedError returns the error of the closed connections for the reads and writes stopped by Close
+func closedError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return net.ErrClosed
+	}
+	return err
+}
+
+// Listener limits the rate of the accepted connections and the number of concurrent connections, and throttles
+// the bytes of the connections, with a limit per connection and an aggregate limit of all the connections.
+// Accept waits for a connection to be allowed instead of refusing it, the pending connections stay in the backlog.
+type Listener struct {
+	net.Listener
+	accept *Limiter
+	slots  chan struct{}
+
+	// Limiters of every connection, and their rates
+	totalRead, totalWrite *Limiter
+	connRead, connWrite   rate
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewListener wraps the listener, every limit is disabled unless its option is set
+func NewListener(l net.Listener, opts ...Option) (*Listener, error) {
+	o := newOptions(opts...)
+	// The limiters of the connections are created on accept
+	if err := o.connRead.validate(); err != nil {
+		return nil, fmt.Errorf("connection read limit: %w", err)
+	}
+	if err := o.connWrite.validate(); err != nil {
+		return nil, fmt.Errorf("connection write limit: %w", err)
+	}
+
+	listener := &Listener{
+		Listener:  l,
+		connRead:  o.connRead,
+		connWrite: o.connWrite,
+	}
+	var err error
+	if listener.totalRead, err = o.totalRead.limiter(); err != nil {
+		return nil, fmt.Errorf("total read limit: %w", err)
+	}
+	if listener.totalWrite, err = o.totalWrite.limiter(); err != nil {
+		return nil, fmt.Errorf("total write limit: %w", err)
+	}
+	// A token bucket of connections instead of bytes
+	if listener.accept, err = o.acceptRate.limiter(); err != nil {
+		return nil, fmt.Errorf("accept rate: %w", err)
+	}
+	if o.maxConns > 0 {
+		listener.slots = make(chan struct{}, o.maxConns)
+	}
+	listener.ctx, listener.cancel = context.WithCancel(context.Background())
+	return listener, nil
+}
+
+// Accept waits for a free connection slot and for the accept rate, then accepts a connection
+func (l *Listener) Accept() (net.Conn, error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-l.ctx.Done():
+			return nil, net.ErrClosed
+		}
+	}
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if l.accept != nil {
+		if err := l.accept.WaitN(l.ctx, 1); err != nil {
+			release()
+			return nil, closedError(err)
+		}
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// The rates of the connections are validated by NewListener
+	var readLimiters, writeLimiters []*Limiter
+	if r, _ := l.connRead.limiter(); r != nil {
+		readLimiters = append(readLimiters, r)
+	}
+	if l.totalRead != nil {
+		readLimiters = append(readLimiters, l.totalRead)
+	}
+	if w, _ := l.connWrite.limiter(); w != nil {
+		writeLimiters = append(writeLimiters, w)
+	}
+	if l.totalWrite != nil {
+		writeLimiters = append(writeLimiters, l.totalWrite)
+	}
+
+	c := NewConn(conn, readLimiters, writeLimiters)
+	c.onClose = release
+	return c, nil
+}
+
+// Close stops the calls to Accept waiting for a slot or for the accept rate, and closes the listener
+func (l *Listener) Close() error {
+	l.cancel()
+	return l.Listener.Close()
+}
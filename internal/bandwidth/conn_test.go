@@ -0,0 +1,154 @@
+package bandwidth
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestListener listens on a local port, the accepted connections echo what they read
+func newTestListener(t *testing.T, opts ...Option) *Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener, err := NewListener(l, opts...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+func dial(t *testing.T, l net.Listener) net.Conn {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// echo sends the bytes and reads them back
+func echo(t *testing.T, conn net.Conn, size int) {
+	go func() { _, _ = conn.Write(make([]byte, size)) }()
+	_, err := io.ReadFull(conn, make([]byte, size))
+	assert.NoError(t, err)
+}
+
+// TestListener_ConnWriteLimit tests that every connection has its own limit
+func TestListener_ConnWriteLimit(t *testing.T) {
+	l := newTestListener(t, WithConnWriteLimit(20000, 1000))
+
+	start := time.Now()
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		conn := dial(t, l)
+		go func() {
+			echo(t, conn, 3000)
+			done <- struct{}{}
+		}()
+	}
+	<-done
+	<-done
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond, "3000 bytes should take 100ms at 20KB/s")
+	assert.Less(t, elapsed, 190*time.Millisecond, "Connections should not share their limit")
+}
+
+// TestListener_TotalReadLimit tests that the connections share the aggregate limit
+func TestListener_TotalReadLimit(t *testing.T) {
+	l := newTestListener(t, WithTotalReadLimit(20000, 1000))
+
+	start := time.Now()
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		conn := dial(t, l)
+		go func() {
+			echo(t, conn, 2000)
+			done <- struct{}{}
+		}()
+	}
+	<-done
+	<-done
+	assert.GreaterOrEqual(t, time.Since(start), 140*time.Millisecond, "4000 bytes should take 150ms at 20KB/s")
+}
+
+// TestListener_MaxConns tests that a connection is accepted once another one is closed
+func TestListener_MaxConns(t *testing.T) {
+	l := newTestListener(t, WithMaxConns(1))
+
+	first := dial(t, l)
+	echo(t, first, 10)
+
+	second := dial(t, l)
+	_ = second.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := second.Write([]byte("hello"))
+	assert.NoError(t, err)
+	_, err = second.Read(make([]byte, 5))
+	assert.Error(t, err, "The second connection should wait in the backlog")
+
+	assert.NoError(t, first.Close())
+	_ = second.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(second, make([]byte, 5))
+	assert.NoError(t, err, "The second connection should be accepted once the first one is closed")
+}
+
+// TestListener_AcceptRate tests that the accepted connections are throttled
+func TestListener_AcceptRate(t *testing.T) {
+	l := newTestListener(t, WithAcceptRate(20, 1))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		echo(t, dial(t, l), 1)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "3 connections should take 100ms at 20/s")
+}
+
+// TestListener_Close tests that Accept returns once the listener is closed, even when waiting for a slot
+func TestListener_Close(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener, err := NewListener(l, WithMaxConns(1))
+	assert.NoError(t, err)
+
+	go func() {
+		conn := dial(t, listener)
+		_, _ = conn.Write([]byte("a"))
+	}()
+	_, err = listener.Accept()
+	assert.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		errCh <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, listener.Close())
+	assert.ErrorIs(t, <-errCh, net.ErrClosed)
+}
+
+// TestNewListener_Invalid tests that invalid limits are rejected
+func TestNewListener_Invalid(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	_, err = NewListener(l, WithConnWriteLimit(1000, 0))
+	assert.Error(t, err, "Limits of the connections should be checked before accepting them")
+	_, err = NewListener(l, WithTotalReadLimit(1000, -1))
+	assert.Error(t, err)
+	_, err = NewListener(l, WithAcceptRate(10, 0))
+	assert.Error(t, err)
+}
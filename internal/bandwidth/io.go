@@ -0,0 +1,65 @@
+package bandwidth
+
+import (
+	"context"
+	"io"
+)
+
+// Reader throttles the bytes read from a reader with one or more limiters, e.g. a limit of the reader and an
+// aggregate limit shared with other readers. Reads are cut to half the smallest burst, and return once their bytes
+// are allowed.
+type Reader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*Limiter
+}
+
+// NewReader wraps the reader, reads fail with the error of the context once it is done
+func NewReader(ctx context.Context, r io.Reader, limiters ...*Limiter) *Reader {
+	return &Reader{ctx: ctx, r: r, limiters: limiters}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return r.r.Read(p)
+	}
+
+	n, err := r.r.Read(p[:chunk(r.limiters, len(p))])
+	if n > 0 {
+		// The bytes are already read, pay for them before returning them
+		if waitErr := waitAll(r.ctx, r.limiters, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Writer throttles the bytes written to a writer with one or more limiters. Writes are cut to half the smallest
+// burst, every chunk is written once its bytes are allowed.
+type Writer struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*Limiter
+}
+
+// NewWriter wraps the writer, writes fail with the error of the context once it is done
+func NewWriter(ctx context.Context, w io.Writer, limiters ...*Limiter) *Writer {
+	return &Writer{ctx: ctx, w: w, limiters: limiters}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		size := chunk(w.limiters, len(p)-written)
+		if err := waitAll(w.ctx, w.limiters, size); err != nil {
+			return written, err
+		}
+
+		n, err := w.w.Write(p[written : written+size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,74 @@
+package bandwidth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReader tests that reads are cut to the burst and throttled
+func TestReader(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 3000)
+	r := NewReader(context.Background(), bytes.NewReader(data), newLimiter(t, 20000, 1000))
+
+	buf := make([]byte, 4096)
+	n, err := r.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, n, "Reads should be cut to half the burst")
+
+	start := time.Now()
+	rest, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, rest, 2500)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "2000 bytes should take 100ms at 20KB/s")
+}
+
+// TestWriter tests that writes are throttled by every limiter, the aggregate limit being shared
+func TestWriter(t *testing.T) {
+	total := newLimiter(t, 20000, 1000)
+	var a, b bytes.Buffer
+	wa := NewWriter(context.Background(), &a, newLimiter(t, 1000000, 1000), total)
+	wb := NewWriter(context.Background(), &b, newLimiter(t, 1000000, 1000), total)
+
+	start := time.Now()
+	n, err := wa.Write(bytes.Repeat([]byte("a"), 1500))
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, n)
+	n, err = wb.Write(bytes.Repeat([]byte("b"), 1500))
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, n)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "The writers should share 20KB/s")
+	assert.Equal(t, 1500, a.Len())
+	assert.Equal(t, 1500, b.Len())
+}
+
+// TestWriter_Context tests that a write stops when the context is done
+func TestWriter_Context(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var buf bytes.Buffer
+	w := NewWriter(ctx, &buf, newLimiter(t, 1, 10))
+
+	n, err := w.Write(bytes.Repeat([]byte("a"), 30))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 10, n, "The burst should be written before waiting")
+}
+
+// TestWriter_Throughput tests that a long write is delivered at the configured rate
+func TestWriter_Throughput(t *testing.T) {
+	const rate, burst, size = 1000000, 4096, 200 * 1024
+	w := NewWriter(context.Background(), io.Discard, newLimiter(t, rate, burst))
+
+	start := time.Now()
+	n, err := io.Copy(w, bytes.NewReader(bytes.Repeat([]byte("a"), size)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, size, n)
+
+	// The burst is sent at once, the rest at the rate
+	got := float64(size-burst) / time.Since(start).Seconds()
+	assert.InDelta(t, rate, got, rate*0.1, "The writer should deliver 1MB/s within 10%%, got %.0fB/s", got)
+}
@@ -0,0 +1,86 @@
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// Limiter is a token bucket of bytes: a byte costs a token, the bucket holds burst bytes and is refilled at the rate.
+// A limiter can be shared by several readers, writers and connections to limit their aggregate throughput.
+type Limiter struct {
+	engine engine.Engine
+	config *engine.Config
+	burst  int
+}
+
+// NewLimiter returns a limiter of bytesPerSecond, allowing bursts of burst bytes
+func NewLimiter(bytesPerSecond float64, burst int) (*Limiter, error) {
+	if err := validate(bytesPerSecond, burst); err != nil {
+		return nil, err
+	}
+
+	opts := []engine.Option{
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(uint64(burst)),
+		engine.WithFillRate(bytesPerSecond / 1000),
+		engine.WithConsumeRate(1),
+		engine.WithQuiet(true),
+	}
+	e, err := engine.EngineFactory(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{engine: e, config: engine.NewConfig(opts...), burst: burst}, nil
+}
+
+// validate checks the rate and the burst of a limiter
+func validate(bytesPerSecond float64, burst int) error {
+	if bytesPerSecond <= 0 {
+		return fmt.Errorf("rate must be greater than 0")
+	}
+	if burst <= 0 {
+		return fmt.Errorf("burst must be greater than 0")
+	}
+	return nil
+}
+
+// Burst returns the max bytes of a call to WaitN
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// WaitN blocks until n bytes are allowed or the context is done. n must not be greater than the burst.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if n > l.burst {
+		return fmt.Errorf("%d bytes exceed the burst of %d bytes", n, l.burst)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	// The missing bytes never take longer than refilling the whole burst
+	_, refill := l.config.Quota(time.Now())
+	return engine.WaitN(ctx, l.engine, l.config, "", uint64(n), refill)
+}
+
+// waitAll waits for n bytes of every limiter
+func waitAll(ctx context.Context, limiters []*Limiter, n int) error {
+	for _, l := range limiters {
+		if err := l.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunk returns the max bytes read or written at once, half the smallest burst of the limiters. Waiting for half
+// the burst leaves room in the bucket for the tokens refilled while the wait oversleeps, instead of dropping them.
+func chunk(limiters []*Limiter, n int) int {
+	for _, l := range limiters {
+		n = min(n, max(l.burst/2, 1))
+	}
+	return n
+}
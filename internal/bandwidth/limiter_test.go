@@ -0,0 +1,49 @@
+package bandwidth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLimiter(t *testing.T, bytesPerSecond float64, burst int) *Limiter {
+	l, err := NewLimiter(bytesPerSecond, burst)
+	assert.NoError(t, err)
+	return l
+}
+
+// TestLimiter_WaitN tests that bytes beyond the burst wait for the rate
+func TestLimiter_WaitN(t *testing.T) {
+	l := newLimiter(t, 10000, 1000)
+	ctx := context.Background()
+
+	start := time.Now()
+	assert.NoError(t, l.WaitN(ctx, 1000), "The burst should be allowed at once")
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	assert.NoError(t, l.WaitN(ctx, 1000))
+	assert.NoError(t, l.WaitN(ctx, 500))
+	assert.GreaterOrEqual(t, time.Since(start), 140*time.Millisecond, "1500 bytes should take 150ms at 10KB/s")
+
+	assert.Error(t, l.WaitN(ctx, 1001), "Calls beyond the burst should fail")
+}
+
+// TestLimiter_Context tests that a wait stops when the context is done
+func TestLimiter_Context(t *testing.T) {
+	l := newLimiter(t, 1, 10)
+	assert.NoError(t, l.WaitN(context.Background(), 10))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.WaitN(ctx, 10), context.DeadlineExceeded)
+}
+
+// TestNewLimiter_Invalid tests that invalid limits are rejected
+func TestNewLimiter_Invalid(t *testing.T) {
+	_, err := NewLimiter(0, 10)
+	assert.Error(t, err)
+	_, err = NewLimiter(10, 0)
+	assert.Error(t, err)
+}
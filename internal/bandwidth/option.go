@@ -0,0 +1,83 @@
+package bandwidth
+
+// rate is a limit of a listener, disabled if perSecond is 0
+type rate struct {
+	perSecond float64
+	burst     int
+}
+
+// limiter returns a new limiter of the rate, nil if the rate is disabled
+func (r rate) limiter() (*Limiter, error) {
+	if r.perSecond <= 0 {
+		return nil, nil
+	}
+	return NewLimiter(r.perSecond, r.burst)
+}
+
+// validate checks the burst of the rate if it is enabled
+func (r rate) validate() error {
+	if r.perSecond <= 0 {
+		return nil
+	}
+	return validate(r.perSecond, r.burst)
+}
+
+type options struct {
+	acceptRate rate
+	maxConns   int
+
+	connRead, connWrite   rate
+	totalRead, totalWrite rate
+}
+
+type Option func(o *options)
+
+func newOptions(opts ...Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithAcceptRate limits the accepted connections to perSecond, with bursts of burst connections.
+func WithAcceptRate(perSecond float64, burst int) Option {
+	return func(o *options) {
+		o.acceptRate = rate{perSecond, burst}
+	}
+}
+
+// WithMaxConns limits the number of open connections.
+func WithMaxConns(maxConns int) Option {
+	return func(o *options) {
+		o.maxConns = maxConns
+	}
+}
+
+// WithConnReadLimit limits the bytes read from every connection, e.g. the upload of a client.
+func WithConnReadLimit(bytesPerSecond float64, burst int) Option {
+	return func(o *options) {
+		o.connRead = rate{bytesPerSecond, burst}
+	}
+}
+
+// WithConnWriteLimit limits the bytes written to every connection, e.g. the download of a client.
+func WithConnWriteLimit(bytesPerSecond float64, burst int) Option {
+	return func(o *options) {
+		o.connWrite = rate{bytesPerSecond, burst}
+	}
+}
+
+// WithTotalReadLimit limits the bytes read from all the connections together.
+func WithTotalReadLimit(bytesPerSecond float64, burst int) Option {
+	return func(o *options) {
+		o.totalRead = rate{bytesPerSecond, burst}
+	}
+}
+
+// WithTotalWriteLimit limits the bytes written to all the connections together.
+func WithTotalWriteLimit(bytesPerSecond float64, burst int) Option {
+	return func(o *options) {
+		o.totalWrite = rate{bytesPerSecond, burst}
+	}
+}
@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+)
+
+var (
+	// wouldDeny counts the requests a dry-run limiter would have denied, by limiter name
+	wouldDeny = expvar.NewMap("would_deny")
+	// shadowMismatch counts the requests the shadow limiter decided differently, by limiter name and shadow decision
+	shadowMismatch = expvar.NewMap("shadow_mismatch")
+)
+
+// DryRunStats is a summary of the decisions evaluated by a dry-run or shadow limiter.
+type DryRunStats struct {
+	Evaluated uint64 // Requests evaluated
+	WouldDeny uint64 // Requests the dry-run limiter would have denied
+
+	// Shadow limiter only
+	ShadowDenied  uint64 // Requests allowed by the enforcing limiter but denied by the shadow one
+	ShadowAllowed uint64 // Requests denied by the enforcing limiter but allowed by the shadow one
+}
+
+// dryRun evaluates an engine but always allows the request.
+type dryRun struct {
+	name      string
+	engine    Engine
+	quiet     bool // Don't log the requests that would be denied
+	evaluated atomic.Uint64
+	wouldDeny atomic.Uint64
+}
+
+// NewDryRun wraps an engine so that it always allows requests,
+// logging and counting the requests it would have denied.
+func NewDryRun(name string, engine Engine) *dryRun {
+	return &dryRun{
+		name:   name,
+		engine: engine,
+	}
+}
+
+func (d *dryRun) record(arriveAt time.Time, allowed bool) bool {
+	d.evaluated.Add(1)
+	if !allowed {
+		d.wouldDeny.Add(1)
+		wouldDeny.Add(d.name, 1)
+		if !d.quiet {
+			fmt.Printf("Dry run: limiter %q would DENY request, ts=\"%v\"\n", d.name, arriveAt)
+		}
+	}
+	return true
+}
+
+func (d *dryRun) AllowAt(arriveAt time.Time) bool {
+	return d.record(arriveAt, d.engine.AllowAt(arriveAt))
+}
+
+func (d *dryRun) Allow() bool {
+	return d.AllowAt(time.Now())
+}
+
+func (d *dryRun) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return d.record(arriveAt, AllowAtPriority(d.engine, arriveAt, class))
+}
+
+func (d *dryRun) AllowAtKey(arriveAt time.Time, key string) bool {
+	return d.record(arriveAt, AllowAtKey(d.engine, arriveAt, key))
+}
+
+func (d *dryRun) AllowNAt(arriveAt time.Time, n uint64) bool {
+	return d.record(arriveAt, AllowNAt(d.engine, arriveAt, n))
+}
+
+func (d *dryRun) AllowNAtKey(arriveAt time.Time, key string, n uint64) bool {
+	return d.record(arriveAt, AllowNAtKey(d.engine, arriveAt, key, n))
+}
+
+func (d *dryRun) LevelName() string {
+	if l, ok := d.engine.(LevelEngine); ok {
+		return l.LevelName()
+	}
+	return ""
+}
+
+func (d *dryRun) Level(at time.Time) (float64, float64) {
+	if l, ok := d.engine.(LevelEngine); ok {
+		return l.Level(at)
+	}
+	return 0, 0
+}
+
+// EngineAtKey returns the engine of the key of the evaluated engine
+func (d *dryRun) EngineAtKey(key string) Engine {
+	return EngineAtKey(d.engine, key)
+}
+
+func (d *dryRun) Stats() DryRunStats {
+	return DryRunStats{
+		Evaluated: d.evaluated.Load(),
+		WouldDeny: d.wouldDeny.Load(),
+	}
+}
+
+// shadow enforces the decisions of one engine and evaluates a candidate engine alongside it.
+type shadow struct {
+	name          string
+	enforcing     Engine
+	candidate     Engine
+	quiet         bool // Don't log the mismatches
+	evaluated     atomic.Uint64
+	wouldDeny     atomic.Uint64
+	shadowDenied  atomic.Uint64
+	shadowAllowed atomic.Uint64
+}
+
+// NewShadow returns an engine that enforces the decisions of the enforcing engine,
+// and logs and counts the requests on which the candidate engine disagrees.
+func NewShadow(name string, enforcing Engine, candidate Engine) *shadow {
+	return &shadow{
+		name:      name,
+		enforcing: enforcing,
+		candidate: candidate,
+	}
+}
+
+func (s *shadow) record(arriveAt time.Time, allowed bool, candidateAllowed bool) bool {
+	s.evaluated.Add(1)
+	if !candidateAllowed {
+		s.wouldDeny.Add(1)
+	}
+
+	switch {
+	case allowed && !candidateAllowed:
+		s.shadowDenied.Add(1)
+		shadowMismatch.Add(s.name+"/denied", 1)
+		if !s.quiet {
+			fmt.Printf("Shadow: limiter %q would DENY allowed request, ts=\"%v\"\n", s.name, arriveAt)
+		}
+	case !allowed && candidateAllowed:
+		s.shadowAllowed.Add(1)
+		shadowMismatch.Add(s.name+"/allowed", 1)
+		if !s.quiet {
+			fmt.Printf("Shadow: limiter %q would ALLOW denied request, ts=\"%v\"\n", s.name, arriveAt)
+		}
+	}
+
+	return allowed
+}
+
+func (s *shadow) AllowAt(arriveAt time.Time) bool {
+	return s.record(arriveAt, s.enforcing.AllowAt(arriveAt), s.candidate.AllowAt(arriveAt))
+}
+
+func (s *shadow) Allow() bool {
+	return s.AllowAt(time.Now())
+}
+
+func (s *shadow) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return s.record(
+		arriveAt,
+		AllowAtPriority(s.enforcing, arriveAt, class),
+		AllowAtPriority(s.candidate, arriveAt, class),
+	)
+}
+
+func (s *shadow) AllowAtKey(arriveAt time.Time, key string) bool {
+	return s.record(
+		arriveAt,
+		AllowAtKey(s.enforcing, arriveAt, key),
+		AllowAtKey(s.candidate, arriveAt, key),
+	)
+}
+
+func (s *shadow) AllowNAt(arriveAt time.Time, n uint64) bool {
+	return s.record(
+		arriveAt,
+		AllowNAt(s.enforcing, arriveAt, n),
+		AllowNAt(s.candidate, arriveAt, n),
+	)
+}
+
+func (s *shadow) AllowNAtKey(arriveAt time.Time, key string, n uint64) bool {
+	return s.record(
+		arriveAt,
+		AllowNAtKey(s.enforcing, arriveAt, key, n),
+		AllowNAtKey(s.candidate, arriveAt, key, n),
+	)
+}
+
+func (s *shadow) LevelName() string {
+	if l, ok := s.enforcing.(LevelEngine); ok {
+		return l.LevelName()
+	}
+	return ""
+}
+
+// Level returns the level of the enforcing engine
+func (s *shadow) Level(at time.Time) (float64, float64) {
+	if l, ok := s.enforcing.(LevelEngine); ok {
+		return l.Level(at)
+	}
+	return 0, 0
+}
+
+// EngineAtKey returns the engine of the key of the enforcing engine
+func (s *shadow) EngineAtKey(key string) Engine {
+	return EngineAtKey(s.enforcing, key)
+}
+
+// OnProcessed forwards to the enforcing engine, whose queue holds the allowed requests
+func (s *shadow) OnProcessed(fn func(arriveAt, processedAt time.Time)) bool {
+	if q, ok := s.enforcing.(QueueEngine); ok {
+		return q.OnProcessed(fn)
+	}
+	return false
+}
+
+func (s *shadow) Stats() DryRunStats {
+	return DryRunStats{
+		Evaluated:     s.evaluated.Load(),
+		WouldDeny:     s.wouldDeny.Load(),
+		ShadowDenied:  s.shadowDenied.Load(),
+		ShadowAllowed: s.shadowAllowed.Load(),
+	}
+}
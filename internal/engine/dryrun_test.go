@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/mocks"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+)
+
+// TestDryRun_AlwaysAllows tests that the dry-run wrapper always allows requests but counts would-be denials.
+func TestDryRun_AlwaysAllows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEngine := mocks.NewMockEngine(ctrl)
+	gomock.InOrder(
+		mockEngine.EXPECT().AllowAt(gomock.Any()).Return(true),
+		mockEngine.EXPECT().AllowAt(gomock.Any()).Return(false),
+		mockEngine.EXPECT().AllowAt(gomock.Any()).Return(false),
+	)
+
+	dryRun := NewDryRun("test-dry-run", mockEngine)
+	for i := 0; i < 3; i++ {
+		assert.True(t, dryRun.AllowAt(time.Now()), "Dry run should always allow requests")
+	}
+
+	assert.Equal(t, DryRunStats{Evaluated: 3, WouldDeny: 2}, dryRun.Stats())
+	assert.Equal(t, "2", wouldDeny.Get("test-dry-run").String(), "would_deny metric should be emitted")
+}
+
+// TestDryRun_Priority tests that priorities are forwarded to the wrapped engine.
+func TestDryRun_Priority(t *testing.T) {
+	limiter, err := EngineFactory(
+		WithEngineType(FixedWindow),
+		WithCapacity(2),
+		WithWindowSize(1000),
+		WithPriorityShares(priority.Shares{1, 1, 0.5}),
+		WithDryRun(true),
+	)
+	assert.NoError(t, err)
+
+	p, ok := limiter.(PriorityEngine)
+	assert.True(t, ok, "Dry run should support priorities")
+
+	now := time.Now()
+	assert.True(t, p.AllowAtPriority(now, priority.Sheddable))
+	assert.True(t, p.AllowAtPriority(now, priority.Sheddable))
+	assert.Equal(t, uint64(1), limiter.(*dryRun).Stats().WouldDeny, "Second sheddable request exceeds its share")
+}
+
+// TestShadow_EnforcesPrimaryDecision tests that the shadow wrapper enforces the primary engine and compares the candidate.
+func TestShadow_EnforcesPrimaryDecision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	enforcing := mocks.NewMockEngine(ctrl)
+	candidate := mocks.NewMockEngine(ctrl)
+	gomock.InOrder(
+		enforcing.EXPECT().AllowAt(gomock.Any()).Return(true),
+		enforcing.EXPECT().AllowAt(gomock.Any()).Return(true),
+		enforcing.EXPECT().AllowAt(gomock.Any()).Return(false),
+	)
+	gomock.InOrder(
+		candidate.EXPECT().AllowAt(gomock.Any()).Return(true),
+		candidate.EXPECT().AllowAt(gomock.Any()).Return(false),
+		candidate.EXPECT().AllowAt(gomock.Any()).Return(true),
+	)
+
+	shadow := NewShadow("test-shadow", enforcing, candidate)
+	now := time.Now()
+	assert.True(t, shadow.AllowAt(now))
+	assert.True(t, shadow.AllowAt(now), "Enforcing decision should win")
+	assert.False(t, shadow.AllowAt(now), "Enforcing decision should win")
+
+	assert.Equal(t, DryRunStats{Evaluated: 3, WouldDeny: 1, ShadowDenied: 1, ShadowAllowed: 1}, shadow.Stats())
+}
+
+// TestEngineFactory_Shadow tests that a shadow engine can be configured from options.
+func TestEngineFactory_Shadow(t *testing.T) {
+	base := []Option{WithEngineType(FixedWindow), WithCapacity(1), WithWindowSize(1000)}
+	shadowOpts, err := ParseSpec("fixed-window:capacity=2")
+	assert.NoError(t, err)
+
+	limiter, err := EngineFactory(append(base, WithShadow(append(base, shadowOpts...)...))...)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	assert.True(t, limiter.AllowAt(now))
+	assert.False(t, limiter.AllowAt(now), "Enforcing engine only allows 1 request")
+	assert.Equal(t, uint64(1), limiter.(*shadow).Stats().ShadowAllowed, "Shadow engine allows 2 requests")
+}
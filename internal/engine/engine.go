@@ -1,11 +1,16 @@
 package engine
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"time"
 
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/fairqueue"
 	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
 	"github.com/minhthong582000/rate-limiter/internal/engine/leakybucket"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 	"github.com/minhthong582000/rate-limiter/internal/engine/slidingwindow"
 	"github.com/minhthong582000/rate-limiter/internal/engine/tokenbucket"
 )
@@ -18,6 +23,8 @@ const (
 	SlidingWindowCounter EngineType = "sliding-window-counter"
 	TokenBucket          EngineType = "token-bucket"
 	LeakyBucket          EngineType = "leaky-bucket"
+	CalendarWindow       EngineType = "calendar-window"
+	FairQueue            EngineType = "fair-queue"
 )
 
 func StringToEngineType(s string) EngineType {
@@ -32,6 +39,10 @@ func StringToEngineType(s string) EngineType {
 		return TokenBucket
 	case "leaky-bucket":
 		return LeakyBucket
+	case "calendar-window":
+		return CalendarWindow
+	case "fair-queue":
+		return FairQueue
 	default:
 		return ""
 	}
@@ -44,10 +55,221 @@ type Engine interface {
 	AllowAt(arriveAt time.Time) bool
 }
 
+// PriorityEngine is an engine that reserves part of its capacity for higher priority requests.
+// All built-in engines implement it, AllowAt treats requests as priority.Normal.
+type PriorityEngine interface {
+	Engine
+	// AllowAtPriority checks if a request of the given class is allowed to be processed at the given time
+	AllowAtPriority(arriveAt time.Time, class priority.Class) bool
+}
+
+// KeyedEngine is an engine that tracks requests per key (tenant, client IP, API key...).
+// AllowAt uses the empty key.
+type KeyedEngine interface {
+	Engine
+	// AllowAtKey checks if a request of the given key is allowed to be processed at the given time
+	AllowAtKey(arriveAt time.Time, key string) bool
+}
+
+// KeyedCostEngine is a keyed engine that supports requests costing more than one request, see CostEngine.
+type KeyedCostEngine interface {
+	KeyedEngine
+	// AllowNAtKey checks if a request of the given key costing n requests is allowed to be processed at the given time
+	AllowNAtKey(arriveAt time.Time, key string, n uint64) bool
+}
+
+// PerKeyEngine is a keyed engine giving every key its own engine, like the engines with a limit per key.
+type PerKeyEngine interface {
+	KeyedEngine
+	// EngineAtKey returns the engine of the key, nil if the key has none yet
+	EngineAtKey(key string) Engine
+}
+
+// CostEngine is an engine that supports requests costing more than one request,
+// e.g. a batch API call or a large upload. The leaky bucket and the fair queue don't,
+// as they queue whole requests.
+type CostEngine interface {
+	Engine
+	// AllowNAt checks if a request costing n requests is allowed to be processed at the given time
+	AllowNAt(arriveAt time.Time, n uint64) bool
+}
+
+// LevelEngine is an engine that exposes its state over time, to draw it on charts.
+type LevelEngine interface {
+	Engine
+	// LevelName describes the level, e.g. "tokens"
+	LevelName() string
+	// Level returns the level at the given time (tokens left, requests counted in the window,
+	// queued requests...) and its maximum
+	Level(at time.Time) (float64, float64)
+}
+
+// QueueEngine is an engine that holds the admitted requests in a queue and processes them later,
+// e.g. the leaky bucket. The time requests spend in the queue is the price of its smooth output.
+type QueueEngine interface {
+	Engine
+	// OnProcessed sets a function called with the arrival and the processing time of every request
+	// leaving the queue, and returns false if the engine doesn't queue requests. The function is
+	// called with the engine locked, it must not call the engine.
+	OnProcessed(fn func(arriveAt, processedAt time.Time)) bool
+}
+
+// AllowAtPriority checks a request of the given class,
+// falling back to AllowAt if the engine doesn't support priorities.
+func AllowAtPriority(e Engine, arriveAt time.Time, class priority.Class) bool {
+	if p, ok := e.(PriorityEngine); ok {
+		return p.AllowAtPriority(arriveAt, class)
+	}
+	return e.AllowAt(arriveAt)
+}
+
+// AllowAtKey checks a request of the given key,
+// falling back to AllowAt if the engine doesn't support keys.
+func AllowAtKey(e Engine, arriveAt time.Time, key string) bool {
+	if k, ok := e.(KeyedEngine); ok {
+		return k.AllowAtKey(arriveAt, key)
+	}
+	return e.AllowAt(arriveAt)
+}
+
+// EngineAtKey returns the engine checking the requests of the key, to read its level: its own engine if the engine
+// has one per key, nil if the key has none yet, the engine itself otherwise.
+func EngineAtKey(e Engine, key string) Engine {
+	if p, ok := e.(PerKeyEngine); ok {
+		return p.EngineAtKey(key)
+	}
+	return e
+}
+
+// AllowNAt checks a request costing n requests,
+// falling back to AllowAt, counting the request once, if the engine doesn't support costs.
+func AllowNAt(e Engine, arriveAt time.Time, n uint64) bool {
+	if c, ok := e.(CostEngine); ok {
+		return c.AllowNAt(arriveAt, n)
+	}
+	return e.AllowAt(arriveAt)
+}
+
+// AllowNAtKey checks a request of the given key costing n requests, falling back to AllowAtKey, counting the
+// request once, if the engine doesn't support costs per key, and to AllowNAt if it doesn't support keys.
+func AllowNAtKey(e Engine, arriveAt time.Time, key string, n uint64) bool {
+	if c, ok := e.(KeyedCostEngine); ok {
+		return c.AllowNAtKey(arriveAt, key, n)
+	}
+	if _, ok := e.(KeyedEngine); ok {
+		return AllowAtKey(e, arriveAt, key)
+	}
+	return AllowNAt(e, arriveAt, n)
+}
+
+// Wait blocks until a request of the key is allowed by an engine of the configuration, or the context is done.
+// The engine is checked again after the time a denied request should wait, at most every poll interval, as
+// windows don't know when a request is allowed. Engines queueing the requests allow them at once.
+func Wait(ctx context.Context, e Engine, c *Config, key string, poll time.Duration) error {
+	return WaitN(ctx, e, c, key, 1, poll)
+}
+
+// WaitN is like Wait for a request costing n requests, see AllowNAtKey
+func WaitN(ctx context.Context, e Engine, c *Config, key string, n uint64, poll time.Duration) error {
+	for {
+		now := time.Now()
+		if AllowNAtKey(e, now, key, n) {
+			return nil
+		}
+		if err := Sleep(ctx, min(max(retryAfter(e, c, now, key, n), time.Millisecond), poll)); err != nil {
+			return err
+		}
+	}
+}
+
+// retryAfter returns how long a denied request costing n requests should wait: the time to refill the missing
+// tokens of a token bucket, or the retry time of the configuration
+func retryAfter(e Engine, c *Config, at time.Time, key string, n uint64) time.Duration {
+	if c.EngineType == TokenBucket && c.FillRate > 0 {
+		if l, ok := EngineAtKey(e, key).(LevelEngine); ok {
+			tokens, _ := l.Level(at)
+			return time.Duration(math.Max(float64(n)*c.ConsumeRate-tokens, 1) / c.FillRate * float64(time.Millisecond))
+		}
+	}
+	return c.RetryAfter(at)
+}
+
+// Sleep waits for the duration, or returns the error of the context if it is done first
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// EngineFactory creates the engine of the options, with its dry run, shadow and per-key wrappers. Invalid
+// configurations are rejected with an error.
 func EngineFactory(opts ...Option) (Engine, error) {
-	config := &Config{}
-	for _, opt := range opts {
-		opt(config)
+	config := NewConfig(opts...)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	name := config.Name
+	if name == "" {
+		name = string(config.EngineType)
+	}
+
+	engine, err := newEngine(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.PerKey {
+		if config.EngineType == FairQueue {
+			return nil, fmt.Errorf("fair queue already has a queue per tenant, it can't be used per key")
+		}
+		first := engine
+		engine = NewPerKey(func() Engine {
+			// Reuse the engine created to validate the configuration for the first key
+			if first != nil {
+				e := first
+				first = nil
+				return e
+			}
+			e, _ := newEngine(config)
+			return e
+		})
+	}
+
+	if config.Shadow != nil {
+		candidate, err := EngineFactory(append([]Option{WithStopChannel(config.StopCh), WithQuiet(config.Quiet)}, config.Shadow...)...)
+		if err != nil {
+			return nil, fmt.Errorf("shadow engine: %w", err)
+		}
+		shadow := NewShadow(name, engine, candidate)
+		shadow.quiet = config.Quiet
+		engine = shadow
+	}
+
+	if config.DryRun {
+		dryRun := NewDryRun(name, engine)
+		dryRun.quiet = config.Quiet
+		engine = dryRun
+	}
+
+	return engine, nil
+}
+
+// newEngine creates an engine of the configured type, without wrappers
+func newEngine(config *Config) (Engine, error) {
+	// Options of the engines processing requests in the background are ignored by the others
+	opts := []engineopt.Option{engineopt.WithPriorityShares(config.PriorityShares)}
+	if config.VirtualTime {
+		opts = append(opts, engineopt.WithVirtualTime(config.StartTime))
+	}
+	if config.Quiet {
+		opts = append(opts, engineopt.WithQuiet())
 	}
 
 	var engine Engine
@@ -56,28 +278,48 @@ func EngineFactory(opts ...Option) (Engine, error) {
 		engine = fixedsizewindow.NewFixedSizeWindow(
 			config.Capacity,
 			config.windowSize,
+			opts...,
 		)
 	case SlidingWindowLog:
 		engine = slidingwindow.NewSlidingWindowLogs(
 			config.Capacity,
 			config.windowSize,
+			opts...,
 		)
 	case SlidingWindowCounter:
 		engine = slidingwindow.NewSlidingWindowCounter(
 			float64(config.Capacity),
 			int64(config.windowSize),
+			opts...,
 		)
 	case TokenBucket:
 		engine = tokenbucket.NewTokenBucket(
 			float64(config.Capacity),
 			config.FillRate,
 			config.ConsumeRate,
+			opts...,
 		)
 	case LeakyBucket:
 		engine = leakybucket.NewLeakyBucket(
 			config.Capacity,
 			config.LeakRate,
 			config.StopCh,
+			opts...,
+		)
+	case CalendarWindow:
+		engine = fixedsizewindow.NewCalendarWindow(
+			config.Capacity,
+			config.Period,
+			config.Location,
+			opts...,
+		)
+	case FairQueue:
+		engine = fairqueue.NewFairQueue(
+			config.Capacity,
+			config.LeakRate,
+			config.StopCh,
+			fairqueue.Tenants{Default: config.DefaultTenant, Keys: config.Tenants},
+			opts...,
 		)
 	default:
 		return nil, fmt.Errorf("invalid rate-limiter engine type")
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWait tests that Wait blocks until the engine allows the request, or the context is done
+func TestWait(t *testing.T) {
+	opts := []Option{
+		WithEngineType(TokenBucket),
+		WithCapacity(1),
+		WithFillRate(1.0 / 50),
+		WithConsumeRate(1),
+	}
+	limiter, err := EngineFactory(opts...)
+	assert.NoError(t, err)
+	config := NewConfig(opts...)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, Wait(context.Background(), limiter, config, "", time.Second))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "Requests should wait for a token every 50ms")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, Wait(ctx, limiter, config, "", time.Second), context.DeadlineExceeded)
+}
+
+// TestWaitN tests that a request costing several requests waits for the missing tokens only
+func TestWaitN(t *testing.T) {
+	opts := []Option{
+		WithEngineType(TokenBucket),
+		WithCapacity(10),
+		WithFillRate(1.0 / 10),
+		WithConsumeRate(1),
+	}
+	limiter, err := EngineFactory(opts...)
+	assert.NoError(t, err)
+	config := NewConfig(opts...)
+
+	start := time.Now()
+	assert.NoError(t, WaitN(context.Background(), limiter, config, "", 10, time.Second), "The burst should be allowed at once")
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	assert.NoError(t, WaitN(context.Background(), limiter, config, "", 5, time.Second))
+	assert.GreaterOrEqual(t, time.Since(start), 45*time.Millisecond, "5 tokens should take 50ms")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+// TestSleep tests that Sleep returns the error of the context if it is done first
+func TestSleep(t *testing.T) {
+	assert.NoError(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, Sleep(ctx, time.Hour), context.Canceled)
+}
@@ -0,0 +1,63 @@
+// Package engineopt holds the options shared by the engines.
+package engineopt
+
+import (
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+)
+
+// Options are the options of an engine. Virtual and Quiet only apply to the engines processing
+// requests in the background, like the leaky bucket.
+type Options struct {
+	Shares    priority.Shares
+	StartTime time.Time // Time the engine starts tracking requests from
+	Virtual   bool      // Process requests when they arrive instead of in the background
+	Quiet     bool      // Don't log the processed requests
+}
+
+type Option func(o *Options)
+
+// NewOptions applies the options to the defaults: no reservation, starting now, in the background
+func NewOptions(opts ...Option) *Options {
+	o := &Options{
+		Shares:    priority.NoReservation,
+		StartTime: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithPriorityShares reserves part of the capacity for higher priority requests.
+func WithPriorityShares(shares priority.Shares) Option {
+	return func(o *Options) {
+		o.Shares = shares
+	}
+}
+
+// WithStartTime sets the time the engine starts tracking requests from, default is now.
+// Used to replay requests with synthetic timestamps.
+func WithStartTime(startTime time.Time) Option {
+	return func(o *Options) {
+		o.StartTime = startTime
+	}
+}
+
+// WithVirtualTime stops processing requests in the background. Instead, requests are processed
+// when a new request arrives, as if they had been processed in the background since startTime.
+// Used to replay requests with synthetic timestamps.
+func WithVirtualTime(startTime time.Time) Option {
+	return func(o *Options) {
+		o.Virtual = true
+		o.StartTime = startTime
+	}
+}
+
+// WithQuiet stops logging the processed requests.
+func WithQuiet() Option {
+	return func(o *Options) {
+		o.Quiet = true
+	}
+}
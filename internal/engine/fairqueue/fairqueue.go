@@ -0,0 +1,342 @@
+package fairqueue
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/pkg/ringbuffer"
+)
+
+// Tenant is the fair queuing configuration of a single key.
+type Tenant struct {
+	Weight        float64 // Share of the drain rate relative to other tenants
+	QueueCapacity uint64  // Max requests queued for this tenant
+}
+
+// Tenants is the configuration of the listed tenants, and of the others. The default tenant has a weight of 1
+// and the capacity of the fair queue as queue capacity if unset.
+type Tenants struct {
+	Default *Tenant
+	Keys    map[string]Tenant
+}
+
+// fairQueue is a leaky bucket with one queue per tenant. Queues are drained at the global
+// drain rate using deficit round robin, so a noisy tenant can only fill its own queue
+// and gets its weighted share of the drain rate.
+type fairQueue struct {
+	capacity      uint64 // Max requests queued across all tenants
+	drainRate     time.Duration
+	defaultTenant Tenant
+	tenants       map[string]Tenant
+	shares        priority.Shares
+	queues        map[string]*ringbuffer.RingBuffer[time.Time]
+	size          uint64
+
+	// Deficit round robin state
+	active      []string // Tenants with queued requests, in round robin order
+	deficit     map[string]float64
+	next        int  // Index of the tenant whose turn it is
+	turnStarted bool // Whether the current tenant already received its quantum
+
+	virtual  bool      // Drain when requests arrive instead of in the background
+	lastLeak time.Time // Virtual time only: time of the last drain tick
+	quiet    bool      // Don't log the processed requests
+
+	onProcessed func(arriveAt, processedAt time.Time) // Called for every drained request, if set
+
+	mutex  sync.Mutex
+	stopCh <-chan struct{}
+}
+
+func NewFairQueue(
+	capacity uint64,
+	drainRate time.Duration,
+	stopCh <-chan struct{},
+	tenants Tenants,
+	opts ...engineopt.Option,
+) *fairQueue {
+	if drainRate <= 0 {
+		panic("drain rate must be greater than 0")
+	}
+
+	if capacity <= 0 {
+		panic("capacity must be greater than 0")
+	}
+
+	for key, tenant := range tenants.Keys {
+		if tenant.Weight <= 0 || tenant.QueueCapacity <= 0 {
+			panic(fmt.Sprintf("tenant %q: weight and queue capacity must be greater than 0", key))
+		}
+	}
+	defaultTenant := Tenant{Weight: 1, QueueCapacity: capacity}
+	if tenants.Default != nil {
+		defaultTenant = *tenants.Default
+	}
+	if defaultTenant.Weight <= 0 || defaultTenant.QueueCapacity <= 0 {
+		panic("default tenant: weight and queue capacity must be greater than 0")
+	}
+	if tenants.Keys == nil {
+		tenants.Keys = map[string]Tenant{}
+	}
+
+	o := engineopt.NewOptions(opts...)
+	f := &fairQueue{
+		capacity:      capacity,
+		drainRate:     drainRate,
+		defaultTenant: defaultTenant,
+		tenants:       tenants.Keys,
+		shares:        o.Shares,
+		queues:        map[string]*ringbuffer.RingBuffer[time.Time]{},
+		deficit:       map[string]float64{},
+		virtual:       o.Virtual,
+		lastLeak:      o.StartTime,
+		quiet:         o.Quiet,
+		stopCh:        stopCh,
+	}
+
+	if !f.virtual {
+		go f.leak()
+	}
+
+	return f
+}
+
+func (f *fairQueue) tenant(key string) Tenant {
+	if tenant, ok := f.tenants[key]; ok {
+		return tenant
+	}
+	return f.defaultTenant
+}
+
+// AllowAtKey enqueues the request into the queue of the given tenant.
+func (f *fairQueue) AllowAtKey(arriveAt time.Time, key string) bool {
+	return f.allow(arriveAt, key, priority.Normal)
+}
+
+// AllowAtPriority enqueues the request into the queue of the default tenant if the requests queued across all
+// tenants stay within the share of capacity of its class.
+func (f *fairQueue) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return f.allow(arriveAt, "", class)
+}
+
+func (f *fairQueue) allow(arriveAt time.Time, key string, class priority.Class) bool {
+	limit := f.shares.Limit(float64(f.capacity), class)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.virtual {
+		f.leakUntil(arriveAt)
+	}
+
+	if f.size >= f.capacity || float64(f.size+1) > limit {
+		return false
+	}
+
+	queue, ok := f.queues[key]
+	if !ok {
+		queue = ringbuffer.NewRingBuffer[time.Time](f.tenant(key).QueueCapacity)
+		f.queues[key] = queue
+	}
+
+	if queue.IsFull() {
+		return false
+	}
+
+	if queue.IsEmpty() {
+		f.active = append(f.active, key)
+	}
+
+	err := queue.PushBack(arriveAt)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	f.size++
+
+	return true
+}
+
+func (f *fairQueue) AllowAt(arriveAt time.Time) bool {
+	return f.AllowAtKey(arriveAt, "")
+}
+
+func (f *fairQueue) Allow() bool {
+	return f.AllowAt(time.Now())
+}
+
+// dequeue pops the next request using deficit round robin. Every request costs 1,
+// each turn a tenant receives a quantum equal to its weight and is served while its deficit allows.
+func (f *fairQueue) dequeue() (string, time.Time, bool) {
+	for len(f.active) > 0 {
+		key := f.active[f.next]
+		if !f.turnStarted {
+			f.deficit[key] += f.tenant(key).Weight
+			f.turnStarted = true
+		}
+
+		if f.deficit[key] >= 1 {
+			queue := f.queues[key]
+			request, err := queue.PopFront()
+			if err != nil {
+				fmt.Println(err)
+				return "", time.Time{}, false
+			}
+			f.deficit[key]--
+			f.size--
+
+			// Idle tenants don't keep their deficit
+			if queue.IsEmpty() {
+				f.active = append(f.active[:f.next], f.active[f.next+1:]...)
+				delete(f.deficit, key)
+				delete(f.queues, key)
+				f.turnStarted = false
+				if f.next >= len(f.active) {
+					f.next = 0
+				}
+			}
+
+			return key, request, true
+		}
+
+		// End of turn, move to the next tenant
+		f.next = (f.next + 1) % len(f.active)
+		f.turnStarted = false
+	}
+
+	return "", time.Time{}, false
+}
+
+// leakUntil drains the requests that would have been drained by the ticker until t.
+// Caller must hold the mutex.
+func (f *fairQueue) leakUntil(t time.Time) {
+	for !t.Before(f.lastLeak.Add(f.drainRate)) {
+		f.lastLeak = f.lastLeak.Add(f.drainRate)
+
+		key, request, ok := f.dequeue()
+		if !ok {
+			// Skip the ticks where there is nothing to drain
+			f.lastLeak = f.lastLeak.Add(t.Sub(f.lastLeak) / f.drainRate * f.drainRate)
+			return
+		}
+		f.processed(key, request, f.lastLeak)
+	}
+}
+
+func (f *fairQueue) processed(key string, request time.Time, processedAt time.Time) {
+	if f.onProcessed != nil {
+		f.onProcessed(request, processedAt)
+	}
+	if !f.quiet {
+		fmt.Printf("Processed request of tenant %q: %v\n", key, request)
+	}
+}
+
+func (f *fairQueue) leak() {
+	ticker := time.NewTicker(f.drainRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.mutex.Lock()
+
+			if key, request, ok := f.dequeue(); ok {
+				f.processed(key, request, time.Now())
+			}
+
+			f.mutex.Unlock()
+		case <-f.stopCh:
+			fmt.Println("Fair queue stopped")
+			return
+		}
+	}
+}
+
+// OnProcessed sets a function called with the arrival and the processing time of every drained request
+func (f *fairQueue) OnProcessed(fn func(arriveAt, processedAt time.Time)) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.onProcessed = fn
+	return true
+}
+
+// ParseTenants parses per-tenant weights and queue capacities given as "key=value" lists,
+// e.g. weights "a=2,b=1" and queue capacities "a=10".
+func ParseTenants(weights string, queueCapacities string, defaultTenant Tenant) (map[string]Tenant, error) {
+	tenants := map[string]Tenant{}
+	get := func(key string) Tenant {
+		if tenant, ok := tenants[key]; ok {
+			return tenant
+		}
+		return defaultTenant
+	}
+
+	err := parsePairs(weights, func(key, value string) error {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight <= 0 {
+			return fmt.Errorf("invalid weight %q for tenant %q", value, key)
+		}
+		tenant := get(key)
+		tenant.Weight = weight
+		tenants[key] = tenant
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = parsePairs(queueCapacities, func(key, value string) error {
+		queueCapacity, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || queueCapacity == 0 {
+			return fmt.Errorf("invalid queue capacity %q for tenant %q", value, key)
+		}
+		tenant := get(key)
+		tenant.QueueCapacity = queueCapacity
+		tenants[key] = tenant
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tenants, nil
+}
+
+func parsePairs(s string, fn func(key, value string) error) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid pair %q, must be key=value", pair)
+		}
+		if err := fn(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fairQueue) LevelName() string {
+	return "queue depth"
+}
+
+// Level returns the requests queued across all tenants at the given time and the capacity
+func (f *fairQueue) Level(at time.Time) (float64, float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.virtual {
+		f.leakUntil(at)
+	}
+	return float64(f.size), float64(f.capacity)
+}
@@ -0,0 +1,201 @@
+package fairqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+)
+
+// TestNewFairQueue ensures the constructor initializes the fair queue correctly.
+func TestNewFairQueue(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	limiter := NewFairQueue(10, time.Second, stopCh, Tenants{Keys: map[string]Tenant{"a": {Weight: 2, QueueCapacity: 5}}})
+
+	assert.Equal(t, uint64(10), limiter.capacity, "Capacity should be 10")
+	assert.Equal(t, time.Second, limiter.drainRate, "Drain rate should be 1 second")
+	assert.Equal(t, Tenant{Weight: 2, QueueCapacity: 5}, limiter.tenant("a"))
+	assert.Equal(t, Tenant{Weight: 1, QueueCapacity: 10}, limiter.tenant("b"), "Unknown tenants should use the default")
+}
+
+// TestFairQueue_NoisyTenant tests that a noisy tenant can't fill the queues of other tenants.
+func TestFairQueue_NoisyTenant(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	limiter := NewFairQueue(10, time.Hour, stopCh, Tenants{Default: &Tenant{Weight: 1, QueueCapacity: 4}})
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	for i := 0; i < 4; i++ {
+		assert.True(t, limiter.AllowAtKey(ts, "noisy"), "Request %d of noisy tenant should be allowed", i+1)
+	}
+	assert.False(t, limiter.AllowAtKey(ts, "noisy"), "Noisy tenant should be limited by its queue capacity")
+	assert.True(t, limiter.AllowAtKey(ts, "quiet"), "Other tenants should still be allowed")
+}
+
+// TestFairQueue_GlobalCapacity tests that the total number of queued requests is bounded.
+func TestFairQueue_GlobalCapacity(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	limiter := NewFairQueue(3, time.Hour, stopCh, Tenants{})
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	assert.True(t, limiter.AllowAtKey(ts, "a"))
+	assert.True(t, limiter.AllowAtKey(ts, "b"))
+	assert.True(t, limiter.AllowAt(ts))
+	assert.False(t, limiter.AllowAtKey(ts, "c"), "Request should be denied when all queues are full")
+}
+
+// TestFairQueue_DeficitRoundRobin tests that queues are drained in proportion to their weights.
+func TestFairQueue_DeficitRoundRobin(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	limiter := NewFairQueue(20, time.Hour, stopCh, Tenants{Keys: map[string]Tenant{
+		"a": {Weight: 2, QueueCapacity: 10},
+		"b": {Weight: 1, QueueCapacity: 10},
+		"c": {Weight: 0.5, QueueCapacity: 10},
+	}})
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	for i := 0; i < 6; i++ {
+		assert.True(t, limiter.AllowAtKey(ts, "a"))
+		assert.True(t, limiter.AllowAtKey(ts, "b"))
+		assert.True(t, limiter.AllowAtKey(ts, "c"))
+	}
+
+	var order []string
+	for i := 0; i < 14; i++ {
+		key, _, ok := limiter.dequeue()
+		assert.True(t, ok)
+		order = append(order, key)
+	}
+
+	// Round 1: a gets 2, b gets 1, c gets 0.5 (not enough to send)
+	// Round 2: a gets 2, b gets 1, c reaches 1 and sends 1...
+	expected := []string{
+		"a", "a", "b",
+		"a", "a", "b", "c",
+		"a", "a", "b",
+		"b", "c", "b", "b",
+	}
+	assert.Equal(t, expected, order)
+}
+
+// TestFairQueue_IdleTenantLosesDeficit tests that a tenant doesn't accumulate credit while it has nothing queued.
+func TestFairQueue_IdleTenantLosesDeficit(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	limiter := NewFairQueue(10, time.Hour, stopCh, Tenants{Keys: map[string]Tenant{"a": {Weight: 3, QueueCapacity: 10}}})
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	assert.True(t, limiter.AllowAtKey(ts, "a"))
+	key, _, ok := limiter.dequeue()
+	assert.True(t, ok)
+	assert.Equal(t, "a", key)
+	assert.Empty(t, limiter.deficit, "Idle tenant should not keep its deficit")
+
+	_, _, ok = limiter.dequeue()
+	assert.False(t, ok, "Nothing left to dequeue")
+}
+
+// TestFairQueue_Leak tests that queued requests are drained over time.
+func TestFairQueue_Leak(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	limiter := NewFairQueue(1, 50*time.Millisecond, stopCh, Tenants{})
+
+	assert.True(t, limiter.AllowAtKey(time.Now(), "a"))
+	assert.False(t, limiter.AllowAtKey(time.Now(), "b"), "Queue should be full")
+
+	time.Sleep(150 * time.Millisecond) // Ensure the leak happens
+	assert.True(t, limiter.AllowAtKey(time.Now(), "b"), "Request after the leak should be allowed")
+}
+
+// TestParseTenants tests parsing of per-tenant weights and queue capacities.
+func TestParseTenants(t *testing.T) {
+	defaultTenant := Tenant{Weight: 1, QueueCapacity: 5}
+
+	tenants, err := ParseTenants("a=2, b=0.5", "a=10,c=3", defaultTenant)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Tenant{
+		"a": {Weight: 2, QueueCapacity: 10},
+		"b": {Weight: 0.5, QueueCapacity: 5},
+		"c": {Weight: 1, QueueCapacity: 3},
+	}, tenants)
+
+	_, err = ParseTenants("a=0", "", defaultTenant)
+	assert.Error(t, err, "Zero weight should fail")
+
+	_, err = ParseTenants("", "a", defaultTenant)
+	assert.Error(t, err, "Missing value should fail")
+}
+
+// TestFairQueue_InvalidConfig ensures invalid configurations panic.
+func TestFairQueue_InvalidConfig(t *testing.T) {
+	assert.Panics(t, func() {
+		NewFairQueue(0, time.Second, make(chan struct{}), Tenants{})
+	}, "Creating a fair queue with zero capacity should panic")
+
+	assert.Panics(t, func() {
+		NewFairQueue(5, 0, make(chan struct{}), Tenants{})
+	}, "Creating a fair queue with zero drain rate should panic")
+
+	assert.Panics(t, func() {
+		NewFairQueue(5, time.Second, make(chan struct{}), Tenants{Keys: map[string]Tenant{"a": {Weight: 0, QueueCapacity: 1}}})
+	}, "Creating a fair queue with zero tenant weight should panic")
+}
+
+// TestFairQueue_Priority tests that the capacity reserved for higher priority classes is kept for them
+func TestFairQueue_Priority(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewFairQueue(10, time.Hour, nil, Tenants{},
+		engineopt.WithVirtualTime(start),
+		engineopt.WithQuiet(),
+		engineopt.WithPriorityShares(priority.DefaultShares),
+	)
+
+	for i := 0; i < 7; i++ {
+		assert.True(t, limiter.AllowAtPriority(start, priority.Sheddable), "Request %d should fit in the sheddable share", i+1)
+	}
+	assert.False(t, limiter.AllowAtPriority(start, priority.Sheddable), "Sheddable requests should only use 70% of the capacity")
+	assert.True(t, limiter.AllowAtKey(start, "a"), "Normal requests should use the capacity reserved for them")
+	assert.True(t, limiter.AllowAtKey(start, "b"))
+	assert.False(t, limiter.AllowAtKey(start, "c"), "Normal requests should only use 90% of the capacity")
+	assert.True(t, limiter.AllowAtPriority(start, priority.Critical), "Critical requests should use the whole capacity")
+	assert.False(t, limiter.AllowAtPriority(start, priority.Critical))
+}
+
+// TestFairQueue_VirtualTime tests that requests are drained based on the arrival times instead of the wall clock.
+func TestFairQueue_VirtualTime(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewFairQueue(2, time.Second, nil, Tenants{}, engineopt.WithVirtualTime(start))
+
+	assert.True(t, limiter.AllowAtKey(start, "a"))
+	assert.True(t, limiter.AllowAtKey(start, "b"))
+	assert.False(t, limiter.AllowAtKey(start.Add(999*time.Millisecond), "c"), "Queue should be full before the first drain tick")
+	assert.True(t, limiter.AllowAtKey(start.Add(time.Second), "c"), "1 request should be drained at 1s")
+	assert.True(t, limiter.AllowAtKey(start.Add(time.Hour), "c"))
+	assert.Equal(t, uint64(1), limiter.size, "Queues should be drained after a long time")
+}
+
+// TestFairQueue_OnProcessed tests that the drained requests are reported with their drain tick
+func TestFairQueue_OnProcessed(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewFairQueue(4, time.Second, nil, Tenants{}, engineopt.WithVirtualTime(start), engineopt.WithQuiet())
+
+	var delays []time.Duration
+	assert.True(t, limiter.OnProcessed(func(arriveAt, processedAt time.Time) {
+		delays = append(delays, processedAt.Sub(arriveAt))
+	}))
+
+	assert.True(t, limiter.AllowAtKey(start, "a"))
+	assert.True(t, limiter.AllowAtKey(start, "a"))
+	assert.True(t, limiter.AllowAtKey(start.Add(500*time.Millisecond), "b"))
+	limiter.Level(start.Add(10 * time.Second))
+
+	// Tenants take turns: b is drained before the second request of a
+	assert.Equal(t, []time.Duration{time.Second, 1500 * time.Millisecond, 3 * time.Second}, delays)
+}
@@ -0,0 +1,202 @@
+package fixedsizewindow
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+)
+
+// Period is the length of a calendar-aligned window.
+type Period string
+
+const (
+	Second Period = "second"
+	Minute Period = "minute"
+	Hour   Period = "hour"
+	Day    Period = "day"
+	Month  Period = "month"
+)
+
+func ParsePeriod(s string) (Period, error) {
+	switch Period(s) {
+	case Second, Minute, Hour, Day, Month:
+		return Period(s), nil
+	default:
+		return "", fmt.Errorf("invalid period %q, must be one of second, minute, hour, day, month", s)
+	}
+}
+
+// WindowStart returns the boundary of the window containing t, evaluated in loc.
+// Second, minute and hour windows are truncated in absolute time at the offset of t,
+// as the wall clock is ambiguous in the hour repeated when DST ends.
+func (p Period) WindowStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	year, month, day := t.Date()
+
+	switch p {
+	case Second:
+		return truncate(t, time.Second)
+	case Minute:
+		return truncate(t, time.Minute)
+	case Hour:
+		return truncate(t, time.Hour)
+	case Day:
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	case Month:
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	default:
+		panic(fmt.Sprintf("invalid period %q", p))
+	}
+}
+
+// truncate rounds t down to a multiple of d on its wall clock
+func truncate(t time.Time, d time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(d).Add(-shift).In(t.Location())
+}
+
+// WindowEnd returns the start of the window following the one containing t.
+// Day and month windows follow the calendar, so they are not always the same length
+// (DST changes, months with 28-31 days).
+func (p Period) WindowEnd(t time.Time, loc *time.Location) time.Time {
+	start := p.WindowStart(t, loc)
+
+	switch p {
+	case Second:
+		return start.Add(time.Second)
+	case Minute:
+		return start.Add(time.Minute)
+	case Hour:
+		return start.Add(time.Hour)
+	case Day:
+		return start.AddDate(0, 0, 1)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+type calendarState struct {
+	currCount   uint64
+	windowStart time.Time
+}
+
+// calendarWindow is a fixed window whose boundaries are aligned to the wall clock
+// instead of the time of the last reset, e.g. "10k requests per month" resets at
+// midnight of the first day of every month in the configured timezone.
+type calendarWindow struct {
+	capacity uint64 // Max requests allowed in the window
+	period   Period
+	location *time.Location
+	shares   priority.Shares
+	state    atomic.Pointer[calendarState]
+}
+
+func NewCalendarWindow(
+	capacity uint64,
+	period Period,
+	location *time.Location,
+	opts ...engineopt.Option,
+) *calendarWindow {
+	if _, err := ParsePeriod(string(period)); err != nil {
+		panic(err.Error())
+	}
+
+	if location == nil {
+		location = time.UTC
+	}
+
+	o := engineopt.NewOptions(opts...)
+	c := &calendarWindow{
+		capacity: capacity,
+		period:   period,
+		location: location,
+		shares:   o.Shares,
+	}
+	c.state.Store(&calendarState{
+		currCount:   0,
+		windowStart: period.WindowStart(o.StartTime, location),
+	})
+	return c
+}
+
+func (c *calendarWindow) AllowAt(arriveAt time.Time) bool {
+	return c.AllowAtPriority(arriveAt, priority.Normal)
+}
+
+// AllowAtPriority only allows the request if the window count stays within
+// the share of capacity of its class.
+func (c *calendarWindow) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return c.allowN(arriveAt, class, 1)
+}
+
+// AllowNAt checks if a request costing n requests is allowed, counting it n times in the window
+func (c *calendarWindow) AllowNAt(arriveAt time.Time, n uint64) bool {
+	return c.allowN(arriveAt, priority.Normal, n)
+}
+
+func (c *calendarWindow) allowN(arriveAt time.Time, class priority.Class, n uint64) bool {
+	limit := c.shares.Limit(float64(c.capacity), class)
+	windowStart := c.period.WindowStart(arriveAt, c.location)
+
+	for {
+		lastState := c.state.Load()
+
+		if windowStart.Before(lastState.windowStart) {
+			// A lot of contention results in lots of CAS retries.
+			// This might causes the lastState.windowStart to be in the future of arriveAt.
+			return false
+		}
+
+		// Reset the counter if the request arrives in a new window
+		if windowStart.After(lastState.windowStart) {
+			if float64(n) > limit {
+				return false
+			}
+
+			newState := &calendarState{
+				currCount:   n,
+				windowStart: windowStart,
+			}
+			if c.state.CompareAndSwap(lastState, newState) {
+				return true
+			}
+			// Retry if CAS fails
+			continue
+		}
+
+		if float64(lastState.currCount+n) <= limit {
+			newState := &calendarState{
+				currCount:   lastState.currCount + n,
+				windowStart: lastState.windowStart,
+			}
+			if c.state.CompareAndSwap(lastState, newState) {
+				return true
+			}
+			// Retry if CAS fails
+			continue
+		}
+
+		return false
+	}
+}
+
+func (c *calendarWindow) Allow() bool {
+	return c.AllowAt(time.Now())
+}
+
+func (c *calendarWindow) LevelName() string {
+	return "window count"
+}
+
+// Level returns the requests counted in the window at the given time and the capacity
+func (c *calendarWindow) Level(at time.Time) (float64, float64) {
+	s := c.state.Load()
+	if c.period.WindowStart(at, c.location).After(s.windowStart) {
+		return 0, float64(c.capacity)
+	}
+	return float64(s.currCount), float64(c.capacity)
+}
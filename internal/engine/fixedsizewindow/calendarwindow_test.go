@@ -0,0 +1,190 @@
+package fixedsizewindow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+)
+
+// TestNewCalendarWindow tests the calendar window rate limiter constructor.
+func TestNewCalendarWindow(t *testing.T) {
+	limiter := NewCalendarWindow(3, Hour, nil)
+
+	assert.Equal(t, uint64(3), limiter.capacity, "Capacity should be 3")
+	assert.Equal(t, Hour, limiter.period, "Period should be hour")
+	assert.Equal(t, time.UTC, limiter.location, "Location should default to UTC")
+
+	state := limiter.state.Load()
+	assert.Equal(t, uint64(0), state.currCount, "Initial count should be 0")
+	assert.Equal(t, 0, state.windowStart.Minute(), "Initial window should be aligned to the hour")
+}
+
+// TestCalendarWindow_AlignedToClock tests that windows reset on wall-clock boundaries
+// rather than on the time of the first request.
+func TestCalendarWindow_AlignedToClock(t *testing.T) {
+	limiter := NewCalendarWindow(2, Minute, time.UTC)
+	limiter.state.Store(&calendarState{currCount: 0, windowStart: time.Unix(0, 0).UTC()})
+
+	requests := []string{
+		"2025-01-01T00:00:59Z",
+		"2025-01-01T00:00:59.5Z",
+		"2025-01-01T00:00:59.9Z", // Window is full
+
+		// Only 1 second later, but in the next minute
+		"2025-01-01T00:01:00Z",
+	}
+
+	for i := 0; i < 2; i++ {
+		ts, _ := time.Parse(time.RFC3339Nano, requests[i])
+		assert.True(t, limiter.AllowAt(ts), "Request %d should be allowed", i+1)
+	}
+
+	ts, _ := time.Parse(time.RFC3339Nano, requests[2])
+	assert.False(t, limiter.AllowAt(ts), "Request 3 should be denied")
+
+	ts, _ = time.Parse(time.RFC3339Nano, requests[3])
+	assert.True(t, limiter.AllowAt(ts), "Request at the minute boundary should be allowed")
+}
+
+// TestCalendarWindow_Second tests that second windows reset on the second boundaries.
+func TestCalendarWindow_Second(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 900*int(time.Millisecond), time.UTC)
+	limiter := NewCalendarWindow(1, Second, time.UTC, engineopt.WithStartTime(start))
+
+	assert.True(t, limiter.AllowAt(start))
+	assert.False(t, limiter.AllowAt(start.Add(50*time.Millisecond)), "Request in the same second should be denied")
+	assert.True(t, limiter.AllowAt(start.Add(100*time.Millisecond)), "Request in the next second should be allowed")
+	assert.Equal(t, start.Add(1100*time.Millisecond), Second.WindowEnd(start.Add(100*time.Millisecond), time.UTC))
+}
+
+// TestCalendarWindow_DailyTimezone tests that daily windows reset at midnight in the configured timezone.
+func TestCalendarWindow_DailyTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Ho_Chi_Minh") // UTC+7
+	if err != nil {
+		t.Skipf("timezone database not available: %v", err)
+	}
+
+	limiter := NewCalendarWindow(1, Day, loc)
+	limiter.state.Store(&calendarState{currCount: 0, windowStart: time.Unix(0, 0).UTC()})
+
+	requests := []string{
+		"2025-01-01T16:00:00Z", // 23:00 local
+		"2025-01-01T16:59:59Z", // 23:59:59 local, same day
+		"2025-01-01T17:00:00Z", // 00:00 local, next day
+	}
+
+	ts, _ := time.Parse(time.RFC3339, requests[0])
+	assert.True(t, limiter.AllowAt(ts), "First request of the day should be allowed")
+
+	ts, _ = time.Parse(time.RFC3339, requests[1])
+	assert.False(t, limiter.AllowAt(ts), "Second request of the same local day should be denied")
+
+	ts, _ = time.Parse(time.RFC3339, requests[2])
+	assert.True(t, limiter.AllowAt(ts), "Request after local midnight should be allowed")
+}
+
+// TestPeriod_DSTFallBack tests that the windows of the hour repeated when DST ends contain their time.
+func TestPeriod_DSTFallBack(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone database not available: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		at     string
+		period Period
+		start  string
+	}{
+		{name: "hour before DST ends", at: "2025-11-02T05:30:00Z", period: Hour, start: "2025-11-02T05:00:00Z"},     // 01:30 EDT
+		{name: "repeated hour", at: "2025-11-02T06:30:00Z", period: Hour, start: "2025-11-02T06:00:00Z"},            // 01:30 EST
+		{name: "repeated minute", at: "2025-11-02T06:30:30Z", period: Minute, start: "2025-11-02T06:30:00Z"},        // 01:30:30 EST
+		{name: "repeated second", at: "2025-11-02T06:30:30.5Z", period: Second, start: "2025-11-02T06:30:30Z"},      // 01:30:30.5 EST
+		{name: "end of the repeated hour", at: "2025-11-02T06:45:00Z", period: Hour, start: "2025-11-02T06:00:00Z"}, // 01:45 EST
+		{name: "day of the repeated hour", at: "2025-11-02T06:30:00Z", period: Day, start: "2025-11-02T04:00:00Z"},  // 00:00 EDT
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339Nano, tc.at)
+			expected, _ := time.Parse(time.RFC3339, tc.start)
+			start := tc.period.WindowStart(at, loc)
+			end := tc.period.WindowEnd(at, loc)
+
+			assert.True(t, start.Equal(expected), "Window should start at %v, got %v", expected, start)
+			assert.False(t, start.After(at), "Window should start before %v", at)
+			assert.True(t, end.After(at), "Window should end after %v, got %v", at, end)
+		})
+	}
+}
+
+// TestPeriod_MonthLength tests that monthly windows have variable lengths.
+func TestPeriod_MonthLength(t *testing.T) {
+	testCases := []struct {
+		name     string
+		at       string
+		expected time.Duration
+	}{
+		{name: "January", at: "2025-01-15T10:00:00Z", expected: 31 * 24 * time.Hour},
+		{name: "February", at: "2025-02-15T10:00:00Z", expected: 28 * 24 * time.Hour},
+		{name: "February leap year", at: "2024-02-15T10:00:00Z", expected: 29 * 24 * time.Hour},
+		{name: "April", at: "2025-04-30T23:59:59Z", expected: 30 * 24 * time.Hour},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, _ := time.Parse(time.RFC3339, tc.at)
+			start := Month.WindowStart(ts, time.UTC)
+			end := Month.WindowEnd(ts, time.UTC)
+
+			assert.Equal(t, 1, start.Day(), "Window should start on the first day of the month")
+			assert.Equal(t, tc.expected, end.Sub(start))
+		})
+	}
+}
+
+// TestCalendarWindow_MonthlyQuota tests a monthly quota across a month boundary.
+func TestCalendarWindow_MonthlyQuota(t *testing.T) {
+	limiter := NewCalendarWindow(2, Month, time.UTC)
+	limiter.state.Store(&calendarState{currCount: 0, windowStart: time.Unix(0, 0).UTC()})
+
+	requests := []string{
+		"2025-02-01T00:00:00Z",
+		"2025-02-14T12:00:00Z",
+		"2025-02-28T23:59:59Z", // Quota exhausted
+		"2025-03-01T00:00:00Z", // New month
+	}
+
+	for i := 0; i < 2; i++ {
+		ts, _ := time.Parse(time.RFC3339, requests[i])
+		assert.True(t, limiter.AllowAt(ts), "Request %d should be allowed", i+1)
+	}
+
+	ts, _ := time.Parse(time.RFC3339, requests[2])
+	assert.False(t, limiter.AllowAt(ts), "Request 3 should be denied")
+
+	ts, _ = time.Parse(time.RFC3339, requests[3])
+	assert.True(t, limiter.AllowAt(ts), "Request in the next month should be allowed")
+}
+
+// TestCalendarWindow_NegativeElapsedTime ensures that requests from a previous window are denied.
+func TestCalendarWindow_NegativeElapsedTime(t *testing.T) {
+	limiter := NewCalendarWindow(5, Hour, time.UTC)
+	limiter.state.Store(&calendarState{currCount: 0, windowStart: time.Unix(0, 0).UTC()})
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-02T00:00:00Z")
+	assert.True(t, limiter.AllowAt(ts))
+
+	ts, _ = time.Parse(time.RFC3339, "2025-01-01T23:00:00Z")
+	assert.False(t, limiter.AllowAt(ts), "Request from a previous window should fail")
+}
+
+// TestCalendarWindow_InvalidPeriod ensures that an unknown period panics.
+func TestCalendarWindow_InvalidPeriod(t *testing.T) {
+	assert.Panics(t, func() {
+		NewCalendarWindow(5, Period("week"), time.UTC)
+	}, "Creating a calendar window with an unknown period should panic")
+}
@@ -3,6 +3,9 @@ package fixedsizewindow
 import (
 	"sync/atomic"
 	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 type state struct {
@@ -13,29 +16,50 @@ type state struct {
 type fixedSizeWindow struct {
 	capacity   uint64 // Max requests allowed in the window
 	windowSize int64
+	shares     priority.Shares
 	state      atomic.Pointer[state]
 }
 
 func NewFixedSizeWindow(
 	capacity uint64,
 	windowSize int64,
+	opts ...engineopt.Option,
 ) *fixedSizeWindow {
 	if windowSize <= 0 {
 		panic("window size must be greater than 0")
 	}
 
+	o := engineopt.NewOptions(opts...)
 	f := &fixedSizeWindow{
 		capacity:   capacity,
 		windowSize: windowSize,
+		shares:     o.Shares,
 	}
 	f.state.Store(&state{
 		currCount: 0,
-		lastTime:  time.Now(),
+		lastTime:  o.StartTime,
 	})
 	return f
 }
 
 func (f *fixedSizeWindow) AllowAt(arriveAt time.Time) bool {
+	return f.AllowAtPriority(arriveAt, priority.Normal)
+}
+
+// AllowAtPriority only allows the request if the window count stays within
+// the share of capacity of its class.
+func (f *fixedSizeWindow) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return f.allowN(arriveAt, class, 1)
+}
+
+// AllowNAt checks if a request costing n requests is allowed, counting it n times in the window
+func (f *fixedSizeWindow) AllowNAt(arriveAt time.Time, n uint64) bool {
+	return f.allowN(arriveAt, priority.Normal, n)
+}
+
+func (f *fixedSizeWindow) allowN(arriveAt time.Time, class priority.Class, n uint64) bool {
+	limit := f.shares.Limit(float64(f.capacity), class)
+
 	for {
 		lastState := f.state.Load()
 		elapsed := arriveAt.Sub(lastState.lastTime).Milliseconds()
@@ -48,8 +72,12 @@ func (f *fixedSizeWindow) AllowAt(arriveAt time.Time) bool {
 
 		// Reset the window if new request arrives after the window has expired
 		if elapsed > f.windowSize {
+			if float64(n) > limit {
+				return false
+			}
+
 			newState := &state{
-				currCount: 1,
+				currCount: n,
 				lastTime:  arriveAt,
 			}
 			if f.state.CompareAndSwap(lastState, newState) {
@@ -59,9 +87,9 @@ func (f *fixedSizeWindow) AllowAt(arriveAt time.Time) bool {
 			continue
 		}
 
-		if lastState.currCount < f.capacity {
+		if float64(lastState.currCount+n) <= limit {
 			newState := &state{
-				currCount: lastState.currCount + 1,
+				currCount: lastState.currCount + n,
 				lastTime:  lastState.lastTime,
 			}
 			if f.state.CompareAndSwap(lastState, newState) {
@@ -78,3 +106,16 @@ func (f *fixedSizeWindow) AllowAt(arriveAt time.Time) bool {
 func (f *fixedSizeWindow) Allow() bool {
 	return f.AllowAt(time.Now())
 }
+
+func (f *fixedSizeWindow) LevelName() string {
+	return "window count"
+}
+
+// Level returns the requests counted in the window at the given time and the capacity
+func (f *fixedSizeWindow) Level(at time.Time) (float64, float64) {
+	s := f.state.Load()
+	if at.Sub(s.lastTime).Milliseconds() > f.windowSize {
+		return 0, float64(f.capacity)
+	}
+	return float64(s.currCount), float64(f.capacity)
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 // TestNewFixedSizeWindow tests the fixed-size window rate limiter constructor.
@@ -140,3 +143,36 @@ func TestFixedSizeWindow_ZeroWindow(t *testing.T) {
 		NewFixedSizeWindow(5, 0)
 	}, "Creating a rate limiter with zero window size should panic")
 }
+
+// TestFixedSizeWindow_Priority tests that lower priorities are rejected first when the window is almost full.
+func TestFixedSizeWindow_Priority(t *testing.T) {
+	limiter := NewFixedSizeWindow(10, 1000, engineopt.WithPriorityShares(priority.DefaultShares))
+	limiter.state.Store(&state{currCount: 0, lastTime: time.Unix(0, 0).UTC()})
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	for i := 0; i < 7; i++ {
+		assert.True(t, limiter.AllowAtPriority(ts, priority.Sheddable), "Sheddable request %d should be allowed", i+1)
+	}
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Sheddable), "Sheddable request should be denied above 70% of capacity")
+
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Normal))
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Normal))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Normal), "Normal request should be denied above 90% of capacity")
+
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Critical), "Critical request should use the reserved capacity")
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Critical), "Critical request should be denied when the window is full")
+}
+
+// TestFixedSizeWindow_Cost tests that a request costing n requests is counted n times.
+func TestFixedSizeWindow_Cost(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	limiter := NewFixedSizeWindow(5, 1000, engineopt.WithStartTime(start))
+
+	assert.True(t, limiter.AllowNAt(start, 3))
+	assert.False(t, limiter.AllowNAt(start, 3), "Request should be denied when it doesn't fit in the window")
+	assert.True(t, limiter.AllowNAt(start, 2))
+	assert.False(t, limiter.AllowAt(start), "Window should be full")
+
+	assert.False(t, limiter.AllowNAt(start.Add(2*time.Second), 6), "Request costing more than the capacity should be denied")
+	assert.True(t, limiter.AllowNAt(start.Add(2*time.Second), 5))
+}
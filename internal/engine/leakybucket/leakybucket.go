@@ -5,21 +5,37 @@ import (
 	"sync"
 	"time"
 
-	"github.com/minhthong582000/rate-limiter/pkg/ringbuffer"
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/pkg/priorityqueue"
 )
 
+type request struct {
+	arriveAt time.Time
+	class    priority.Class
+}
+
 type leakyBucket struct {
 	capacity  uint64 // Max burst
 	drainRate time.Duration
-	queue     *ringbuffer.RingBuffer[time.Time]
+	shares    priority.Shares
+	queue     *priorityqueue.PriorityQueue[request] // Higher priority requests are drained first
+	virtual   bool                                  // Drain when requests arrive instead of in the background
+	lastLeak  time.Time                             // Virtual time only: time of the last drain tick
+	quiet     bool                                  // Don't log the processed requests
 	mutex     sync.Mutex
 	stopCh    <-chan struct{}
+	doneCh    chan struct{} // Closed by Stop
+	stopOnce  sync.Once
+
+	onProcessed func(arriveAt, processedAt time.Time) // Called for every drained request, if set
 }
 
 func NewLeakyBucket(
 	capacity uint64,
 	drainRate time.Duration,
 	stopCh <-chan struct{},
+	opts ...engineopt.Option,
 ) *leakyBucket {
 	if drainRate <= 0 {
 		panic("drain rate must be greater than 0")
@@ -29,27 +45,49 @@ func NewLeakyBucket(
 		panic("capacity must be greater than 0")
 	}
 
+	o := engineopt.NewOptions(opts...)
 	l := &leakyBucket{
 		capacity:  capacity,
 		drainRate: drainRate,
-		queue:     ringbuffer.NewRingBuffer[time.Time](capacity),
-		stopCh:    stopCh,
+		shares:    o.Shares,
+		queue: priorityqueue.NewPriorityQueue(capacity, func(a, b request) bool {
+			return a.class < b.class
+		}),
+		virtual:  o.Virtual,
+		lastLeak: o.StartTime,
+		quiet:    o.Quiet,
+		stopCh:   stopCh,
+		doneCh:   make(chan struct{}),
 	}
 
-	go l.leak()
+	if !l.virtual {
+		go l.leak()
+	}
 
 	return l
 }
 
 func (l *leakyBucket) AllowAt(arriveAt time.Time) bool {
+	return l.AllowAtPriority(arriveAt, priority.Normal)
+}
+
+// AllowAtPriority only enqueues the request if the queue length stays within
+// the share of capacity of its class.
+func (l *leakyBucket) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	limit := l.shares.Limit(float64(l.capacity), class)
+
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	if l.queue.IsFull() {
+	if l.virtual {
+		l.leakUntil(arriveAt)
+	}
+
+	if l.queue.IsFull() || float64(l.queue.Size()+1) > limit {
 		return false
 	}
 
-	err := l.queue.PushBack(arriveAt)
+	err := l.queue.Push(request{arriveAt: arriveAt, class: class})
 	if err != nil {
 		fmt.Println(err)
 		return false
@@ -62,6 +100,35 @@ func (l *leakyBucket) Allow() bool {
 	return l.AllowAt(time.Now())
 }
 
+// leakUntil drains the requests that would have been drained by the ticker until t.
+// Caller must hold the mutex.
+func (l *leakyBucket) leakUntil(t time.Time) {
+	for !t.Before(l.lastLeak.Add(l.drainRate)) {
+		l.lastLeak = l.lastLeak.Add(l.drainRate)
+		if l.queue.IsEmpty() {
+			// Skip the ticks where there is nothing to drain
+			l.lastLeak = l.lastLeak.Add(t.Sub(l.lastLeak) / l.drainRate * l.drainRate)
+			return
+		}
+
+		request, err := l.queue.Pop()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		l.processed(request, l.lastLeak)
+	}
+}
+
+func (l *leakyBucket) processed(r request, processedAt time.Time) {
+	if l.onProcessed != nil {
+		l.onProcessed(r.arriveAt, processedAt)
+	}
+	if !l.quiet {
+		fmt.Printf("Processed %s request: %v\n", r.class, r.arriveAt)
+	}
+}
+
 func (l *leakyBucket) leak() {
 	ticker := time.NewTicker(l.drainRate)
 	defer ticker.Stop()
@@ -72,11 +139,11 @@ func (l *leakyBucket) leak() {
 			l.mutex.Lock()
 
 			if !l.queue.IsEmpty() {
-				request, err := l.queue.PopFront()
+				request, err := l.queue.Pop()
 				if err != nil {
 					fmt.Println(err)
 				} else {
-					fmt.Printf("Processed request: %v\n", request)
+					l.processed(request, time.Now())
 				}
 			}
 
@@ -84,6 +151,38 @@ func (l *leakyBucket) leak() {
 		case <-l.stopCh:
 			fmt.Println("Leaky bucket stopped")
 			return
+		case <-l.doneCh:
+			return
 		}
 	}
 }
+
+// Stop stops draining the queue before the stop channel is closed, e.g. when the engine is dropped
+func (l *leakyBucket) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.doneCh)
+	})
+}
+
+func (l *leakyBucket) LevelName() string {
+	return "queue depth"
+}
+
+// Level returns the queued requests at the given time and the capacity
+func (l *leakyBucket) Level(at time.Time) (float64, float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.virtual {
+		l.leakUntil(at)
+	}
+	return float64(l.queue.Size()), float64(l.capacity)
+}
+
+// OnProcessed sets a function called with the arrival and the processing time of every drained request
+func (l *leakyBucket) OnProcessed(fn func(arriveAt, processedAt time.Time)) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.onProcessed = fn
+	return true
+}
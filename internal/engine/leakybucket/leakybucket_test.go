@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 // TestNewLeakyBucket ensures the constructor initializes the leaky bucket correctly.
@@ -95,3 +98,101 @@ func TestLeakyBucket_ZeroDrainRate(t *testing.T) {
 		NewLeakyBucket(5, 0, make(chan struct{}))
 	}, "Creating a leaky bucket with zero drain rate should panic")
 }
+
+// TestLeakyBucket_Priority tests that lower priorities are rejected first and higher priorities are drained first.
+func TestLeakyBucket_Priority(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	limiter := NewLeakyBucket(4, time.Hour, stopCh, engineopt.WithPriorityShares(priority.Shares{1, 0.75, 0.5}))
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Sheddable))
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Sheddable))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Sheddable), "Sheddable request should be denied above 50% of capacity")
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Normal))
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Critical))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Critical), "Critical request should be denied when the queue is full")
+
+	// Critical request is drained first, then normal, then sheddable
+	expected := []priority.Class{priority.Critical, priority.Normal, priority.Sheddable, priority.Sheddable}
+	for _, class := range expected {
+		req, err := limiter.queue.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, class, req.class)
+	}
+}
+
+// TestLeakyBucket_VirtualTime tests that requests are drained based on the arrival times instead of the wall clock.
+func TestLeakyBucket_VirtualTime(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewLeakyBucket(2, time.Second, nil, engineopt.WithVirtualTime(start))
+
+	requests := []string{
+		"2025-01-01T00:00:00Z",
+		"2025-01-01T00:00:00.5Z",
+		"2025-01-01T00:00:00.9Z", // Queue is full, nothing drained yet
+
+		"2025-01-01T00:00:01Z", // 1 request drained at 1s
+		"2025-01-01T00:00:01.5Z",
+
+		"2025-01-01T00:01:00Z", // Queue is empty after a long time
+		"2025-01-01T00:01:00Z",
+		"2025-01-01T00:01:00Z",
+	}
+	expected := []bool{true, true, false, true, false, true, true, false}
+
+	for i, r := range requests {
+		ts, _ := time.Parse(time.RFC3339Nano, r)
+		assert.Equal(t, expected[i], limiter.AllowAt(ts), "Request %d", i+1)
+	}
+	assert.Equal(t, start.Add(time.Minute), limiter.lastLeak, "Drain ticks should stay aligned to the start time")
+}
+
+// TestLeakyBucket_Level tests that the level is the queue depth at the given time.
+func TestLeakyBucket_Level(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewLeakyBucket(3, time.Second, nil, engineopt.WithVirtualTime(start), engineopt.WithQuiet())
+
+	assert.Equal(t, "queue depth", limiter.LevelName())
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.AllowAt(start))
+	}
+
+	level, max := limiter.Level(start)
+	assert.Equal(t, 3.0, level)
+	assert.Equal(t, 3.0, max)
+
+	level, _ = limiter.Level(start.Add(2 * time.Second))
+	assert.Equal(t, 1.0, level, "2 requests should be drained after 2s")
+}
+
+// TestLeakyBucket_OnProcessed tests that the drained requests are reported with their drain tick
+func TestLeakyBucket_OnProcessed(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewLeakyBucket(3, time.Second, nil, engineopt.WithVirtualTime(start), engineopt.WithQuiet())
+
+	var delays []time.Duration
+	assert.True(t, limiter.OnProcessed(func(arriveAt, processedAt time.Time) {
+		delays = append(delays, processedAt.Sub(arriveAt))
+	}))
+
+	assert.True(t, limiter.AllowAt(start))
+	assert.True(t, limiter.AllowAt(start.Add(200*time.Millisecond)))
+	assert.True(t, limiter.AllowAt(start.Add(500*time.Millisecond)))
+	limiter.Level(start.Add(10 * time.Second))
+
+	// One request is drained every second, the last one waits for the third tick
+	assert.Equal(t, []time.Duration{time.Second, 1800 * time.Millisecond, 2500 * time.Millisecond}, delays)
+}
+
+// TestLeakyBucket_Stop tests that a stopped bucket doesn't drain its queue anymore.
+func TestLeakyBucket_Stop(t *testing.T) {
+	limiter := NewLeakyBucket(1, 50*time.Millisecond, nil, engineopt.WithQuiet())
+
+	limiter.Stop()
+	limiter.Stop() // Stopping twice is a no-op
+
+	assert.True(t, limiter.Allow())
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, limiter.Allow(), "Queue should not be drained after stop")
+}
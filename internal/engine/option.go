@@ -1,21 +1,57 @@
 package engine
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/fairqueue"
+	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+)
 
 type Config struct {
+	Name       string // Used in logs and metrics, default is the engine type
 	EngineType EngineType
 	Capacity   uint64
 	StopCh     <-chan struct{}
 
+	// Always allow requests, only log and count the requests that would be denied
+	DryRun bool
+	// Options of a candidate engine evaluated alongside this one
+	Shadow []Option
+
+	// Give every key its own engine, e.g. a limit per client IP or API key
+	PerKey bool
+
+	// Share of capacity each priority class can use
+	PriorityShares priority.Shares
+
+	// Requests are checked with synthetic timestamps starting at StartTime.
+	// Queues are drained when requests arrive instead of in the background.
+	VirtualTime bool
+	StartTime   time.Time
+
+	// Don't log the processed, dry run and shadow requests
+	Quiet bool
+
 	// Token bucket specific configuration
 	FillRate    float64
 	ConsumeRate float64
 
-	// Leaky bucket specific configuration
+	// Leaky bucket and fair queue specific configuration
 	LeakRate time.Duration
 
+	// Fair queue specific configuration
+	DefaultTenant *fairqueue.Tenant
+	Tenants       map[string]fairqueue.Tenant
+
 	// Fixed size window and sliding window specific configuration
 	windowSize int64
+
+	// Calendar window specific configuration
+	Period   fixedsizewindow.Period
+	Location *time.Location
 }
 
 type Option func(f *Config)
@@ -61,3 +97,214 @@ func WithWindowSize(windowSize int64) Option {
 		f.windowSize = windowSize
 	}
 }
+
+func WithPeriod(period fixedsizewindow.Period) Option {
+	return func(f *Config) {
+		f.Period = period
+	}
+}
+
+func WithLocation(location *time.Location) Option {
+	return func(f *Config) {
+		f.Location = location
+	}
+}
+
+func WithPriorityShares(shares priority.Shares) Option {
+	return func(f *Config) {
+		f.PriorityShares = shares
+	}
+}
+
+func WithDefaultTenant(tenant fairqueue.Tenant) Option {
+	return func(f *Config) {
+		f.DefaultTenant = &tenant
+	}
+}
+
+func WithTenants(tenants map[string]fairqueue.Tenant) Option {
+	return func(f *Config) {
+		f.Tenants = tenants
+	}
+}
+
+func WithName(name string) Option {
+	return func(f *Config) {
+		f.Name = name
+	}
+}
+
+func WithDryRun(dryRun bool) Option {
+	return func(f *Config) {
+		f.DryRun = dryRun
+	}
+}
+
+func WithShadow(opts ...Option) Option {
+	return func(f *Config) {
+		f.Shadow = opts
+	}
+}
+
+// WithPerKey gives every key its own engine with the same configuration.
+func WithPerKey(perKey bool) Option {
+	return func(f *Config) {
+		f.PerKey = perKey
+	}
+}
+
+func WithVirtualTime(startTime time.Time) Option {
+	return func(f *Config) {
+		f.VirtualTime = true
+		f.StartTime = startTime
+	}
+}
+
+// WithQuiet stops the engines from logging the processed requests, and the dry run and shadow decisions.
+// Used when running large simulations where only the summary matters.
+func WithQuiet(quiet bool) Option {
+	return func(f *Config) {
+		f.Quiet = quiet
+	}
+}
+
+// Validate checks the parameters used by the engine type, the constructors of the engines panic on
+// invalid parameters
+func (c *Config) Validate() error {
+	switch c.EngineType {
+	case FixedWindow, SlidingWindowLog, SlidingWindowCounter:
+		if c.windowSize <= 0 {
+			return fmt.Errorf("window size must be greater than 0")
+		}
+	case TokenBucket:
+		if c.ConsumeRate <= 0 || c.ConsumeRate > float64(c.Capacity) {
+			return fmt.Errorf("consume rate must be > 0 and <= capacity")
+		}
+		if c.FillRate <= 0 || math.IsInf(c.FillRate, 0) || math.IsNaN(c.FillRate) {
+			return fmt.Errorf("fill rate must be > 0")
+		}
+	case LeakyBucket, FairQueue:
+		if c.LeakRate <= 0 {
+			return fmt.Errorf("drain rate must be greater than 0")
+		}
+		if c.Capacity <= 0 {
+			return fmt.Errorf("capacity must be greater than 0")
+		}
+	case CalendarWindow:
+		if _, err := fixedsizewindow.ParsePeriod(string(c.Period)); err != nil {
+			return err
+		}
+	}
+
+	if c.EngineType == FairQueue {
+		for key, tenant := range c.Tenants {
+			if tenant.Weight <= 0 || tenant.QueueCapacity <= 0 {
+				return fmt.Errorf("tenant %q: weight and queue capacity must be greater than 0", key)
+			}
+		}
+		if c.DefaultTenant != nil && (c.DefaultTenant.Weight <= 0 || c.DefaultTenant.QueueCapacity <= 0) {
+			return fmt.Errorf("default tenant: weight and queue capacity must be greater than 0")
+		}
+	}
+	return nil
+}
+
+// NewConfig returns the configuration of the options, with the defaults of EngineFactory
+func NewConfig(opts ...Option) *Config {
+	config := &Config{
+		PriorityShares: priority.NoReservation,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// RetryAfter returns how long a denied request should wait before it may be allowed: the time to refill
+// the tokens of a request, to drain a queued request, or until the calendar window ends. Fixed and sliding
+// windows return the window size, the longest wait, as their state is not known here.
+func (c *Config) RetryAfter(at time.Time) time.Duration {
+	switch c.EngineType {
+	case TokenBucket:
+		if c.FillRate <= 0 {
+			return 0
+		}
+		return time.Duration(c.ConsumeRate / c.FillRate * float64(time.Millisecond))
+	case LeakyBucket, FairQueue:
+		return c.LeakRate
+	case FixedWindow, SlidingWindowLog, SlidingWindowCounter:
+		return time.Duration(c.windowSize) * time.Millisecond
+	case CalendarWindow:
+		location := c.Location
+		if location == nil {
+			location = time.UTC
+		}
+		return c.Period.WindowEnd(at, location).Sub(at)
+	default:
+		return 0
+	}
+}
+
+// Quota returns the limit as a number of requests per window, e.g. to publish it to clients: the capacity
+// per window of the windows, the requests of a full bucket per time to refill it, or the queue per time to drain it.
+func (c *Config) Quota(at time.Time) (uint64, time.Duration) {
+	switch c.EngineType {
+	case TokenBucket:
+		if c.FillRate <= 0 {
+			return c.Capacity, 0
+		}
+		return uint64(float64(c.Capacity) / math.Max(c.ConsumeRate, 1)), time.Duration(float64(c.Capacity) / c.FillRate * float64(time.Millisecond))
+	case LeakyBucket, FairQueue:
+		return c.Capacity, time.Duration(c.Capacity) * c.LeakRate
+	case FixedWindow, SlidingWindowLog, SlidingWindowCounter:
+		return c.Capacity, time.Duration(c.windowSize) * time.Millisecond
+	case CalendarWindow:
+		location := c.Location
+		if location == nil {
+			location = time.UTC
+		}
+		return c.Capacity, c.Period.WindowEnd(at, location).Sub(c.Period.WindowStart(at, location))
+	default:
+		return c.Capacity, 0
+	}
+}
+
+// Reset returns how long until an engine of this configuration is back to its full quota: the time to refill the
+// missing tokens, or to drain the queued requests. Other engines, and the engines not exposing their level,
+// return the time a denied request should wait.
+func (c *Config) Reset(e Engine, at time.Time) time.Duration {
+	l, ok := e.(LevelEngine)
+	if !ok || l.LevelName() == "" {
+		return c.RetryAfter(at)
+	}
+
+	level, maxLevel := l.Level(at)
+	switch c.EngineType {
+	case TokenBucket:
+		if c.FillRate <= 0 {
+			return 0
+		}
+		return time.Duration(math.Max(maxLevel-level, 0) / c.FillRate * float64(time.Millisecond))
+	case LeakyBucket, FairQueue:
+		return time.Duration(level * float64(c.LeakRate))
+	default:
+		return c.RetryAfter(at)
+	}
+}
+
+// Remaining returns the requests an engine of this configuration would still allow at the given time,
+// false if the engine doesn't expose its level, like the engines with a limit per key
+func (c *Config) Remaining(e Engine, at time.Time) (int64, bool) {
+	l, ok := e.(LevelEngine)
+	if !ok || l.LevelName() == "" {
+		return 0, false
+	}
+
+	level, maxLevel := l.Level(at)
+	if l.LevelName() == "tokens" {
+		// Tokens left, a request may cost more than one token
+		return int64(math.Floor(level / math.Max(c.ConsumeRate, 1))), true
+	}
+	// Requests counted in the window or queued
+	return int64(math.Max(maxLevel-level, 0)), true
+}
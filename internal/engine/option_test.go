@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/fairqueue"
+	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
+)
+
+// TestConfig_RetryAfter tests the time a denied request should wait for each engine
+func TestConfig_RetryAfter(t *testing.T) {
+	at := time.Date(2025, 1, 1, 18, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		opts     []Option
+		expected time.Duration
+	}{
+		{
+			name:     "Token bucket refills the tokens of a request",
+			opts:     []Option{WithEngineType(TokenBucket), WithFillRate(1.0 / 200), WithConsumeRate(2)},
+			expected: 400 * time.Millisecond,
+		},
+		{
+			name:     "Leaky bucket drains a request",
+			opts:     []Option{WithEngineType(LeakyBucket), WithLeakRate(250 * time.Millisecond)},
+			expected: 250 * time.Millisecond,
+		},
+		{
+			name:     "Sliding window waits for the window",
+			opts:     []Option{WithEngineType(SlidingWindowLog), WithWindowSize(1000)},
+			expected: time.Second,
+		},
+		{
+			name:     "Calendar window waits until the end of the period",
+			opts:     []Option{WithEngineType(CalendarWindow), WithPeriod(fixedsizewindow.Day), WithLocation(time.UTC)},
+			expected: 6 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewConfig(tc.opts...).RetryAfter(at))
+		})
+	}
+}
+
+// TestConfig_Quota tests the requests per window published for each engine
+func TestConfig_Quota(t *testing.T) {
+	at := time.Date(2025, 2, 10, 18, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		opts           []Option
+		expectedQuota  uint64
+		expectedWindow time.Duration
+	}{
+		{
+			name:           "Token bucket allows a full bucket per refill",
+			opts:           []Option{WithEngineType(TokenBucket), WithCapacity(10), WithFillRate(1.0 / 100), WithConsumeRate(2)},
+			expectedQuota:  5,
+			expectedWindow: time.Second,
+		},
+		{
+			name:           "Leaky bucket queues its capacity per drain",
+			opts:           []Option{WithEngineType(LeakyBucket), WithCapacity(4), WithLeakRate(250 * time.Millisecond)},
+			expectedQuota:  4,
+			expectedWindow: time.Second,
+		},
+		{
+			name:           "Fixed window allows its capacity per window",
+			opts:           []Option{WithEngineType(FixedWindow), WithCapacity(100), WithWindowSize(60000)},
+			expectedQuota:  100,
+			expectedWindow: time.Minute,
+		},
+		{
+			name:           "Calendar window follows the calendar",
+			opts:           []Option{WithEngineType(CalendarWindow), WithCapacity(1000), WithPeriod(fixedsizewindow.Month), WithLocation(time.UTC)},
+			expectedQuota:  1000,
+			expectedWindow: 28 * 24 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			quota, window := NewConfig(tc.opts...).Quota(at)
+			assert.Equal(t, tc.expectedQuota, quota)
+			assert.Equal(t, tc.expectedWindow, window)
+		})
+	}
+}
+
+// TestConfig_Remaining tests the requests left in a token bucket and a window, and keyed engines without a level
+func TestConfig_Remaining(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	opts := []Option{WithEngineType(TokenBucket), WithCapacity(6), WithFillRate(1.0 / 3600000), WithConsumeRate(2), WithVirtualTime(at)}
+	config := NewConfig(opts...)
+	e, err := EngineFactory(opts...)
+	assert.NoError(t, err)
+	assert.True(t, e.AllowAt(at))
+	remaining, ok := config.Remaining(e, at)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), remaining, "Requests costing 2 tokens should be left")
+
+	opts = []Option{WithEngineType(FixedWindow), WithCapacity(3), WithWindowSize(1000), WithVirtualTime(at)}
+	config = NewConfig(opts...)
+	e, err = EngineFactory(opts...)
+	assert.NoError(t, err)
+	assert.True(t, e.AllowAt(at))
+	remaining, ok = config.Remaining(e, at)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), remaining)
+
+	e, err = EngineFactory(append(opts, WithPerKey(true))...)
+	assert.NoError(t, err)
+	_, ok = config.Remaining(e, at)
+	assert.False(t, ok, "Keyed engines should not expose a level")
+}
+
+// TestConfig_Reset tests the time until an engine is back to its full quota
+func TestConfig_Reset(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	opts := []Option{WithEngineType(TokenBucket), WithCapacity(10), WithFillRate(1.0 / 1000), WithConsumeRate(2), WithVirtualTime(at)}
+	config := NewConfig(opts...)
+	e, err := EngineFactory(opts...)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), config.Reset(e, at), "A full bucket should not wait")
+	assert.True(t, e.AllowAt(at))
+	assert.Equal(t, 2*time.Second, config.Reset(e, at), "2 tokens should take 2s to refill")
+
+	opts = []Option{WithEngineType(FixedWindow), WithCapacity(3), WithWindowSize(1000), WithVirtualTime(at)}
+	config = NewConfig(opts...)
+	e, err = EngineFactory(opts...)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, config.Reset(e, at), "Windows should wait for the window size")
+
+	e, err = EngineFactory(append(opts, WithPerKey(true))...)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, config.Reset(e, at), "Keyed engines should wait for a denied request")
+}
+
+// TestConfig_Validate tests that the invalid configurations are rejected instead of panicking in the constructors
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      []Option
+		expectErr bool
+	}{
+		{
+			name: "Valid token bucket",
+			opts: []Option{WithEngineType(TokenBucket), WithCapacity(10), WithFillRate(1), WithConsumeRate(10)},
+		},
+		{
+			name:      "Consume rate above capacity",
+			opts:      []Option{WithEngineType(TokenBucket), WithCapacity(5), WithFillRate(1), WithConsumeRate(10)},
+			expectErr: true,
+		},
+		{
+			name:      "No fill rate",
+			opts:      []Option{WithEngineType(TokenBucket), WithCapacity(5), WithConsumeRate(1)},
+			expectErr: true,
+		},
+		{
+			name:      "No window size",
+			opts:      []Option{WithEngineType(FixedWindow), WithCapacity(5)},
+			expectErr: true,
+		},
+		{
+			name:      "No drain rate",
+			opts:      []Option{WithEngineType(LeakyBucket), WithCapacity(5)},
+			expectErr: true,
+		},
+		{
+			name:      "Invalid period",
+			opts:      []Option{WithEngineType(CalendarWindow), WithCapacity(5), WithPeriod("week")},
+			expectErr: true,
+		},
+		{
+			name: "Tenant without weight",
+			opts: []Option{
+				WithEngineType(FairQueue), WithCapacity(5), WithLeakRate(time.Second),
+				WithTenants(map[string]fairqueue.Tenant{"a": {QueueCapacity: 1}}),
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewConfig(tc.opts...).Validate()
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestEngineFactory_Invalid tests that the factory returns an error for an invalid shadow engine
+func TestEngineFactory_Invalid(t *testing.T) {
+	opts := []Option{WithEngineType(TokenBucket), WithCapacity(5), WithFillRate(1), WithConsumeRate(1)}
+	_, err := EngineFactory(append(opts, WithShadow(append(opts, WithConsumeRate(10))...))...)
+	assert.Error(t, err)
+}
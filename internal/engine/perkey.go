@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// evictInterval is how often the engines of the idle keys are looked for
+	evictInterval = time.Minute
+	// idleTimeout is how long the engines not exposing their level are kept without requests
+	idleTimeout = 10 * time.Minute
+)
+
+// stopper is an engine processing requests in the background until it is stopped, like the leaky bucket
+type stopper interface {
+	Stop()
+}
+
+// keyEngine is the engine of a key
+type keyEngine struct {
+	engine   Engine
+	lastSeen atomic.Int64 // Arrival time of the last request in Unix nanoseconds
+}
+
+// perKey gives every key its own engine, so that a noisy key can't use the capacity of the others.
+// Engines are created on the first request of a key, and dropped once they are back to their initial
+// state (full bucket, empty window or queue), as a new engine would behave the same.
+type perKey struct {
+	newEngine    func() Engine
+	mutex        sync.RWMutex // Held for writing to add or drop engines
+	engines      map[string]*keyEngine
+	lastEviction atomic.Int64 // Time of the last look for idle keys in Unix nanoseconds
+}
+
+// NewPerKey creates a keyed engine calling newEngine for every new key
+func NewPerKey(newEngine func() Engine) *perKey {
+	return &perKey{
+		newEngine: newEngine,
+		engines:   map[string]*keyEngine{},
+	}
+}
+
+func (p *perKey) AllowAtKey(arriveAt time.Time, key string) bool {
+	return p.allow(arriveAt, key, func(e Engine) bool {
+		return e.AllowAt(arriveAt)
+	})
+}
+
+func (p *perKey) AllowNAtKey(arriveAt time.Time, key string, n uint64) bool {
+	return p.allow(arriveAt, key, func(e Engine) bool {
+		return AllowNAt(e, arriveAt, n)
+	})
+}
+
+// allow checks a request with the engine of the key, creating it if needed
+func (p *perKey) allow(arriveAt time.Time, key string, check func(e Engine) bool) bool {
+	p.evictIdle(arriveAt)
+
+	for {
+		// Engines aren't dropped while a request is checked
+		p.mutex.RLock()
+		k, ok := p.engines[key]
+		if ok {
+			k.lastSeen.Store(arriveAt.UnixNano())
+			allowed := check(k.engine)
+			p.mutex.RUnlock()
+			return allowed
+		}
+		p.mutex.RUnlock()
+
+		p.mutex.Lock()
+		if _, ok := p.engines[key]; !ok {
+			p.engines[key] = &keyEngine{engine: p.newEngine()}
+		}
+		p.mutex.Unlock()
+	}
+}
+
+func (p *perKey) AllowAt(arriveAt time.Time) bool {
+	return p.AllowAtKey(arriveAt, "")
+}
+
+func (p *perKey) Allow() bool {
+	return p.AllowAt(time.Now())
+}
+
+func (p *perKey) EngineAtKey(key string) Engine {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if k, ok := p.engines[key]; ok {
+		return k.engine
+	}
+	return nil
+}
+
+// evictIdle drops the engines of the idle keys, at most once every evictInterval
+func (p *perKey) evictIdle(at time.Time) {
+	if at.Sub(time.Unix(0, p.lastEviction.Load())) < evictInterval {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// Another request may have looked for them in the meantime
+	if at.Sub(time.Unix(0, p.lastEviction.Load())) < evictInterval {
+		return
+	}
+	p.lastEviction.Store(at.UnixNano())
+
+	for key, k := range p.engines {
+		if !k.idle(at) {
+			continue
+		}
+		delete(p.engines, key)
+		if s, ok := k.engine.(stopper); ok {
+			s.Stop()
+		}
+	}
+}
+
+// idle returns whether the engine is back to its initial state at the given time,
+// or hasn't seen requests for idleTimeout if it doesn't expose its level
+func (k *keyEngine) idle(at time.Time) bool {
+	l, ok := k.engine.(LevelEngine)
+	if !ok || l.LevelName() == "" {
+		return at.Sub(time.Unix(0, k.lastSeen.Load())) >= idleTimeout
+	}
+
+	level, maxLevel := l.Level(at)
+	if l.LevelName() == "tokens" {
+		return level >= maxLevel
+	}
+	// Requests counted in the window or queued
+	return level <= 0
+}
+
+// Keys returns the number of keys with an engine
+func (p *perKey) Keys() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return len(p.engines)
+}
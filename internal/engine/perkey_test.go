@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPerKey tests that every key has its own limit.
+func TestPerKey(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter, err := EngineFactory(
+		WithEngineType(FixedWindow),
+		WithCapacity(2),
+		WithWindowSize(1000),
+		WithVirtualTime(start),
+		WithPerKey(true),
+	)
+	assert.NoError(t, err)
+
+	keyed, ok := limiter.(KeyedEngine)
+	assert.True(t, ok, "Per-key engine should be keyed")
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, keyed.AllowAtKey(start, "noisy"))
+	}
+	assert.False(t, keyed.AllowAtKey(start, "noisy"), "Noisy key should be limited")
+	assert.True(t, keyed.AllowAtKey(start, "quiet"), "Other keys should not be affected by the noisy one")
+	assert.True(t, limiter.AllowAt(start), "Requests without a key share the empty key")
+	assert.Equal(t, 3, limiter.(*perKey).Keys())
+
+	assert.True(t, keyed.AllowAtKey(start.Add(2*time.Second), "noisy"), "Keys should be limited independently over time")
+}
+
+// TestPerKey_FairQueue tests that the fair queue can't be used per key.
+func TestPerKey_FairQueue(t *testing.T) {
+	_, err := EngineFactory(
+		WithEngineType(FairQueue),
+		WithCapacity(2),
+		WithLeakRate(time.Second),
+		WithVirtualTime(time.Now()),
+		WithPerKey(true),
+	)
+	assert.Error(t, err)
+}
+
+// TestPerKey_EvictIdle tests that the engines back to their initial state are dropped.
+func TestPerKey_EvictIdle(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter, err := EngineFactory(
+		WithEngineType(TokenBucket),
+		WithCapacity(2),
+		WithFillRate(1.0/60000), // A token per minute
+		WithConsumeRate(1),
+		WithVirtualTime(start),
+		WithPerKey(true),
+	)
+	assert.NoError(t, err)
+	p := limiter.(*perKey)
+
+	assert.True(t, p.AllowAtKey(start, "a"))
+	assert.True(t, p.AllowAtKey(start.Add(30*time.Second), "b"))
+	assert.Equal(t, 2, p.Keys())
+
+	// Only the bucket of a is full again
+	assert.True(t, p.AllowAtKey(start.Add(65*time.Second), "c"))
+	assert.Equal(t, 2, p.Keys(), "Only the full bucket of a should be dropped")
+
+	assert.True(t, p.AllowAtKey(start.Add(100*time.Second), "a"))
+	assert.Equal(t, 3, p.Keys(), "Eviction should wait for the interval")
+
+	assert.True(t, p.AllowAtKey(start.Add(130*time.Second), "d"))
+	assert.Equal(t, 2, p.Keys(), "Buckets of b and c should be dropped")
+}
+
+// TestPerKey_EvictIdle_Stop tests that the dropped engines processing requests in the background are stopped.
+func TestPerKey_EvictIdle_Stop(t *testing.T) {
+	var stopped []*stoppedEngine
+	p := NewPerKey(func() Engine {
+		e := &stoppedEngine{}
+		stopped = append(stopped, e)
+		return e
+	})
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, p.AllowAtKey(start, "a"))
+	assert.True(t, p.AllowAtKey(start.Add(idleTimeout), "b"))
+	assert.Equal(t, 1, p.Keys(), "Engines without a level should be dropped after the idle timeout")
+	assert.True(t, stopped[0].stopped)
+	assert.False(t, stopped[1].stopped)
+}
+
+// stoppedEngine is an engine recording whether it was stopped
+type stoppedEngine struct {
+	stopped bool
+}
+
+func (s *stoppedEngine) Allow() bool                     { return true }
+func (s *stoppedEngine) AllowAt(arriveAt time.Time) bool { return true }
+func (s *stoppedEngine) Stop()                           { s.stopped = true }
+
+// TestEngineAtKey tests that the engine of a key can be read through the wrappers.
+func TestEngineAtKey(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter, err := EngineFactory(
+		WithEngineType(FixedWindow),
+		WithCapacity(2),
+		WithWindowSize(1000),
+		WithVirtualTime(start),
+		WithPerKey(true),
+		WithDryRun(true),
+		WithQuiet(true),
+	)
+	assert.NoError(t, err)
+
+	assert.Nil(t, EngineAtKey(limiter, "a"), "Keys without requests should have no engine")
+	assert.True(t, AllowAtKey(limiter, start, "a"))
+
+	config := NewConfig(WithEngineType(FixedWindow), WithCapacity(2), WithWindowSize(1000))
+	remaining, ok := config.Remaining(EngineAtKey(limiter, "a"), start)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), remaining)
+
+	_, ok = config.Remaining(limiter, start)
+	assert.False(t, ok, "Per-key engine should not expose a level")
+}
@@ -0,0 +1,154 @@
+package priority
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Class is the priority of a request. Lower values are more important.
+type Class uint8
+
+const (
+	Critical  Class = iota // Health checks, payments... Never starved by other classes
+	Normal                 // Default class for requests without a priority
+	Sheddable              // Bulk traffic, rejected first when close to the limit
+
+	numClasses = 3
+)
+
+func (c Class) String() string {
+	switch c {
+	case Critical:
+		return "critical"
+	case Normal:
+		return "normal"
+	case Sheddable:
+		return "sheddable"
+	default:
+		return fmt.Sprintf("class(%d)", uint8(c))
+	}
+}
+
+func ParseClass(s string) (Class, error) {
+	switch s {
+	case "critical":
+		return Critical, nil
+	case "normal":
+		return Normal, nil
+	case "sheddable":
+		return Sheddable, nil
+	default:
+		return 0, fmt.Errorf("invalid priority class %q, must be one of critical, normal, sheddable", s)
+	}
+}
+
+// Shares is the fraction of the engine capacity each class is allowed to use.
+// For example, with Shares{1, 0.9, 0.7} a sheddable request is rejected once 70% of the
+// capacity is in use, leaving the remaining 30% for normal and critical requests.
+type Shares [numClasses]float64
+
+var (
+	// NoReservation lets every class use the whole capacity.
+	NoReservation = Shares{1, 1, 1}
+	// DefaultShares reserves 10% of the capacity for critical requests
+	// and another 20% for normal and critical requests.
+	DefaultShares = Shares{1, 0.9, 0.7}
+)
+
+// Limit returns the part of capacity that requests of the given class can use.
+func (s Shares) Limit(capacity float64, class Class) float64 {
+	if int(class) >= numClasses {
+		class = Sheddable
+	}
+	return capacity * s[class]
+}
+
+// ParseShares parses a list of "class=share" pairs, e.g. "normal=0.9,sheddable=0.7".
+// Classes that are not listed can use the whole capacity.
+func ParseShares(s string) (Shares, error) {
+	shares := NoReservation
+	if err := parseClassValues(s, shares[:], 1); err != nil {
+		return Shares{}, err
+	}
+
+	for i := 1; i < numClasses; i++ {
+		if shares[i] > shares[i-1] {
+			return Shares{}, fmt.Errorf("share of %s must not exceed share of %s", Class(i), Class(i-1))
+		}
+	}
+
+	return shares, nil
+}
+
+// Mix is the proportion of traffic in each class, used to simulate requests with priorities.
+type Mix [numClasses]float64
+
+// ParseMix parses a list of "class=weight" pairs, e.g. "critical=1,normal=6,sheddable=3".
+// Weights don't need to sum up to 1.
+func ParseMix(s string) (Mix, error) {
+	var mix Mix
+	if err := parseClassValues(s, mix[:], math.Inf(1)); err != nil {
+		return Mix{}, err
+	}
+
+	total := 0.0
+	for _, w := range mix {
+		total += w
+	}
+	if total <= 0 {
+		return Mix{}, fmt.Errorf("priority mix must have at least one positive weight")
+	}
+
+	return mix, nil
+}
+
+// Pick returns the class for a uniformly distributed random number r in [0, 1).
+func (m Mix) Pick(r float64) Class {
+	total := 0.0
+	for _, w := range m {
+		total += w
+	}
+
+	acc := 0.0
+	for i, w := range m {
+		acc += w / total
+		if r < acc {
+			return Class(i)
+		}
+	}
+
+	return Class(numClasses - 1)
+}
+
+func parseClassValues(s string, values []float64, maxValue float64) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid pair %q, must be class=value", pair)
+		}
+
+		class, err := ParseClass(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", class, err)
+		}
+		if v < 0 || v > maxValue {
+			return fmt.Errorf("invalid value for %s: %v", class, v)
+		}
+
+		values[class] = v
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package priority
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseShares tests parsing of class shares.
+func TestParseShares(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     string
+		expected  Shares
+		expectErr bool
+	}{
+		{name: "Empty", input: "", expected: NoReservation},
+		{name: "Partial", input: "sheddable=0.5", expected: Shares{1, 1, 0.5}},
+		{name: "Full", input: "critical=1, normal=0.9, sheddable=0.7", expected: DefaultShares},
+		{name: "Unknown class", input: "bulk=0.5", expectErr: true},
+		{name: "Missing value", input: "normal", expectErr: true},
+		{name: "Share above 1", input: "normal=1.5", expectErr: true},
+		{name: "Lower class with bigger share", input: "normal=0.5,sheddable=0.8", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shares, err := ParseShares(tc.input)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, shares)
+		})
+	}
+}
+
+// TestShares_Limit tests the capacity available to each class.
+func TestShares_Limit(t *testing.T) {
+	assert.Equal(t, float64(10), DefaultShares.Limit(10, Critical))
+	assert.Equal(t, float64(9), DefaultShares.Limit(10, Normal))
+	assert.Equal(t, float64(7), DefaultShares.Limit(10, Sheddable))
+}
+
+// TestMix_Pick tests picking a class from a traffic mix.
+func TestMix_Pick(t *testing.T) {
+	mix, err := ParseMix("critical=1,normal=2,sheddable=1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, Critical, mix.Pick(0))
+	assert.Equal(t, Critical, mix.Pick(0.24))
+	assert.Equal(t, Normal, mix.Pick(0.25))
+	assert.Equal(t, Normal, mix.Pick(0.74))
+	assert.Equal(t, Sheddable, mix.Pick(0.75))
+	assert.Equal(t, Sheddable, mix.Pick(0.999))
+
+	_, err = ParseMix("normal=0")
+	assert.Error(t, err, "Mix without positive weight should fail")
+}
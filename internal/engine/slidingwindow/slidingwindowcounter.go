@@ -3,6 +3,9 @@ package slidingwindow
 import (
 	"sync/atomic"
 	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 type state struct {
@@ -15,21 +18,25 @@ type slidingWindowCounter struct {
 	capacity   float64   // Max requests allowed in the window
 	windowSize int64     // Window size in millisecond
 	startTime  time.Time // Used as a monotonic start time to calculate the window
+	shares     priority.Shares
 	state      atomic.Pointer[state]
 }
 
 func NewSlidingWindowCounter(
 	capacity float64,
 	windowSize int64,
+	opts ...engineopt.Option,
 ) *slidingWindowCounter {
 	if windowSize <= 0 {
 		panic("window size must be greater than 0")
 	}
 
+	o := engineopt.NewOptions(opts...)
 	s := &slidingWindowCounter{
 		capacity:   capacity,
 		windowSize: windowSize,
-		startTime:  time.Now(),
+		startTime:  o.StartTime,
+		shares:     o.Shares,
 	}
 
 	s.state.Store(&state{
@@ -41,6 +48,22 @@ func NewSlidingWindowCounter(
 }
 
 func (s *slidingWindowCounter) AllowAt(arriveAt time.Time) bool {
+	return s.AllowAtPriority(arriveAt, priority.Normal)
+}
+
+// AllowAtPriority only allows the request if the estimated count stays within
+// the share of capacity of its class.
+func (s *slidingWindowCounter) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return s.allowN(arriveAt, class, 1)
+}
+
+// AllowNAt checks if a request costing n requests is allowed, counting it n times in the window
+func (s *slidingWindowCounter) AllowNAt(arriveAt time.Time, n uint64) bool {
+	return s.allowN(arriveAt, priority.Normal, n)
+}
+
+func (s *slidingWindowCounter) allowN(arriveAt time.Time, class priority.Class, n uint64) bool {
+	limit := s.shares.Limit(s.capacity, class)
 	now := arriveAt.Sub(s.startTime).Milliseconds()
 
 	for {
@@ -72,8 +95,8 @@ func (s *slidingWindowCounter) AllowAt(arriveAt time.Time) bool {
 		prevWindowWeight := 1 - (float64(now%s.windowSize) / float64(s.windowSize))
 		estimatedCurrCount := newState.prevCount*prevWindowWeight + newState.currCount
 
-		if estimatedCurrCount < s.capacity {
-			newState.currCount += 1
+		if estimatedCurrCount+float64(n)-1 < limit {
+			newState.currCount += float64(n)
 			if s.state.CompareAndSwap(lastState, &newState) {
 				return true
 			}
@@ -88,3 +111,25 @@ func (s *slidingWindowCounter) AllowAt(arriveAt time.Time) bool {
 func (s *slidingWindowCounter) Allow() bool {
 	return s.AllowAt(time.Now())
 }
+
+func (s *slidingWindowCounter) LevelName() string {
+	return "window count"
+}
+
+// Level returns the estimated requests in the window at the given time and the capacity
+func (s *slidingWindowCounter) Level(at time.Time) (float64, float64) {
+	now := at.Sub(s.startTime).Milliseconds()
+	state := s.state.Load()
+	currWindow := now / s.windowSize
+
+	currCount, prevCount := state.currCount, state.prevCount
+	switch {
+	case currWindow == state.currWindow+1:
+		currCount, prevCount = 0, state.currCount
+	case currWindow > state.currWindow+1:
+		currCount, prevCount = 0, 0
+	}
+
+	prevWindowWeight := 1 - (float64(now%s.windowSize) / float64(s.windowSize))
+	return prevCount*prevWindowWeight + currCount, s.capacity
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 // TestNewSlidingWindowCounter tests the sliding window counter constructor
@@ -156,3 +159,52 @@ func TestSlidingWindowCounter_NegativeElapsedTime(t *testing.T) {
 	ts, _ = time.Parse(time.RFC3339, requests[1])
 	assert.False(t, limiter.AllowAt(ts), "Request with negative elapsed time should fail")
 }
+
+// TestSlidingWindowCounter_Priority tests that lower priorities are rejected first when the estimate is close to the capacity.
+func TestSlidingWindowCounter_Priority(t *testing.T) {
+	limiter := NewSlidingWindowCounter(10, 1000, engineopt.WithPriorityShares(priority.DefaultShares))
+	ts := limiter.startTime
+
+	for i := 0; i < 7; i++ {
+		assert.True(t, limiter.AllowAtPriority(ts, priority.Sheddable), "Sheddable request %d should be allowed", i+1)
+	}
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Sheddable), "Sheddable request should be denied above 70% of capacity")
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Normal))
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Normal))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Normal), "Normal request should be denied above 90% of capacity")
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Critical))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Critical), "Critical request should be denied at capacity")
+}
+
+// TestSlidingWindowCounter_Cost tests that a request costing n requests is counted n times.
+func TestSlidingWindowCounter_Cost(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewSlidingWindowCounter(5, 1000, engineopt.WithStartTime(start))
+
+	assert.True(t, limiter.AllowNAt(start, 3))
+	assert.False(t, limiter.AllowNAt(start, 3), "Request should be denied when it doesn't fit in the window")
+	assert.True(t, limiter.AllowNAt(start, 2))
+	assert.False(t, limiter.AllowAt(start), "Window should be full")
+
+	// Half of the previous window is still counted: 5*0.5 + 2 = 4.5 < 5
+	assert.True(t, limiter.AllowNAt(start.Add(1500*time.Millisecond), 2))
+	assert.False(t, limiter.AllowNAt(start.Add(1500*time.Millisecond), 2))
+}
+
+// TestSlidingWindowCounter_Level tests that the level is the estimated count at the given time.
+func TestSlidingWindowCounter_Level(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	limiter := NewSlidingWindowCounter(5, 1000, engineopt.WithStartTime(start))
+
+	assert.True(t, limiter.AllowNAt(start, 4))
+
+	level, max := limiter.Level(start.Add(500 * time.Millisecond))
+	assert.Equal(t, 4.0, level)
+	assert.Equal(t, 5.0, max)
+
+	level, _ = limiter.Level(start.Add(1250 * time.Millisecond))
+	assert.Equal(t, 3.0, level, "75% of the previous window should be counted")
+
+	level, _ = limiter.Level(start.Add(3 * time.Second))
+	assert.Equal(t, 0.0, level)
+}
@@ -4,6 +4,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+
 	"github.com/minhthong582000/rate-limiter/pkg/ringbuffer"
 )
 
@@ -11,25 +14,46 @@ type slidingWindowLogs struct {
 	capacity   uint64 // Max requests allowed in the window
 	windowSize int64  // Window size in millisecond
 	requestLog *ringbuffer.RingBuffer[time.Time]
+	shares     priority.Shares
 	mutex      sync.Mutex
 }
 
 func NewSlidingWindowLogs(
 	capacity uint64,
 	windowSize int64,
+	opts ...engineopt.Option,
 ) *slidingWindowLogs {
 	if windowSize <= 0 {
 		panic("window size must be greater than 0")
 	}
 
+	o := engineopt.NewOptions(opts...)
 	return &slidingWindowLogs{
 		capacity:   capacity,
 		windowSize: windowSize,
 		requestLog: ringbuffer.NewRingBuffer[time.Time](capacity),
+		shares:     o.Shares,
 	}
 }
 
 func (f *slidingWindowLogs) AllowAt(arriveAt time.Time) bool {
+	return f.AllowAtPriority(arriveAt, priority.Normal)
+}
+
+// AllowAtPriority only allows the request if the number of logged requests stays within
+// the share of capacity of its class.
+func (f *slidingWindowLogs) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return f.allowN(arriveAt, class, 1)
+}
+
+// AllowNAt checks if a request costing n requests is allowed, logging it n times
+func (f *slidingWindowLogs) AllowNAt(arriveAt time.Time, n uint64) bool {
+	return f.allowN(arriveAt, priority.Normal, n)
+}
+
+func (f *slidingWindowLogs) allowN(arriveAt time.Time, class priority.Class, n uint64) bool {
+	limit := f.shares.Limit(float64(f.capacity), class)
+
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
@@ -42,8 +66,11 @@ func (f *slidingWindowLogs) AllowAt(arriveAt time.Time) bool {
 		}
 	}
 
-	if !f.requestLog.IsFull() {
-		_ = f.requestLog.PushBack(arriveAt)
+	size := f.requestLog.Size()
+	if size+n <= f.requestLog.Capacity() && float64(size+n) <= limit {
+		for i := uint64(0); i < n; i++ {
+			_ = f.requestLog.PushBack(arriveAt)
+		}
 		return true
 	}
 
@@ -53,3 +80,25 @@ func (f *slidingWindowLogs) AllowAt(arriveAt time.Time) bool {
 func (f *slidingWindowLogs) Allow() bool {
 	return f.AllowAt(time.Now())
 }
+
+func (f *slidingWindowLogs) LevelName() string {
+	return "window count"
+}
+
+// Level returns the requests logged in the window at the given time and the capacity
+func (f *slidingWindowLogs) Level(at time.Time) (float64, float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Requests out of the window would be dropped by the next request anyway
+	for !f.requestLog.IsEmpty() {
+		lastLog, _ := f.requestLog.PeekFront()
+		if at.Sub(lastLog).Milliseconds() > f.windowSize {
+			_, _ = f.requestLog.PopFront()
+		} else {
+			break
+		}
+	}
+
+	return float64(f.requestLog.Size()), float64(f.capacity)
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 // TestNewSlidingWindowLogs tests sliding window logs constructor
@@ -111,3 +114,35 @@ func TestSlidingWindowLogs_ZeroWindowSize(t *testing.T) {
 		NewSlidingWindowLogs(5, 0)
 	}, "Creating a sliding window with zero window size should panic")
 }
+
+// TestSlidingWindowLogs_Priority tests that lower priorities are rejected first when the log is almost full.
+func TestSlidingWindowLogs_Priority(t *testing.T) {
+	limiter := NewSlidingWindowLogs(4, 1000, engineopt.WithPriorityShares(priority.Shares{1, 0.75, 0.5}))
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Sheddable))
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Sheddable))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Sheddable), "Sheddable request should be denied above 50% of capacity")
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Normal))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Normal), "Normal request should be denied above 75% of capacity")
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Critical))
+	assert.False(t, limiter.AllowAtPriority(ts, priority.Critical), "Critical request should be denied when the log is full")
+
+	// Once the window slides, sheddable requests are allowed again
+	ts = ts.Add(2 * time.Second)
+	assert.True(t, limiter.AllowAtPriority(ts, priority.Sheddable))
+}
+
+// TestSlidingWindowLogs_Cost tests that a request costing n requests is logged n times.
+func TestSlidingWindowLogs_Cost(t *testing.T) {
+	limiter := NewSlidingWindowLogs(4, 1000)
+
+	ts, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	assert.True(t, limiter.AllowNAt(ts, 3))
+	assert.False(t, limiter.AllowNAt(ts, 2), "Request should be denied when it doesn't fit in the log")
+	assert.True(t, limiter.AllowNAt(ts, 1))
+	assert.False(t, limiter.AllowAt(ts), "Log should be full")
+
+	// All the logged requests slide out together
+	assert.True(t, limiter.AllowNAt(ts.Add(2*time.Second), 4))
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
+)
+
+// ParseSpec parses an engine configuration written as "<engine-type>[:key=value,...]",
+// e.g. "token-bucket:capacity=10,fill-duration=100". Keys are named after the flags of the run command,
+// durations are in milliseconds. Only the given values are returned, so the options are meant
+// to be appended to a base configuration.
+func ParseSpec(spec string) ([]Option, error) {
+	typeName, params, _ := strings.Cut(strings.TrimSpace(spec), ":")
+
+	engineType := StringToEngineType(strings.TrimSpace(typeName))
+	if engineType == "" {
+		return nil, fmt.Errorf("invalid engine type %q", typeName)
+	}
+	opts := []Option{WithEngineType(engineType)}
+
+	for _, pair := range strings.Split(params, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid engine parameter %q, must be key=value", pair)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		opt, err := parseSpecParam(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid engine parameter %q: %w", key, err)
+		}
+		opts = append(opts, opt)
+	}
+
+	return opts, nil
+}
+
+// SpecName returns the name given to an engine configuration, or the configuration itself if it has no name
+func SpecName(spec string) string {
+	_, params, _ := strings.Cut(spec, ":")
+	for _, pair := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(pair, "=")
+		if strings.TrimSpace(key) == "name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return strings.TrimSpace(spec)
+}
+
+func parseSpecParam(key string, value string) (Option, error) {
+	switch key {
+	case "name":
+		return WithName(value), nil
+	case "capacity":
+		capacity, err := parsePositiveUint(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return WithCapacity(capacity), nil
+	case "fill-duration":
+		fillDuration, err := parsePositiveFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return WithFillRate(1.0 / fillDuration), nil
+	case "consume-rate":
+		consumeRate, err := parsePositiveFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return WithConsumeRate(consumeRate), nil
+	case "drain-duration":
+		drainDuration, err := parsePositiveFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return WithLeakRate(time.Duration(drainDuration * float64(time.Millisecond))), nil
+	case "window-size":
+		windowSize, err := parsePositiveUint(value, 63)
+		if err != nil {
+			return nil, err
+		}
+		return WithWindowSize(int64(windowSize)), nil
+	case "period":
+		period, err := fixedsizewindow.ParsePeriod(value)
+		if err != nil {
+			return nil, err
+		}
+		return WithPeriod(period), nil
+	case "timezone":
+		location, err := time.LoadLocation(value)
+		if err != nil {
+			return nil, err
+		}
+		return WithLocation(location), nil
+	case "per-key":
+		perKey, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		return WithPerKey(perKey), nil
+	case "dry-run":
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		return WithDryRun(dryRun), nil
+	default:
+		return nil, fmt.Errorf("unknown parameter")
+	}
+}
+
+func parsePositiveFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f <= 0 {
+		return 0, fmt.Errorf("must be greater than 0")
+	}
+	return f, nil
+}
+
+func parsePositiveUint(value string, bitSize int) (uint64, error) {
+	u, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	if u == 0 {
+		return 0, fmt.Errorf("must be greater than 0")
+	}
+	return u, nil
+}
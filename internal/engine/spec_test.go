@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
+)
+
+// TestParseSpec tests parsing of engine configurations.
+func TestParseSpec(t *testing.T) {
+	testCases := []struct {
+		name      string
+		spec      string
+		expected  Config
+		expectErr bool
+	}{
+		{
+			name:     "Engine type only",
+			spec:     "leaky-bucket",
+			expected: Config{EngineType: LeakyBucket},
+		},
+		{
+			name: "Token bucket",
+			spec: "token-bucket:capacity=10, fill-duration=200,consume-rate=2,name=tb",
+			expected: Config{
+				Name:        "tb",
+				EngineType:  TokenBucket,
+				Capacity:    10,
+				FillRate:    1.0 / 200,
+				ConsumeRate: 2,
+			},
+		},
+		{
+			name: "Leaky bucket",
+			spec: "leaky-bucket:drain-duration=250,dry-run=true",
+			expected: Config{
+				EngineType: LeakyBucket,
+				LeakRate:   250 * time.Millisecond,
+				DryRun:     true,
+			},
+		},
+		{
+			name: "Calendar window",
+			spec: "calendar-window:capacity=100,period=month,timezone=UTC",
+			expected: Config{
+				EngineType: CalendarWindow,
+				Capacity:   100,
+				Period:     fixedsizewindow.Month,
+				Location:   time.UTC,
+			},
+		},
+		{
+			name:     "Window size",
+			spec:     "sliding-window-log:window-size=60000",
+			expected: Config{EngineType: SlidingWindowLog, windowSize: 60000},
+		},
+		{name: "Unknown engine", spec: "magic-bucket", expectErr: true},
+		{name: "Unknown parameter", spec: "token-bucket:speed=1", expectErr: true},
+		{name: "Missing value", spec: "token-bucket:capacity", expectErr: true},
+		{name: "Negative value", spec: "token-bucket:capacity=-1", expectErr: true},
+		{name: "Fractional capacity", spec: "token-bucket:capacity=0.5", expectErr: true},
+		{name: "Fractional window size", spec: "fixed-window:window-size=1.5", expectErr: true},
+		{name: "Invalid period", spec: "calendar-window:period=week", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := ParseSpec(tc.spec)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			config := Config{}
+			for _, opt := range opts {
+				opt(&config)
+			}
+			assert.Equal(t, tc.expected, config)
+		})
+	}
+}
+
+// TestSpecName tests the name of an engine configuration.
+func TestSpecName(t *testing.T) {
+	assert.Equal(t, "strict", SpecName("token-bucket:capacity=5,name=strict"))
+	assert.Equal(t, "fixed-window:window-size=1000", SpecName(" fixed-window:window-size=1000 "))
+	assert.Equal(t, "leaky-bucket", SpecName("leaky-bucket"))
+}
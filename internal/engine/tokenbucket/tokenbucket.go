@@ -4,6 +4,9 @@ import (
 	"math"
 	"sync/atomic"
 	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 type state struct {
@@ -15,6 +18,7 @@ type tokenBucket struct {
 	capacity    float64 // Max burst
 	fillRate    float64 // Token fill rate per millisecond
 	consumeRate float64 // Token consume rate per request
+	shares      priority.Shares
 	state       atomic.Pointer[state]
 }
 
@@ -22,6 +26,7 @@ func NewTokenBucket(
 	capacity float64,
 	fillRate float64,
 	consumeRate float64,
+	opts ...engineopt.Option,
 ) *tokenBucket {
 	if consumeRate <= 0 || consumeRate > capacity {
 		panic("consume rate must be > 0 and <= capacity")
@@ -31,38 +36,64 @@ func NewTokenBucket(
 		panic("fill rate must be > 0")
 	}
 
+	o := engineopt.NewOptions(opts...)
 	t := &tokenBucket{
 		capacity:    capacity,
 		fillRate:    fillRate,
 		consumeRate: consumeRate,
+		shares:      o.Shares,
 	}
 	t.state.Store(&state{
 		currToken: capacity,
-		lastTime:  time.Now(),
+		lastTime:  o.StartTime,
 	})
 	return t
 }
 
 func (t *tokenBucket) AllowAt(arriveAt time.Time) bool {
+	return t.AllowAtPriority(arriveAt, priority.Normal)
+}
+
+// AllowAtPriority only allows the request if the tokens left after consuming
+// are not reserved for higher priority classes.
+func (t *tokenBucket) AllowAtPriority(arriveAt time.Time, class priority.Class) bool {
+	return t.allowN(arriveAt, class, 1)
+}
+
+// AllowNAt checks if a request costing n requests is allowed, consuming n times the consume rate
+func (t *tokenBucket) AllowNAt(arriveAt time.Time, n uint64) bool {
+	return t.allowN(arriveAt, priority.Normal, n)
+}
+
+func (t *tokenBucket) allowN(arriveAt time.Time, class priority.Class, n uint64) bool {
+	reserved := t.capacity - t.shares.Limit(t.capacity, class)
+	cost := t.consumeRate * float64(n)
+
 	for {
 		lastState := t.state.Load()
-		elapsed := arriveAt.Sub(lastState.lastTime).Milliseconds()
+		elapsed := milliseconds(arriveAt.Sub(lastState.lastTime))
 		newState := &state{
 			lastTime: arriveAt,
 		}
 
-		if elapsed < 0 {
+		if elapsed <= -1 {
 			// A lot of contention results in lots of CAS retries.
 			// This might causes the lastState.lastTime to be in the future of arriveAt.
 			return false
 		}
+		if elapsed < 0 {
+			// Less than a millisecond late, refill nothing and keep the last time
+			// so the refill up to it is not credited twice.
+			elapsed = 0
+			newState.lastTime = lastState.lastTime
+		}
 
 		newState.currToken = math.Min(
 			t.capacity,
-			lastState.currToken+t.fillRate*float64(elapsed),
+			lastState.currToken+t.fillRate*elapsed,
 		)
-		if newState.currToken >= t.consumeRate {
-			newState.currToken -= t.consumeRate
+		if newState.currToken-cost >= reserved {
+			newState.currToken -= cost
 			if t.state.CompareAndSwap(lastState, newState) {
 				return true
 			}
@@ -74,6 +105,26 @@ func (t *tokenBucket) AllowAt(arriveAt time.Time) bool {
 	}
 }
 
+// milliseconds returns d in fractional milliseconds, so the refill of a request
+// arriving mid-millisecond is not lost when the last time moves to it
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
 func (t *tokenBucket) Allow() bool {
 	return t.AllowAt(time.Now())
 }
+
+func (t *tokenBucket) LevelName() string {
+	return "tokens"
+}
+
+// Level returns the tokens left at the given time and the capacity
+func (t *tokenBucket) Level(at time.Time) (float64, float64) {
+	s := t.state.Load()
+	elapsed := milliseconds(at.Sub(s.lastTime))
+	if elapsed < 0 {
+		return s.currToken, t.capacity
+	}
+	return math.Min(t.capacity, s.currToken+t.fillRate*elapsed), t.capacity
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine/engineopt"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
 )
 
 // TestNewTokenBucket tests token bucket constructor.
@@ -126,3 +129,92 @@ func TestTokenBucket_ConsumeRateExceedsCapacity(t *testing.T) {
 		NewTokenBucket(5, 1, 10)
 	}, "Creating a token bucket with consume rate exceeding capacity should panic")
 }
+
+// TestTokenBucket_Priority verifies that lower priorities are rejected first when tokens are low.
+func TestTokenBucket_Priority(t *testing.T) {
+	bucket := NewTokenBucket(10, 1.0/1000, 1, engineopt.WithPriorityShares(priority.DefaultShares)) // 30% reserved for normal/critical
+
+	bucket.state.Store(&state{currToken: bucket.capacity, lastTime: time.Unix(0, 0).UTC()})
+	ts := time.Unix(0, 0).UTC()
+
+	// Sheddable requests can only use 7 tokens
+	for i := 0; i < 7; i++ {
+		assert.True(t, bucket.AllowAtPriority(ts, priority.Sheddable), "Sheddable request %d should be allowed", i+1)
+	}
+	assert.False(t, bucket.AllowAtPriority(ts, priority.Sheddable), "Sheddable request should be denied when 30% tokens are left")
+
+	// Normal requests can use 2 more tokens
+	assert.True(t, bucket.AllowAtPriority(ts, priority.Normal))
+	assert.True(t, bucket.AllowAt(ts), "Requests without priority should be normal")
+	assert.False(t, bucket.AllowAtPriority(ts, priority.Normal), "Normal request should be denied when 10% tokens are left")
+
+	// The last token is reserved for critical requests
+	assert.True(t, bucket.AllowAtPriority(ts, priority.Critical))
+	assert.False(t, bucket.AllowAtPriority(ts, priority.Critical), "Critical request should be denied when the bucket is empty")
+}
+
+// TestTokenBucket_StartTime tests that the bucket can start tracking requests from a synthetic time.
+func TestTokenBucket_StartTime(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
+	bucket := NewTokenBucket(1, 1.0/1000, 1, engineopt.WithStartTime(start)) // capacity=1, fillRate=1/s
+
+	assert.True(t, bucket.AllowAt(start), "First request should be allowed at the start time")
+	assert.False(t, bucket.AllowAt(start.Add(500*time.Millisecond)), "Bucket should be empty")
+	assert.True(t, bucket.AllowAt(start.Add(time.Second)), "Token should be refilled after 1s")
+}
+
+// TestTokenBucket_SubMillisecond tests that the fractions of a millisecond between requests are refilled.
+func TestTokenBucket_SubMillisecond(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	bucket := NewTokenBucket(10, 0.5, 1, engineopt.WithStartTime(start)) // capacity=10, fillRate=1 token/2ms
+
+	allowed := 0
+	for at := start; at.Before(start.Add(time.Second)); at = at.Add(900 * time.Microsecond) {
+		if bucket.AllowAt(at) {
+			allowed++
+		}
+	}
+	assert.InDelta(t, 510, allowed, 1, "The burst and a request every 2ms should be allowed")
+}
+
+// TestTokenBucket_Late tests that a request less than a millisecond before the last one is not denied, nor refilled.
+func TestTokenBucket_Late(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	bucket := NewTokenBucket(2, 0.5, 1, engineopt.WithStartTime(start)) // capacity=2, fillRate=1 token/2ms
+
+	assert.True(t, bucket.AllowAt(start.Add(10*time.Millisecond)))
+	assert.True(t, bucket.AllowAt(start.Add(9500*time.Microsecond)), "Requests less than a millisecond late should not be denied")
+	assert.False(t, bucket.AllowAt(start.Add(11600*time.Microsecond)), "The refill up to the last time should not be credited twice")
+	assert.True(t, bucket.AllowAt(start.Add(12*time.Millisecond)))
+}
+
+// TestTokenBucket_Cost tests that a request costing n requests consumes n times the consume rate.
+func TestTokenBucket_Cost(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	bucket := NewTokenBucket(10, 1.0/1000, 2, engineopt.WithStartTime(start)) // capacity=10, fillRate=1/s, consumeRate=2
+
+	assert.True(t, bucket.AllowNAt(start, 3), "Request costing 6 tokens should be allowed")
+	assert.False(t, bucket.AllowNAt(start, 3), "Request costing 6 tokens should be denied when 4 tokens are left")
+	assert.True(t, bucket.AllowNAt(start, 2), "Request costing 4 tokens should be allowed")
+	assert.False(t, bucket.AllowAt(start), "Bucket should be empty")
+}
+
+// TestTokenBucket_Level tests that the level is the tokens left at the given time.
+func TestTokenBucket_Level(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	bucket := NewTokenBucket(4, 1.0/1000, 1, engineopt.WithStartTime(start)) // capacity=4, fillRate=1/s
+
+	assert.Equal(t, "tokens", bucket.LevelName())
+	for i := 0; i < 4; i++ {
+		assert.True(t, bucket.AllowAt(start))
+	}
+
+	level, max := bucket.Level(start)
+	assert.Equal(t, 0.0, level)
+	assert.Equal(t, 4.0, max)
+
+	level, _ = bucket.Level(start.Add(2500 * time.Millisecond))
+	assert.InDelta(t, 2.5, level, 1e-9, "Tokens should be refilled without consuming them")
+	level, _ = bucket.Level(start.Add(time.Minute))
+	assert.Equal(t, 4.0, level, "Tokens should not exceed the capacity")
+}
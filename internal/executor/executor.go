@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// ErrClosed is returned by Submit once the executor is shut down, and by the futures of the funcs it dropped
+var ErrClosed = errors.New("executor closed")
+
+// Func is a func run by the executor, its context is done when the context of Submit is done or the executor is closed
+type Func func(ctx context.Context) (any, error)
+
+// task is a submitted func and its future
+type task struct {
+	ctx    context.Context
+	fn     Func
+	future *Future
+}
+
+// Executor runs the submitted funcs with a fixed number of workers, each func waiting for the engine to allow it.
+// The funcs wait in a bounded queue, so Submit blocks when the workers don't keep up instead of starting
+// goroutines without limit. Shutdown stops the workers, no goroutine outlives the executor.
+type Executor struct {
+	engine  engine.Engine
+	config  *engine.Config
+	poll    time.Duration
+	verbose bool
+
+	queue chan *task
+	wg    sync.WaitGroup
+
+	// closing stops the calls to Submit waiting for the queue, ctx the funcs waiting for the engine or running
+	closing   chan struct{}
+	closeOnce sync.Once
+	mutex     sync.RWMutex
+	closed    bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewExecutor creates the engine of the executor and starts its workers. The leaky bucket and the fair queue are
+// rejected: they admit the requests in their queue at once, so the funcs would not wait.
+func NewExecutor(engineOpts []engine.Option, opts ...Option) (*Executor, error) {
+	o := newOptions(opts...)
+	if o.numWorker <= 0 {
+		return nil, fmt.Errorf("number of workers must be greater than 0")
+	}
+	if o.queueSize < 0 {
+		return nil, fmt.Errorf("queue size must not be negative")
+	}
+	if o.poll <= 0 {
+		return nil, fmt.Errorf("poll interval must be greater than 0")
+	}
+
+	config := engine.NewConfig(engineOpts...)
+	if config.EngineType == engine.LeakyBucket || config.EngineType == engine.FairQueue {
+		return nil, fmt.Errorf("%s queues the requests instead of delaying them, it can't throttle an executor", config.EngineType)
+	}
+
+	e, err := engine.EngineFactory(engineOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ex := &Executor{
+		engine:  e,
+		config:  config,
+		poll:    o.poll,
+		verbose: o.verbose,
+		queue:   make(chan *task, o.queueSize),
+		closing: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	// Start workers
+	for i := 1; i <= o.numWorker; i++ {
+		ex.wg.Add(1)
+		go func(id int) {
+			defer func() {
+				ex.wg.Done()
+				if ex.verbose {
+					fmt.Printf("Worker %d stopped\n", id)
+				}
+			}()
+
+			if ex.verbose {
+				fmt.Printf("Worker %d started\n", id)
+			}
+			for t := range ex.queue {
+				ex.run(t)
+			}
+		}(i)
+	}
+	return ex, nil
+}
+
+// Submit queues the func and returns its future. It blocks while the queue is full, and fails if the context is done
+// first or the executor is shut down.
+func (e *Executor) Submit(ctx context.Context, fn Func) (*Future, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.closed {
+		return nil, ErrClosed
+	}
+
+	t := &task{ctx: ctx, fn: fn, future: newFuture()}
+	select {
+	case e.queue <- t:
+		return t.future, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-e.closing:
+		return nil, ErrClosed
+	}
+}
+
+// run waits for the engine to allow the func, then runs it and completes its future
+func (e *Executor) run(t *task) {
+	// The func is stopped by its own context and by the executor
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(e.ctx, cancel)
+	defer stop()
+
+	if err := e.wait(ctx); err != nil {
+		t.future.complete(nil, err)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.future.complete(nil, fmt.Errorf("func panicked: %v", r))
+		}
+	}()
+	value, err := t.fn(ctx)
+	t.future.complete(value, err)
+}
+
+// wait blocks until the engine allows a func, the context is done or the executor is closed, see engine.Wait
+func (e *Executor) wait(ctx context.Context) error {
+	// The context of the func is cancelled by the executor asynchronously, check the executor first
+	if e.ctx.Err() != nil {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := engine.Wait(ctx, e.engine, e.config, "", e.poll)
+	if err != nil && e.ctx.Err() != nil {
+		return ErrClosed
+	}
+	return err
+}
+
+// Shutdown stops accepting funcs and waits for the queued ones to run. If the context is done first, the funcs
+// still queued or waiting for the engine fail with ErrClosed, the running ones see their context done, and Shutdown
+// returns the error of the context once the workers stopped.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	e.closeOnce.Do(func() {
+		close(e.closing)
+		e.mutex.Lock()
+		e.closed = true
+		close(e.queue)
+		e.mutex.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		e.cancel()
+		return nil
+	case <-ctx.Done():
+		e.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Close shuts the executor down without waiting for the queued funcs
+func (e *Executor) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = e.Shutdown(ctx)
+	return nil
+}
@@ -0,0 +1,214 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestExecutor returns an executor allowing a func every 10ms, after a burst of 2
+func newTestExecutor(t *testing.T, opts ...Option) *Executor {
+	e, err := NewExecutor([]engine.Option{
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(2),
+		engine.WithFillRate(0.1),
+		engine.WithConsumeRate(1),
+		engine.WithQuiet(true),
+	}, append([]Option{WithPollInterval(5 * time.Millisecond)}, opts...)...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+// TestExecutor_Submit tests that the funcs run at the rate of the engine and their futures hold their results
+func TestExecutor_Submit(t *testing.T) {
+	e := newTestExecutor(t, WithNumWorker(4))
+
+	start := time.Now()
+	var futures []*Future
+	for i := 0; i < 6; i++ {
+		f, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) {
+			return i, nil
+		})
+		assert.NoError(t, err)
+		futures = append(futures, f)
+	}
+	for i, f := range futures {
+		value, err := f.Wait(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond, "4 funcs beyond the burst should take 40ms")
+
+	boom := errors.New("boom")
+	f, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) { return nil, boom })
+	assert.NoError(t, err)
+	_, err = f.Wait(context.Background())
+	assert.ErrorIs(t, err, boom)
+
+	f, err = e.Submit(context.Background(), func(ctx context.Context) (any, error) { panic("boom") })
+	assert.NoError(t, err)
+	_, err = f.Wait(context.Background())
+	assert.ErrorContains(t, err, "panicked", "A panic should fail the future, not the worker")
+}
+
+// TestExecutor_Workers tests that no more funcs than workers run at the same time
+func TestExecutor_Workers(t *testing.T) {
+	e, err := NewExecutor([]engine.Option{
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(100),
+		engine.WithFillRate(1),
+		engine.WithConsumeRate(1),
+		engine.WithQuiet(true),
+	}, WithNumWorker(2))
+	assert.NoError(t, err)
+	defer e.Close()
+
+	var running, peak atomic.Int64
+	var futures []*Future
+	for i := 0; i < 10; i++ {
+		f, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil, nil
+		})
+		assert.NoError(t, err)
+		futures = append(futures, f)
+	}
+	for _, f := range futures {
+		<-f.Done()
+	}
+	assert.Equal(t, int64(2), peak.Load())
+}
+
+// TestExecutor_Queue tests that Submit blocks while the queue is full
+func TestExecutor_Queue(t *testing.T) {
+	e := newTestExecutor(t, WithQueueSize(1))
+
+	release := make(chan struct{})
+	block := func(ctx context.Context) (any, error) {
+		<-release
+		return nil, nil
+	}
+	_, err := e.Submit(context.Background(), block)
+	assert.NoError(t, err)
+	// Wait for the worker to pick the first func, the second one fills the queue
+	time.Sleep(10 * time.Millisecond)
+	_, err = e.Submit(context.Background(), block)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = e.Submit(ctx, block)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Submit should block while the queue is full")
+	close(release)
+}
+
+// TestExecutor_Context tests that a func is not run once its context is done
+func TestExecutor_Context(t *testing.T) {
+	e := newTestExecutor(t)
+
+	// Empty the bucket, the next func waits for 10ms
+	for i := 0; i < 2; i++ {
+		f, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) { return nil, nil })
+		assert.NoError(t, err)
+		<-f.Done()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var ran atomic.Bool
+	f, err := e.Submit(ctx, func(ctx context.Context) (any, error) {
+		ran.Store(true)
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	cancel()
+
+	_, err = f.Wait(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, ran.Load())
+}
+
+// TestExecutor_Shutdown tests that Shutdown runs the queued funcs and stops accepting new ones
+func TestExecutor_Shutdown(t *testing.T) {
+	e := newTestExecutor(t)
+
+	var futures []*Future
+	for i := 0; i < 4; i++ {
+		f, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) { return nil, nil })
+		assert.NoError(t, err)
+		futures = append(futures, f)
+	}
+	assert.NoError(t, e.Shutdown(context.Background()))
+	for _, f := range futures {
+		_, err := f.Wait(context.Background())
+		assert.NoError(t, err)
+	}
+
+	_, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) { return nil, nil })
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+// TestExecutor_ShutdownTimeout tests that the funcs still waiting fail once the context of Shutdown is done,
+// and the running ones are cancelled
+func TestExecutor_ShutdownTimeout(t *testing.T) {
+	e := newTestExecutor(t)
+
+	running := make(chan struct{})
+	first, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) {
+		close(running)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.NoError(t, err)
+	second, err := e.Submit(context.Background(), func(ctx context.Context) (any, error) { return nil, nil })
+	assert.NoError(t, err)
+	<-running
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, e.Shutdown(ctx), context.DeadlineExceeded)
+
+	_, err = first.Wait(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = second.Wait(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+// TestNewExecutor tests that the invalid options and the engines queueing requests are rejected
+func TestNewExecutor(t *testing.T) {
+	tokenBucket := []engine.Option{
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(1),
+		engine.WithFillRate(1),
+		engine.WithConsumeRate(1),
+	}
+	for _, opt := range []Option{WithNumWorker(0), WithQueueSize(-1), WithPollInterval(0)} {
+		_, err := NewExecutor(tokenBucket, opt)
+		assert.Error(t, err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	for _, engineType := range []engine.EngineType{engine.LeakyBucket, engine.FairQueue} {
+		_, err := NewExecutor([]engine.Option{
+			engine.WithEngineType(engineType),
+			engine.WithCapacity(1),
+			engine.WithLeakRate(time.Second),
+			engine.WithStopChannel(stopCh),
+		})
+		assert.Error(t, err, "%s should be rejected", engineType)
+	}
+}
@@ -0,0 +1,37 @@
+package executor
+
+import "context"
+
+// Future is the result of a submitted func, available once the func returned
+type Future struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Done is closed once the result is available
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the result is available and returns it, or returns the error of the context if it is done first.
+// The error of the result is the error of the func, or the reason it didn't run: its context was done, or the
+// executor was closed.
+func (f *Future) Wait(ctx context.Context) (any, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-f.done:
+		return f.value, f.err
+	}
+}
+
+// complete sets the result, it must be called once
+func (f *Future) complete(value any, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+}
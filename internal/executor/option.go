@@ -0,0 +1,53 @@
+package executor
+
+import "time"
+
+type options struct {
+	numWorker int
+	queueSize int
+	poll      time.Duration
+	verbose   bool
+}
+
+type Option func(o *options)
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		numWorker: 1,
+		queueSize: 100,
+		poll:      100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithNumWorker sets the number of funcs run at the same time, default is 1.
+func WithNumWorker(numWorker int) Option {
+	return func(o *options) {
+		o.numWorker = numWorker
+	}
+}
+
+// WithQueueSize sets the number of submitted funcs waiting for a worker, Submit blocks once the queue is full.
+// Default is 100, 0 makes Submit wait for a free worker.
+func WithQueueSize(queueSize int) Option {
+	return func(o *options) {
+		o.queueSize = queueSize
+	}
+}
+
+// WithPollInterval sets the max time between two checks of a func waiting for the engine, default is 100ms.
+func WithPollInterval(poll time.Duration) Option {
+	return func(o *options) {
+		o.poll = poll
+	}
+}
+
+// WithVerbose logs the start and stop of the workers, default is false.
+func WithVerbose(verbose bool) Option {
+	return func(o *options) {
+		o.verbose = verbose
+	}
+}
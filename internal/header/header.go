@@ -0,0 +1,143 @@
+package header
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// epochThreshold separates the reset headers in seconds from the ones in Unix time, like GitHub's X-RateLimit-Reset
+const epochThreshold = 1_000_000_000
+
+// Decision is the decision of an engine on a request, as told to the client: the RateLimit-Policy and RateLimit
+// headers of the IETF draft, the legacy X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers,
+// and Retry-After for a denied request.
+type Decision struct {
+	Policy     Policy
+	Remaining  int64         // Requests left, -1 if unknown
+	Reset      time.Duration // Time until the quota is restored, -1 if unknown
+	RetryAfter time.Duration // Time a denied request should wait, -1 if allowed or unknown
+}
+
+// NewDecision returns the decision of an engine at the given time. The remaining requests are unknown if the engine
+// doesn't expose its level, like the engines with a limit per key, unless the request is denied.
+func NewDecision(name string, e engine.Engine, c *engine.Config, at time.Time, allowed bool) Decision {
+	d := Decision{Policy: NewPolicy(name, c, at), Remaining: -1, Reset: -1, RetryAfter: -1}
+	if remaining, ok := c.Remaining(e, at); ok {
+		d.Remaining, d.Reset = remaining, c.Reset(e, at)
+	}
+
+	if !allowed {
+		d.RetryAfter = c.RetryAfter(at)
+		d.Remaining, d.Reset = 0, max(d.Reset, d.RetryAfter)
+	}
+	return d
+}
+
+// Write sets the headers of the decision, the durations in seconds rounded up. The RateLimit and reset headers
+// are only set if the remaining requests are known.
+func (d Decision) Write(h http.Header) {
+	h.Set("RateLimit-Policy", d.Policy.String())
+	h.Set("X-RateLimit-Limit", strconv.FormatUint(d.Policy.Quota, 10))
+
+	if d.Remaining >= 0 {
+		rateLimit := quote(d.Policy.Name) + ";r=" + strconv.FormatInt(d.Remaining, 10)
+		h.Set("X-RateLimit-Remaining", strconv.FormatInt(d.Remaining, 10))
+		if d.Reset >= 0 {
+			rateLimit += ";t=" + strconv.FormatInt(seconds(d.Reset), 10)
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(seconds(d.Reset), 10))
+		}
+		h.Set("RateLimit", rateLimit)
+	}
+
+	if d.RetryAfter >= 0 {
+		// A client retrying at once would be denied again
+		h.Set("Retry-After", strconv.FormatInt(max(seconds(d.RetryAfter), 1), 10))
+	}
+}
+
+// Parse reads the decision of a server from the headers of its response. The IETF headers are preferred to the
+// RateLimit-* headers of the older drafts, then to the legacy X-RateLimit-* headers. The resets are given in
+// seconds, or in Unix time like GitHub. Unknown values are -1, or a zero quota.
+func Parse(h http.Header, now time.Time) Decision {
+	d := Decision{Remaining: -1, Reset: -1, RetryAfter: -1}
+	if retryAfter, ok := ParseRetryAfter(h.Get("Retry-After"), now); ok {
+		d.RetryAfter = retryAfter
+	}
+
+	if p, ok := ParsePolicy(h.Get("RateLimit-Policy")); ok {
+		d.Policy = p
+	}
+	if name, params, ok := parseItem(h.Get("RateLimit")); ok {
+		if d.Policy.Name == "" {
+			d.Policy.Name = name
+		}
+		if r, ok := params["r"]; ok {
+			d.Remaining = r
+		}
+		if t, ok := params["t"]; ok {
+			d.Reset = time.Duration(t) * time.Second
+		}
+	}
+
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		if d.Policy.Quota == 0 {
+			if limit, err := strconv.ParseUint(strings.TrimSpace(h.Get(prefix+"Limit")), 10, 64); err == nil {
+				d.Policy.Quota = limit
+			}
+		}
+		if d.Remaining < 0 {
+			if remaining, err := strconv.ParseInt(strings.TrimSpace(h.Get(prefix+"Remaining")), 10, 64); err == nil && remaining >= 0 {
+				d.Remaining = remaining
+			}
+		}
+		if d.Reset < 0 {
+			if reset, ok := parseReset(h.Get(prefix+"Reset"), now); ok {
+				d.Reset = reset
+			}
+		}
+	}
+	return d
+}
+
+// Pause returns how long the server asks the client to wait: the Retry-After of a throttled response, or the reset
+// of a throttled response or of a response without remaining requests. False if the server doesn't ask to wait.
+func (d Decision) Pause(throttled bool) (time.Duration, bool) {
+	if throttled && d.RetryAfter >= 0 {
+		return d.RetryAfter, true
+	}
+	if (throttled || d.Remaining == 0) && d.Reset >= 0 {
+		return d.Reset, true
+	}
+	return 0, false
+}
+
+// ParseRetryAfter parses a Retry-After header, given in seconds or as a HTTP date relative to now
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// parseReset parses a reset header, in seconds or in Unix time
+func parseReset(value string, now time.Time) (time.Duration, bool) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	if seconds >= epochThreshold {
+		return max(time.Unix(seconds, 0).Sub(now), 0), true
+	}
+	return time.Duration(seconds) * time.Second, true
+}
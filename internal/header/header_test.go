@@ -0,0 +1,168 @@
+package header
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// TestNewDecision tests the headers of the decisions of an engine
+func TestNewDecision(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := []engine.Option{
+		engine.WithEngineType(engine.FixedWindow), engine.WithCapacity(2), engine.WithWindowSize(60000), engine.WithVirtualTime(at),
+	}
+	config := engine.NewConfig(opts...)
+	e, err := engine.EngineFactory(opts...)
+	assert.NoError(t, err)
+
+	h := http.Header{}
+	NewDecision("api", e, config, at, e.AllowAt(at)).Write(h)
+	assert.Equal(t, `"api";q=2;w=60`, h.Get("RateLimit-Policy"))
+	assert.Equal(t, `"api";r=1;t=60`, h.Get("RateLimit"))
+	assert.Equal(t, "2", h.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", h.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", h.Get("X-RateLimit-Reset"))
+	assert.Empty(t, h.Get("Retry-After"), "Allowed requests should not have a Retry-After")
+
+	assert.True(t, e.AllowAt(at))
+	h = http.Header{}
+	NewDecision("api", e, config, at, e.AllowAt(at)).Write(h)
+	assert.Equal(t, `"api";r=0;t=60`, h.Get("RateLimit"))
+	assert.Equal(t, "60", h.Get("Retry-After"))
+
+	// Keyed engines don't expose their level
+	e, err = engine.EngineFactory(append(opts, engine.WithPerKey(true))...)
+	assert.NoError(t, err)
+	h = http.Header{}
+	NewDecision("api", e, config, at, engine.AllowAtKey(e, at, "a")).Write(h)
+	assert.Equal(t, "2", h.Get("X-RateLimit-Limit"))
+	assert.Empty(t, h.Get("RateLimit"), "Unknown remaining requests should not be published")
+	assert.Empty(t, h.Get("X-RateLimit-Remaining"))
+}
+
+// TestParse tests that the headers written by a decision are parsed back
+func TestParse(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := Decision{Policy: Policy{Name: "api", Quota: 10, Window: time.Minute}, Remaining: 0, Reset: 30 * time.Second, RetryAfter: 5 * time.Second}
+
+	h := http.Header{}
+	d.Write(h)
+	assert.Equal(t, d, Parse(h, now))
+
+	// Legacy headers only
+	h = http.Header{}
+	h.Set("X-RateLimit-Limit", "5000")
+	h.Set("X-RateLimit-Remaining", "4999")
+	h.Set("X-RateLimit-Reset", "1735689720")
+	assert.Equal(t, Decision{Policy: Policy{Quota: 5000}, Remaining: 4999, Reset: 2 * time.Minute, RetryAfter: -1}, Parse(h, now))
+
+	assert.Equal(t, Decision{Remaining: -1, Reset: -1, RetryAfter: -1}, Parse(http.Header{}, now))
+}
+
+// TestDecision_Pause tests the pauses read from the headers of the responses
+func TestDecision_Pause(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		headers   map[string]string
+		throttled bool
+		expected  time.Duration
+		ok        bool
+	}{
+		{
+			name:      "Retry-After in seconds",
+			headers:   map[string]string{"Retry-After": "30"},
+			throttled: true,
+			expected:  30 * time.Second,
+			ok:        true,
+		},
+		{
+			name:      "Retry-After as a HTTP date",
+			headers:   map[string]string{"Retry-After": "Wed, 01 Jan 2025 00:01:00 GMT"},
+			throttled: true,
+			expected:  time.Minute,
+			ok:        true,
+		},
+		{
+			name:      "Retry-After is ignored without a 429",
+			headers:   map[string]string{"Retry-After": "30"},
+			throttled: false,
+		},
+		{
+			name:     "IETF RateLimit header without remaining requests",
+			headers:  map[string]string{"RateLimit": `"default";r=0;t=10`},
+			expected: 10 * time.Second,
+			ok:       true,
+		},
+		{
+			name:    "IETF RateLimit header with remaining requests",
+			headers: map[string]string{"RateLimit": `"default";r=5;t=10`},
+		},
+		{
+			name:      "RateLimit-Reset on a 429",
+			headers:   map[string]string{"RateLimit-Reset": "5"},
+			throttled: true,
+			expected:  5 * time.Second,
+			ok:        true,
+		},
+		{
+			name:     "X-RateLimit-Reset in Unix time without remaining requests",
+			headers:  map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1735689720"},
+			expected: 2 * time.Minute,
+			ok:       true,
+		},
+		{
+			name:    "X-RateLimit-Reset with remaining requests",
+			headers: map[string]string{"X-RateLimit-Remaining": "3", "X-RateLimit-Reset": "60"},
+		},
+		{
+			name:      "429 without headers",
+			throttled: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for name, value := range tc.headers {
+				h.Set(name, value)
+			}
+			d, ok := Parse(h, now).Pause(tc.throttled)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+// TestParseRetryAfter tests the parsing of the Retry-After header
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "120", expected: 2 * time.Minute, ok: true},
+		{value: "0", expected: 0, ok: true},
+		{value: "Wed, 01 Jan 2025 00:00:30 GMT", expected: 30 * time.Second, ok: true},
+		{value: "Tue, 31 Dec 2024 23:59:00 GMT", expected: 0, ok: true},
+		{value: "", ok: false},
+		{value: "-1", ok: false},
+		{value: "soon", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			d, ok := ParseRetryAfter(tc.value, now)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
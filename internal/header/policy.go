@@ -0,0 +1,121 @@
+package header
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// DefaultPolicy is the name of a policy without a name
+const DefaultPolicy = "default"
+
+// Policy is a quota of requests per window, published in the RateLimit-Policy header of the IETF draft,
+// e.g. "default";q=100;w=60
+type Policy struct {
+	Name   string
+	Quota  uint64        // 0 if unknown
+	Window time.Duration // 0 if unknown
+}
+
+// NewPolicy returns the quota of an engine configuration at the given time, see engine.Config.Quota
+func NewPolicy(name string, c *engine.Config, at time.Time) Policy {
+	quota, window := c.Quota(at)
+	return Policy{Name: name, Quota: quota, Window: window}
+}
+
+// String formats the policy as an item of the RateLimit-Policy header, the window in seconds rounded up
+func (p Policy) String() string {
+	s := quote(p.Name) + ";q=" + strconv.FormatUint(p.Quota, 10)
+	if p.Window > 0 {
+		s += ";w=" + strconv.FormatInt(seconds(p.Window), 10)
+	}
+	return s
+}
+
+// ParsePolicy parses the first item of a RateLimit-Policy header
+func ParsePolicy(value string) (Policy, bool) {
+	name, params, ok := parseItem(value)
+	if !ok {
+		return Policy{}, false
+	}
+	quota, ok := params["q"]
+	if !ok {
+		return Policy{}, false
+	}
+	return Policy{Name: name, Quota: uint64(quota), Window: time.Duration(params["w"]) * time.Second}, true
+}
+
+// seconds rounds the duration up to seconds, so a client never retries too early
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// quote formats the name as a string of a structured field
+func quote(name string) string {
+	if name == "" {
+		name = DefaultPolicy
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range name {
+		// Only printable ASCII characters are allowed
+		if c < 0x20 || c > 0x7e {
+			c = '_'
+		}
+		if c == '"' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parseItem parses the first item of a structured field list, e.g. "default";r=0;t=30, made of a string or token
+// name and integer parameters. The parameters that are not integers are ignored.
+func parseItem(value string) (string, map[string]int64, bool) {
+	value = strings.TrimSpace(value)
+
+	var name string
+	if strings.HasPrefix(value, `"`) {
+		var b strings.Builder
+		i := 1
+		for ; i < len(value) && value[i] != '"'; i++ {
+			if value[i] == '\\' && i+1 < len(value) {
+				i++
+			}
+			b.WriteByte(value[i])
+		}
+		if i == len(value) {
+			return "", nil, false
+		}
+		name, value = b.String(), value[i+1:]
+	} else {
+		end := strings.IndexAny(value, ";,")
+		if end < 0 {
+			end = len(value)
+		}
+		name, value = strings.TrimSpace(value[:end]), value[end:]
+	}
+	if name == "" {
+		return "", nil, false
+	}
+
+	item, _, _ := strings.Cut(value, ",")
+	params := map[string]int64{}
+	for _, param := range strings.Split(item, ";") {
+		key, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil || n < 0 {
+			continue
+		}
+		params[strings.TrimSpace(key)] = n
+	}
+	return name, params, true
+}
@@ -0,0 +1,63 @@
+package header
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
+)
+
+// TestNewPolicy tests the policies generated from the engine configurations
+func TestNewPolicy(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		opts     []engine.Option
+		expected string
+	}{
+		{
+			name:     "Fixed window",
+			opts:     []engine.Option{engine.WithEngineType(engine.FixedWindow), engine.WithCapacity(100), engine.WithWindowSize(60000)},
+			expected: `"default";q=100;w=60`,
+		},
+		{
+			name: "Token bucket",
+			opts: []engine.Option{
+				engine.WithEngineType(engine.TokenBucket), engine.WithCapacity(10), engine.WithFillRate(0.01), engine.WithConsumeRate(1),
+			},
+			expected: `"default";q=10;w=1`,
+		},
+		{
+			name:     "Calendar window",
+			opts:     []engine.Option{engine.WithEngineType(engine.CalendarWindow), engine.WithCapacity(1000), engine.WithPeriod(fixedsizewindow.Day)},
+			expected: `"default";q=1000;w=86400`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewPolicy("", engine.NewConfig(tc.opts...), at).String())
+		})
+	}
+}
+
+// TestParsePolicy tests the parsing of the RateLimit-Policy header
+func TestParsePolicy(t *testing.T) {
+	p, ok := ParsePolicy(`"burst";q=100;w=60, "daily";q=1000;w=86400`)
+	assert.True(t, ok)
+	assert.Equal(t, Policy{Name: "burst", Quota: 100, Window: time.Minute}, p, "The first policy should be parsed")
+
+	p, ok = ParsePolicy(`"a \"quoted\", name";q=5`)
+	assert.True(t, ok)
+	assert.Equal(t, Policy{Name: `a "quoted", name`, Quota: 5}, p)
+	assert.Equal(t, `"a \"quoted\", name";q=5`, p.String())
+
+	_, ok = ParsePolicy(`"default";w=60`)
+	assert.False(t, ok, "A policy without quota should be invalid")
+	_, ok = ParsePolicy(`"default;q=5`)
+	assert.False(t, ok, "An unterminated name should be invalid")
+}
@@ -0,0 +1,167 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/header"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+)
+
+// Client is an engine whose decisions are made by a real server: every request of the simulator is sent
+// as a HTTP request, and it is allowed unless the server answers 429 Too Many Requests. Requests failing
+// without a response are denied and counted as errors.
+type Client struct {
+	url       string
+	method    string
+	keyHeader string
+	client    *http.Client
+	ctx       context.Context
+
+	mutex      sync.Mutex
+	statuses   map[int]int64
+	errors     int64
+	retryAfter []time.Duration // Retry-After of the 429 responses that have one
+	latencies  []time.Duration
+}
+
+// NewClient creates a client sending requests to an absolute http or https URL
+func NewClient(target string, opts ...Option) *Client {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		panic(fmt.Sprintf("invalid target URL %q, must be an absolute http or https URL", target))
+	}
+
+	o := newOptions(opts...)
+	client := o.client
+	if client == nil {
+		client = &http.Client{Timeout: o.timeout}
+	}
+
+	ctx := context.Background()
+	if o.stopCh != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		go func() {
+			<-o.stopCh
+			cancel()
+		}()
+	}
+
+	return &Client{
+		url:       target,
+		method:    o.method,
+		keyHeader: o.keyHeader,
+		client:    client,
+		ctx:       ctx,
+		statuses:  map[int]int64{},
+	}
+}
+
+func (c *Client) Allow() bool {
+	return c.AllowAtKey(time.Now(), "")
+}
+
+// AllowAt sends a request without key, the server decides at the time it receives it
+func (c *Client) AllowAt(arriveAt time.Time) bool {
+	return c.AllowAtKey(arriveAt, "")
+}
+
+// AllowAtKey sends a request with the key in the key header, if not empty
+func (c *Client) AllowAtKey(_ time.Time, key string) bool {
+	req, err := http.NewRequestWithContext(c.ctx, c.method, c.url, nil)
+	if err != nil {
+		c.failed()
+		return false
+	}
+	if key != "" && c.keyHeader != "" {
+		req.Header.Set(c.keyHeader, key)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.failed()
+		return false
+	}
+	// Read the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	latency := time.Since(start)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.statuses[resp.StatusCode]++
+	c.latencies = append(c.latencies, latency)
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return true
+	}
+	if retryAfter, ok := header.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		c.retryAfter = append(c.retryAfter, retryAfter)
+	}
+	return false
+}
+
+func (c *Client) failed() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.errors++
+}
+
+// Stats are the responses of the server.
+type Stats struct {
+	Statuses   map[int]int64         `json:"statuses"`
+	Throttled  int64                 `json:"throttled"`   // 429 responses
+	Errors     int64                 `json:"errors"`      // Requests without a response
+	RetryAfter int64                 `json:"retry_after"` // 429 responses with a valid Retry-After header
+	Wait       simulator.Percentiles `json:"wait"`        // Retry-After of the 429 responses
+	Latency    simulator.Percentiles `json:"latency"`     // Response time
+}
+
+// Stats returns the responses received so far
+func (c *Client) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := Stats{
+		Statuses:   map[int]int64{},
+		Throttled:  c.statuses[http.StatusTooManyRequests],
+		Errors:     c.errors,
+		RetryAfter: int64(len(c.retryAfter)),
+		Wait:       simulator.NewPercentiles(c.retryAfter),
+		Latency:    simulator.NewPercentiles(c.latencies),
+	}
+	for status, count := range c.statuses {
+		s.Statuses[status] = count
+	}
+	return s
+}
+
+// Print writes the responses by status code
+func (s Stats) Print(w io.Writer) {
+	statuses := make([]int, 0, len(s.Statuses))
+	for status := range s.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	fmt.Fprintf(w, "\nResponses\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "  %d %s:\t%d\n", status, http.StatusText(status), s.Statuses[status])
+	}
+	fmt.Fprintf(tw, "  Errors:\t%d\n", s.Errors)
+	fmt.Fprintf(tw, "  Latency:\t%v\n", s.Latency)
+	if s.Throttled > 0 {
+		fmt.Fprintf(tw, "  Retry-After:\t%d of %d throttled responses, %v\n", s.RetryAfter, s.Throttled, s.Wait)
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,89 @@
+package loadtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+)
+
+// newLimitedServer returns a server allowing 5 requests per key, then answering 429 with a Retry-After
+func newLimitedServer(t *testing.T) *httptest.Server {
+	limiter, err := engine.EngineFactory(
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(5),
+		engine.WithFillRate(1.0/3600000),
+		engine.WithConsumeRate(1),
+		engine.WithPerKey(true),
+	)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !engine.AllowAtKey(limiter, time.Now(), r.Header.Get("X-Api-Key")) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestClient_LoadTest tests that the decisions of a server are recorded by the simulator
+func TestClient_LoadTest(t *testing.T) {
+	server := newLimitedServer(t)
+	client := NewClient(server.URL, WithKeyHeader("X-Api-Key"))
+
+	sim, err := simulator.NewSimulator(
+		simulator.WithRateLimiter(client),
+		simulator.WithNumWorker(2),
+		simulator.WithNumRequests(8),
+		simulator.WithWaitTime(1),
+		simulator.WithQuiet(true),
+	)
+	assert.NoError(t, err)
+	result := sim.Run()
+
+	s := result.Summary(time.Second)
+	assert.Equal(t, int64(5), s.Allowed, "Requests should be allowed until the server limit")
+	assert.Equal(t, int64(3), s.Denied, "429 responses should be denied")
+
+	stats := client.Stats()
+	assert.Equal(t, map[int]int64{http.StatusOK: 5, http.StatusTooManyRequests: 3}, stats.Statuses)
+	assert.Equal(t, int64(3), stats.Throttled)
+	assert.Equal(t, int64(3), stats.RetryAfter)
+	assert.Equal(t, time.Hour, stats.Wait.Max)
+	assert.Equal(t, int64(0), stats.Errors)
+}
+
+// TestClient_KeyHeader tests that each key gets its own limit on the server
+func TestClient_KeyHeader(t *testing.T) {
+	server := newLimitedServer(t)
+	client := NewClient(server.URL, WithKeyHeader("X-Api-Key"))
+
+	for i := 0; i < 5; i++ {
+		assert.True(t, client.AllowAtKey(time.Now(), "a"))
+	}
+	assert.False(t, client.AllowAtKey(time.Now(), "a"), "Key a should be throttled")
+	assert.True(t, client.AllowAtKey(time.Now(), "b"), "Key b should have its own limit")
+}
+
+// TestClient_Errors tests that requests without a response are denied and counted as errors
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := NewClient(server.URL, WithTimeout(time.Second))
+	assert.False(t, client.Allow())
+	assert.Equal(t, int64(1), client.Stats().Errors)
+
+	assert.Panics(t, func() {
+		NewClient("localhost:8080")
+	}, "Creating a client without scheme should panic")
+}
@@ -0,0 +1,63 @@
+package loadtest
+
+import (
+	"net/http"
+	"time"
+)
+
+type options struct {
+	method    string
+	keyHeader string
+	timeout   time.Duration
+	client    *http.Client
+	stopCh    <-chan struct{}
+}
+
+type Option func(o *options)
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		method:    http.MethodGet,
+		keyHeader: "X-Forwarded-For",
+		timeout:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMethod sets the HTTP method of the requests, default is GET.
+func WithMethod(method string) Option {
+	return func(o *options) {
+		o.method = method
+	}
+}
+
+// WithKeyHeader sets the header carrying the key of a request (tenant, client IP...), default is X-Forwarded-For.
+func WithKeyHeader(header string) Option {
+	return func(o *options) {
+		o.keyHeader = header
+	}
+}
+
+// WithTimeout sets the timeout of a request, default is 5s.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithHTTPClient sends the requests with the given client instead of a new one, the timeout is ignored.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithStopChannel cancels the requests in flight when the channel is closed.
+func WithStopChannel(stopCh <-chan struct{}) Option {
+	return func(o *options) {
+		o.stopCh = stopCh
+	}
+}
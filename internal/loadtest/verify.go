@@ -0,0 +1,68 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+)
+
+// Verification compares the admit rate observed on a server to the admit rate of the expected policy
+// on the same arrivals.
+type Verification struct {
+	Policy          string  `json:"policy"`
+	Observed        float64 `json:"observed"` // Admit rate of the server
+	Expected        float64 `json:"expected"` // Admit rate of the policy
+	ObservedAllowed int64   `json:"observed_allowed"`
+	ExpectedAllowed int64   `json:"expected_allowed"`
+	Tolerance       float64 `json:"tolerance"` // Max difference between the admit rates
+	Passed          bool    `json:"passed"`
+}
+
+// Verify sends the requests of the load test to the engine of the expected policy, which must run
+// in virtual time from the first arrival, and checks the admit rates are within the tolerance.
+func Verify(policy string, e engine.Engine, observed *simulator.Result, tolerance float64) Verification {
+	v := Verification{Policy: policy, Tolerance: tolerance}
+	for _, req := range observed.Requests {
+		if req.Allowed {
+			v.ObservedAllowed++
+		}
+		if allow(e, req) {
+			v.ExpectedAllowed++
+		}
+	}
+
+	if total := len(observed.Requests); total > 0 {
+		v.Observed = float64(v.ObservedAllowed) / float64(total)
+		v.Expected = float64(v.ExpectedAllowed) / float64(total)
+	}
+	v.Passed = math.Abs(v.Observed-v.Expected) <= tolerance
+	return v
+}
+
+// allow checks a request with its key or its priority, like the simulator sent it
+func allow(e engine.Engine, req simulator.Request) bool {
+	if req.Key != "" {
+		return engine.AllowAtKey(e, req.ArriveAt, req.Key)
+	}
+	if req.Class != "" {
+		class, _ := priority.ParseClass(req.Class)
+		return engine.AllowAtPriority(e, req.ArriveAt, class)
+	}
+	return e.AllowAt(req.ArriveAt)
+}
+
+// Print writes the observed and expected admit rates
+func (v Verification) Print(w io.Writer) {
+	result := "PASS"
+	if !v.Passed {
+		result = "FAIL"
+	}
+	fmt.Fprintf(w, "\nExpected policy %s\n", v.Policy)
+	fmt.Fprintf(w, "  Observed: %d allowed (%.1f%%)\n", v.ObservedAllowed, 100*v.Observed)
+	fmt.Fprintf(w, "  Expected: %d allowed (%.1f%%)\n", v.ExpectedAllowed, 100*v.Expected)
+	fmt.Fprintf(w, "  %s: difference %+.1f%%, tolerance %.1f%%\n", result, 100*(v.Observed-v.Expected), 100*v.Tolerance)
+}
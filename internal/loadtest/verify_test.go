@@ -0,0 +1,42 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+)
+
+// TestVerify tests that the observed decisions are compared to the expected policy on the same arrivals
+func TestVerify(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []simulator.Request
+	for i := 0; i < 10; i++ {
+		requests = append(requests, simulator.Request{ArriveAt: start.Add(time.Duration(i) * time.Millisecond), Allowed: i < 6})
+	}
+	observed := &simulator.Result{Requests: requests}
+
+	policy := func() engine.Engine {
+		e, err := engine.EngineFactory(
+			engine.WithEngineType(engine.FixedWindow),
+			engine.WithCapacity(5),
+			engine.WithWindowSize(1000),
+			engine.WithVirtualTime(start),
+		)
+		assert.NoError(t, err)
+		return e
+	}
+
+	v := Verify("fixed-window", policy(), observed, 0.1)
+	assert.Equal(t, int64(6), v.ObservedAllowed)
+	assert.Equal(t, int64(5), v.ExpectedAllowed)
+	assert.InDelta(t, 0.6, v.Observed, 1e-9)
+	assert.InDelta(t, 0.5, v.Expected, 1e-9)
+	assert.True(t, v.Passed, "10% difference should be within the tolerance")
+
+	v = Verify("fixed-window", policy(), observed, 0.05)
+	assert.False(t, v.Passed, "10% difference should be out of the tolerance")
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Check is a HTTP endpoint checking the limits of a request on behalf of a proxy, compatible with Envoy HTTP
+// ext_authz and NGINX auth_request. It answers 200 OK if the request is allowed and 429 Too Many Requests
+// otherwise, with rate limit and Retry-After headers the proxy can copy to its response.
+type Check struct {
+	limits     []*Limit
+	trusted    []*net.IPNet
+	quiet      bool
+	keyHeader  string
+	pathPrefix string
+	denyStatus int
+}
+
+// NewCheck returns a check endpoint of the limits
+func NewCheck(limits []*Limit, opts ...Option) *Check {
+	o := newOptions(opts...)
+	return &Check{
+		limits:     limits,
+		trusted:    o.trusted,
+		quiet:      o.quiet,
+		keyHeader:  o.keyHeader,
+		pathPrefix: o.pathPrefix,
+		denyStatus: o.denyStatus,
+	}
+}
+
+// ServeHTTP checks every limit matching the route of the original request, in order, keyed by the key header
+// or the client IP
+func (c *Check) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method, path := c.original(r)
+	key := c.key(r)
+	d := check(c.limits, time.Now(), path, key)
+	d.writeHeaders(w.Header())
+
+	if d.denied != nil {
+		if !c.quiet {
+			fmt.Printf("Check %s %s from %s DENIED by %s\n", method, path, key, d.denied.Name)
+		}
+		w.WriteHeader(c.denyStatus)
+		return
+	}
+
+	if !c.quiet {
+		fmt.Printf("Check %s %s from %s ALLOWED\n", method, path, key)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// original returns the method and the path of the request checked by the proxy. NGINX sends them in headers
+// set by the configuration, X-Original-Method and X-Original-URI, or X-Forwarded-Method and X-Forwarded-Uri like
+// other proxies. Envoy sends the original request, with the path prefix of the ext_authz filter.
+func (c *Check) original(r *http.Request) (string, string) {
+	method := r.Header.Get("X-Original-Method")
+	if method == "" {
+		method = r.Header.Get("X-Forwarded-Method")
+	}
+	if method == "" {
+		method = r.Method
+	}
+
+	uri := r.Header.Get("X-Original-URI")
+	if uri == "" {
+		uri = r.Header.Get("X-Forwarded-Uri")
+	}
+	if uri != "" {
+		if u, err := url.ParseRequestURI(uri); err == nil {
+			return method, u.Path
+		}
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, c.pathPrefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return method, path
+}
+
+// key returns the key header of the request if set, or the IP of the client of the proxy. X-Forwarded-For,
+// skipping the trusted proxies in front of the proxy, then X-Real-IP are only used when the check request comes
+// from a trusted proxy, like in clientIP, so a client reaching the endpoint can't pick its IP.
+func (c *Check) key(r *http.Request) string {
+	if c.keyHeader != "" {
+		if key := r.Header.Get(c.keyHeader); key != "" {
+			return key
+		}
+	}
+
+	ip := remoteIP(r)
+	if !isTrusted(ip, c.trusted) {
+		return ip
+	}
+	if forwarded := forwardedIP(r, c.trusted); forwarded != "" {
+		return forwarded
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return ip
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+func newTestCheck(t *testing.T, limits []string, opts ...Option) *Check {
+	var parsed []*Limit
+	for _, s := range limits {
+		route, specOpts, err := ParseLimit(s)
+		assert.NoError(t, err)
+		opts := append([]engine.Option{engine.WithFillRate(1.0 / 3600000), engine.WithConsumeRate(1), engine.WithWindowSize(3600000)}, specOpts...)
+		l, err := NewLimit(s, route, opts...)
+		assert.NoError(t, err)
+		parsed = append(parsed, l)
+	}
+	trusted, err := ParseTrustedProxies("127.0.0.1")
+	assert.NoError(t, err)
+	return NewCheck(parsed, append([]Option{WithTrustedProxies(trusted), WithQuiet(true)}, opts...)...)
+}
+
+func checkRequest(c *Check, path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	return w
+}
+
+// TestCheck_Envoy tests a check of Envoy ext_authz, the original request with the path prefix of the filter
+func TestCheck_Envoy(t *testing.T) {
+	c := newTestCheck(t, []string{"ip=token-bucket:capacity=1", "/search=fixed-window:capacity=10"}, WithPathPrefix("/check"))
+
+	w := checkRequest(c, "/check/search?q=a", map[string]string{"X-Forwarded-For": "1.1.1.1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"), "Headers should describe the limit of the client, closest to deny")
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = checkRequest(c, "/check/search", map[string]string{"X-Forwarded-For": "1.1.1.1"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = checkRequest(c, "/check/search", map[string]string{"X-Forwarded-For": "2.2.2.2"})
+	assert.Equal(t, http.StatusOK, w.Code, "Another client should have its own limit")
+}
+
+// TestCheck_NGINX tests a check of NGINX auth_request, the original request in headers, with a deny status NGINX accepts
+func TestCheck_NGINX(t *testing.T) {
+	c := newTestCheck(t, []string{"/login=fixed-window:capacity=1,per-key=true"}, WithDenyStatus(http.StatusForbidden))
+
+	headers := map[string]string{"X-Original-URI": "/login?next=/home", "X-Real-IP": "1.1.1.1"}
+	assert.Equal(t, http.StatusOK, checkRequest(c, "/auth", headers).Code)
+	assert.Equal(t, http.StatusForbidden, checkRequest(c, "/auth", headers).Code)
+
+	headers["X-Original-URI"] = "/home"
+	assert.Equal(t, http.StatusOK, checkRequest(c, "/auth", headers).Code, "Other routes should not be limited")
+
+	headers = map[string]string{"X-Forwarded-Uri": "/login", "X-Real-IP": "2.2.2.2"}
+	assert.Equal(t, http.StatusOK, checkRequest(c, "/auth", headers).Code, "Another client should have its own limit")
+}
+
+// TestCheck_Key tests the keys of the check requests, from the key header, X-Forwarded-For and X-Real-IP
+func TestCheck_Key(t *testing.T) {
+	trusted, err := ParseTrustedProxies("127.0.0.1, 10.0.0.0/8")
+	assert.NoError(t, err)
+	c := newTestCheck(t, nil, WithKeyHeader("X-Api-Key"), WithTrustedProxies(trusted))
+
+	remoteAddr := "127.0.0.1:1234"
+	key := func(headers map[string]string) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		return c.key(r)
+	}
+
+	assert.Equal(t, "alice", key(map[string]string{"X-Api-Key": "alice", "X-Forwarded-For": "1.1.1.1"}))
+	assert.Equal(t, "1.1.1.1", key(map[string]string{"X-Forwarded-For": "9.9.9.9, 1.1.1.1, 10.0.0.2"}),
+		"The trusted proxies in front of the proxy should be skipped")
+	assert.Equal(t, "2.2.2.2", key(map[string]string{"X-Real-IP": "2.2.2.2"}))
+	assert.Equal(t, "127.0.0.1", key(nil))
+
+	// A client reaching the endpoint directly can't pick its IP
+	remoteAddr = "3.3.3.3:1234"
+	assert.Equal(t, "3.3.3.3", key(map[string]string{"X-Forwarded-For": "1.1.1.1"}))
+	assert.Equal(t, "3.3.3.3", key(map[string]string{"X-Real-IP": "2.2.2.2"}))
+	assert.Equal(t, "alice", key(map[string]string{"X-Api-Key": "alice"}))
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// Limit is a limiter applied to all the requests, or to the requests of a route.
+type Limit struct {
+	Name   string        // Used in logs, the scope and engine configuration of the limit
+	Route  string        // Path of the limited requests, with the paths under it, all the requests if empty
+	Engine engine.Engine // Keyed by client IP if per key
+	Config *engine.Config
+}
+
+// NewLimit creates the engine of a limit on the route, on all the requests if the route is empty
+func NewLimit(name string, route string, opts ...engine.Option) (*Limit, error) {
+	e, err := engine.EngineFactory(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Limit{Name: name, Route: route, Engine: e, Config: engine.NewConfig(opts...)}, nil
+}
+
+// ParseLimit parses a limit written as "<scope>=<engine>[:key=value,...]". The scope is "global" for all
+// the requests, "ip" for all the requests with a limit per client IP, or a path prefix like "/api/search".
+// Route limits are shared by all the clients unless the engine configuration has per-key=true.
+// The engine options are returned to be appended to a base configuration.
+func ParseLimit(s string) (route string, opts []engine.Option, err error) {
+	scope, spec, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid limit %q, must be <scope>=<engine>[:key=value,...]", s)
+	}
+
+	opts, err = engine.ParseSpec(spec)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch scope = strings.TrimSpace(scope); {
+	case scope == "global":
+	case scope == "ip":
+		opts = append(opts, engine.WithPerKey(true))
+	case strings.HasPrefix(scope, "/"):
+		route = scope
+	default:
+		return "", nil, fmt.Errorf("invalid limit scope %q, must be global, ip or a path prefix", scope)
+	}
+	return route, opts, nil
+}
+
+// matches returns true if the limit applies to the path: the route itself or a path under it,
+// e.g. "/api/search/users" but not "/api/searches" for "/api/search"
+func (l *Limit) matches(path string) bool {
+	if l.Route == "" {
+		return true
+	}
+	route := strings.TrimSuffix(l.Route, "/")
+	return path == route || strings.HasPrefix(path, route+"/")
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+)
+
+type options struct {
+	trusted []*net.IPNet
+	quiet   bool
+
+	// Check endpoint specific options
+	keyHeader  string
+	pathPrefix string
+	denyStatus int
+}
+
+type Option func(o *options)
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		denyStatus: http.StatusTooManyRequests,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTrustedProxies reads the client IP from the X-Forwarded-For header of the requests coming from these networks.
+func WithTrustedProxies(trusted []*net.IPNet) Option {
+	return func(o *options) {
+		o.trusted = trusted
+	}
+}
+
+// WithQuiet stops logging every request.
+func WithQuiet(quiet bool) Option {
+	return func(o *options) {
+		o.quiet = quiet
+	}
+}
+
+// WithKeyHeader sets the header carrying the key of a request, e.g. an API key. Requests without it are
+// keyed by client IP.
+func WithKeyHeader(header string) Option {
+	return func(o *options) {
+		o.keyHeader = header
+	}
+}
+
+// WithPathPrefix sets the prefix removed from the path of the check requests, the path_prefix of Envoy ext_authz.
+func WithPathPrefix(prefix string) Option {
+	return func(o *options) {
+		o.pathPrefix = prefix
+	}
+}
+
+// WithDenyStatus sets the status code of the denied check requests, default is 429 Too Many Requests.
+func WithDenyStatus(status int) Option {
+	return func(o *options) {
+		o.denyStatus = status
+	}
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/header"
+)
+
+// Proxy is a reverse proxy checking the limits of a request before forwarding it to the upstream.
+// Requests denied by any limit get a 429 Too Many Requests response with a Retry-After header.
+type Proxy struct {
+	limits  []*Limit
+	trusted []*net.IPNet
+	quiet   bool
+	reverse *httputil.ReverseProxy
+}
+
+// NewProxy returns a proxy forwarding the allowed requests to the upstream, it panics if the upstream is not absolute
+func NewProxy(upstream *url.URL, limits []*Limit, opts ...Option) *Proxy {
+	if upstream == nil || upstream.Host == "" {
+		panic("upstream must be an absolute URL")
+	}
+
+	o := newOptions(opts...)
+	return &Proxy{
+		limits:  limits,
+		trusted: o.trusted,
+		quiet:   o.quiet,
+		reverse: httputil.NewSingleHostReverseProxy(upstream),
+	}
+}
+
+// ServeHTTP checks every limit matching the route of the request, in order, with the client IP as key
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip := clientIP(r, p.trusted)
+	d := check(p.limits, time.Now(), r.URL.Path, ip)
+	d.writeHeaders(w.Header())
+
+	if d.denied != nil {
+		if !p.quiet {
+			fmt.Printf("Request %s %s from %s DENIED by %s\n", r.Method, r.URL.Path, ip, d.denied.Name)
+		}
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
+	if !p.quiet {
+		fmt.Printf("Request %s %s from %s ALLOWED\n", r.Method, r.URL.Path, ip)
+	}
+	p.reverse.ServeHTTP(w, r)
+}
+
+// decision is the outcome of the limits of a request
+type decision struct {
+	at        time.Time
+	key       string
+	denied    *Limit // First limit denying the request, nil if allowed
+	closest   *Limit // Limit closest to deny the client, nil if no limit matches
+	remaining int64  // Requests left in the closest limit, -1 if unknown
+}
+
+// check checks every limit matching the path, in order, until one denies the request. The limits whose level
+// is known are looked at first, so that the earlier limits don't count a request denied by a later one.
+func check(limits []*Limit, at time.Time, path string, key string) decision {
+	d := decision{at: at, key: key, remaining: -1}
+
+	var matching []*Limit
+	for _, l := range limits {
+		if !l.matches(path) {
+			continue
+		}
+		matching = append(matching, l)
+
+		// Dry-run limits never deny
+		if remaining, ok := l.Config.Remaining(engine.EngineAtKey(l.Engine, key), at); ok && remaining <= 0 && !l.Config.DryRun {
+			d.denied, d.closest, d.remaining = l, l, 0
+			return d
+		}
+	}
+
+	for _, l := range matching {
+		if d.closest == nil {
+			d.closest = l
+		}
+
+		if !engine.AllowAtKey(l.Engine, at, key) {
+			d.denied, d.closest, d.remaining = l, l, 0
+			break
+		}
+		if remaining, ok := l.Config.Remaining(engine.EngineAtKey(l.Engine, key), at); ok && (d.remaining < 0 || remaining < d.remaining) {
+			d.closest, d.remaining = l, remaining
+		}
+	}
+	return d
+}
+
+// writeHeaders sets the rate limit headers of the closest limit, see header.Decision, with the Retry-After header
+// of a denied request
+func (d decision) writeHeaders(h http.Header) {
+	if d.closest == nil {
+		return
+	}
+
+	e := engine.EngineAtKey(d.closest.Engine, d.key)
+	if e == nil {
+		e = d.closest.Engine
+	}
+	header.NewDecision(d.closest.Name, e, d.closest.Config, d.at, d.denied == nil).Write(h)
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// newTestProxy returns a proxy in front of an upstream answering 200 with the client IP it sees
+func newTestProxy(t *testing.T, limits []string, opts ...Option) *Proxy {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Forwarded-For")))
+	}))
+	t.Cleanup(upstream.Close)
+	u, err := url.Parse(upstream.URL)
+	assert.NoError(t, err)
+
+	var parsed []*Limit
+	for _, s := range limits {
+		route, specOpts, err := ParseLimit(s)
+		assert.NoError(t, err)
+		opts := append([]engine.Option{engine.WithFillRate(1.0 / 3600000), engine.WithConsumeRate(1), engine.WithWindowSize(3600000)}, specOpts...)
+		l, err := NewLimit(s, route, opts...)
+		assert.NoError(t, err)
+		parsed = append(parsed, l)
+	}
+	return NewProxy(u, parsed, append(opts, WithQuiet(true))...)
+}
+
+func get(p *Proxy, path string, remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	return w
+}
+
+// TestProxy_Global tests that a global limit is shared by all the clients, with rate limit headers
+func TestProxy_Global(t *testing.T) {
+	p := newTestProxy(t, []string{"global=token-bucket:capacity=2"})
+
+	w := get(p, "/", "10.0.0.1:1234", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, `"global=token-bucket:capacity=2";q=2;w=7200`, w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, `"global=token-bucket:capacity=2";r=1;t=3600`, w.Header().Get("RateLimit"), "The missing token should take 1h to refill")
+
+	w = get(p, "/", "10.0.0.2:1234", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = get(p, "/", "10.0.0.3:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Global limit should be shared")
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"), "Retry-After should be the time to refill a token")
+}
+
+// TestProxy_PerIP tests that every client IP gets its own limit
+func TestProxy_PerIP(t *testing.T) {
+	p := newTestProxy(t, []string{"ip=fixed-window:capacity=1"})
+
+	assert.Equal(t, http.StatusOK, get(p, "/", "10.0.0.1:1234", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(p, "/", "10.0.0.1:1234", "").Code)
+	assert.Equal(t, http.StatusOK, get(p, "/", "10.0.0.2:1234", "").Code, "Another IP should have its own limit")
+}
+
+// TestProxy_Route tests that route limits only apply to their path prefix, on top of the global limit
+func TestProxy_Route(t *testing.T) {
+	p := newTestProxy(t, []string{"global=fixed-window:capacity=10", "/search=fixed-window:capacity=1"})
+
+	w := get(p, "/search?q=a", "10.0.0.1:1234", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"), "Headers should describe the limit closest to deny")
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusTooManyRequests, get(p, "/search", "10.0.0.2:1234", "").Code)
+	assert.Equal(t, http.StatusOK, get(p, "/home", "10.0.0.2:1234", "").Code, "Other routes should only have the global limit")
+}
+
+// TestProxy_RouteBoundary tests that route limits match whole path segments
+func TestProxy_RouteBoundary(t *testing.T) {
+	p := newTestProxy(t, []string{"/api/search=fixed-window:capacity=1", "/docs/=fixed-window:capacity=1"})
+
+	assert.Equal(t, http.StatusOK, get(p, "/api/search/users", "10.0.0.1:1234", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(p, "/api/search", "10.0.0.1:1234", "").Code, "Route and its sub paths should share the limit")
+	assert.Equal(t, http.StatusOK, get(p, "/api/searches", "10.0.0.1:1234", "").Code, "Paths only sharing a prefix should not match")
+	assert.Equal(t, http.StatusOK, get(p, "/api/searchx/1", "10.0.0.1:1234", "").Code)
+
+	assert.Equal(t, http.StatusOK, get(p, "/docs", "10.0.0.1:1234", "").Code, "Trailing slash of the route should be ignored")
+	assert.Equal(t, http.StatusTooManyRequests, get(p, "/docs/intro", "10.0.0.1:1234", "").Code)
+}
+
+// TestProxy_DeniedByLaterLimit tests that a request denied by a limit isn't counted by the limits before it
+func TestProxy_DeniedByLaterLimit(t *testing.T) {
+	p := newTestProxy(t, []string{"global=fixed-window:capacity=3", "ip=token-bucket:capacity=1"})
+
+	assert.Equal(t, http.StatusOK, get(p, "/", "10.0.0.1:1234", "").Code)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusTooManyRequests, get(p, "/", "10.0.0.1:1234", "").Code)
+	}
+
+	// The global limit only counted the allowed request
+	assert.Equal(t, http.StatusOK, get(p, "/", "10.0.0.2:1234", "").Code)
+	assert.Equal(t, http.StatusOK, get(p, "/", "10.0.0.3:1234", "").Code)
+	w := get(p, "/", "10.0.0.4:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "global=fixed-window:capacity=3", limitName(w.Header().Get("RateLimit-Policy")))
+}
+
+// TestProxy_PerIPHeaders tests that the headers of a limit per client IP describe the limit of the client
+func TestProxy_PerIPHeaders(t *testing.T) {
+	p := newTestProxy(t, []string{"ip=token-bucket:capacity=2"})
+
+	w := get(p, "/", "10.0.0.1:1234", "")
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	w = get(p, "/", "10.0.0.2:1234", "")
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"), "Other clients should not count")
+}
+
+// limitName returns the name of the policy of a RateLimit-Policy header
+func limitName(policy string) string {
+	name, _, _ := strings.Cut(policy, ";")
+	return strings.Trim(name, `"`)
+}
+
+// TestProxy_ForwardedFor tests that X-Forwarded-For is only trusted from trusted proxies
+func TestProxy_ForwardedFor(t *testing.T) {
+	trusted, err := ParseTrustedProxies("127.0.0.1, 10.0.0.0/8")
+	assert.NoError(t, err)
+	p := newTestProxy(t, []string{"ip=fixed-window:capacity=1"}, WithTrustedProxies(trusted))
+
+	// Behind the trusted proxies, the client is the rightmost untrusted address
+	assert.Equal(t, http.StatusOK, get(p, "/", "127.0.0.1:1234", "1.1.1.1, 10.0.0.5").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(p, "/", "10.0.0.7:1234", "9.9.9.9, 1.1.1.1").Code,
+		"Spoofed leftmost address should be ignored")
+	assert.Equal(t, http.StatusOK, get(p, "/", "127.0.0.1:1234", "2.2.2.2").Code)
+
+	// An untrusted client can't pick its IP
+	assert.Equal(t, http.StatusOK, get(p, "/", "3.3.3.3:1234", "4.4.4.4").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(p, "/", "3.3.3.3:1234", "5.5.5.5").Code)
+
+	// The upstream sees the client in X-Forwarded-For
+	w := get(p, "/", "6.6.6.6:1234", "")
+	assert.Equal(t, "6.6.6.6", w.Body.String())
+}
+
+// TestParseLimit tests the parsing of the scope of a limit
+func TestParseLimit(t *testing.T) {
+	route, _, err := ParseLimit("global=token-bucket:capacity=10")
+	assert.NoError(t, err)
+	assert.Equal(t, "", route)
+
+	route, opts, err := ParseLimit("ip=token-bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "", route)
+	assert.True(t, engine.NewConfig(opts...).PerKey, "ip scope should be per key")
+
+	route, _, err = ParseLimit("/api/search=sliding-window-log:capacity=5")
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/search", route)
+
+	_, _, err = ParseLimit("token-bucket")
+	assert.Error(t, err, "Missing scope should fail")
+
+	_, _, err = ParseLimit("host=token-bucket")
+	assert.Error(t, err, "Unknown scope should fail")
+
+	_, _, err = ParseLimit("global=unknown")
+	assert.Error(t, err, "Unknown engine should fail")
+}
+
+// TestParseTrustedProxies tests the parsing of the trusted proxies
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1,::1")
+	assert.NoError(t, err)
+	assert.Len(t, networks, 3)
+	assert.True(t, isTrusted("10.1.2.3", networks))
+	assert.True(t, isTrusted("192.168.1.1", networks))
+	assert.False(t, isTrusted("192.168.1.2", networks))
+	assert.True(t, isTrusted("::1", networks))
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+	_, err = ParseTrustedProxies("localhost")
+	assert.Error(t, err)
+}
+
+// TestNewLimit_Invalid tests that an invalid engine configuration is returned as an error
+func TestNewLimit_Invalid(t *testing.T) {
+	route, opts, err := ParseLimit("global=token-bucket:capacity=5,consume-rate=10")
+	assert.NoError(t, err)
+	_, err = NewLimit("global", route, opts...)
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Server serves the subset of the Redis protocol (RESP) needed by the Redis clients to call CL.THROTTLE, the command
+// of the redis-cell module: CL.THROTTLE, PING, ECHO, SELECT, COMMAND and QUIT. Other commands get an error.
+type Server struct {
+	throttler *Throttler
+	quiet     bool
+
+	mutex    sync.Mutex
+	closed   bool
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func NewServer(opts ...Option) *Server {
+	o := newOptions(opts...)
+	return &Server{
+		throttler: NewThrottler(),
+		quiet:     o.quiet,
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+// Serve accepts connections on the listener until Close is called
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.mutex.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			_ = conn.Close()
+			return ErrServerClosed
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops accepting connections, closes the open ones and waits for their commands
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.conns, conn)
+	_ = conn.Close()
+}
+
+// serveConn runs the commands of a connection until the client quits or sends a malformed command
+func (s *Server) serveConn(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				writeError(w, "ERR "+err.Error())
+				_ = w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.run(w, args)
+		// Reply to pipelined commands at once
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// run runs a command and writes its reply, it returns true if the client quits
+func (s *Server) run(w *bufio.Writer, args []string) bool {
+	switch strings.ToUpper(args[0]) {
+	case "CL.THROTTLE":
+		s.throttle(w, args[1:])
+	case "PING":
+		if len(args) > 1 {
+			writeBulk(w, args[1])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "ECHO":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'echo' command")
+		} else {
+			writeBulk(w, args[1])
+		}
+	case "SELECT":
+		// A single database, selected by the clients with a database in their URL
+		writeSimple(w, "OK")
+	case "COMMAND":
+		// Sent by redis-cli to complete the commands
+		writeIntegers(w, nil)
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+// throttle runs CL.THROTTLE key max_burst count period [quantity]
+func (s *Server) throttle(w *bufio.Writer, args []string) {
+	if len(args) != 4 && len(args) != 5 {
+		writeError(w, "ERR wrong number of arguments for 'cl.throttle' command")
+		return
+	}
+
+	var integers []int64
+	for _, arg := range args[1:] {
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		integers = append(integers, n)
+	}
+	quantity := int64(1)
+	if len(integers) == 4 {
+		quantity = integers[3]
+	}
+	if integers[2] > int64(math.MaxInt64/time.Second) {
+		writeError(w, "ERR value is not an integer or out of range")
+		return
+	}
+
+	key := args[0]
+	limit := Limit{MaxBurst: integers[0], Count: integers[1], Period: time.Duration(integers[2]) * time.Second}
+	reply, err := s.throttler.ThrottleAt(time.Now(), key, limit, quantity)
+	if err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	writeIntegers(w, reply.Reply())
+
+	if !s.quiet {
+		decision := "ALLOWED"
+		if reply.Limited {
+			decision = "LIMITED"
+		}
+		fmt.Printf("Key %s %s, %d of %d remaining\n", key, decision, reply.Remaining, reply.Limit)
+	}
+}
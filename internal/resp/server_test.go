@@ -0,0 +1,152 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestConn starts a server on a local port and returns a connection to it
+func newTestConn(t *testing.T) (net.Conn, *bufio.Reader) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := NewServer(WithQuiet(true))
+	go func() { _ = s.Serve(listener) }()
+	t.Cleanup(func() { _ = s.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, bufio.NewReader(conn)
+}
+
+// command sends a command as an array of bulk strings, like the Redis clients do
+func command(t *testing.T, conn net.Conn, args ...string) {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	_, err := conn.Write([]byte(b.String()))
+	assert.NoError(t, err)
+}
+
+// readReply reads the lines of a reply, the lines of the elements of an array included
+func readReply(t *testing.T, r *bufio.Reader) []string {
+	line, err := readLine(r)
+	assert.NoError(t, err)
+	lines := []string{line}
+	if strings.HasPrefix(line, "*") {
+		n, err := strconv.Atoi(line[1:])
+		assert.NoError(t, err)
+		for i := 0; i < n; i++ {
+			lines = append(lines, readReply(t, r)...)
+		}
+	}
+	if strings.HasPrefix(line, "$") {
+		data, err := readLine(r)
+		assert.NoError(t, err)
+		lines = append(lines, data)
+	}
+	return lines
+}
+
+// TestServer_Throttle tests CL.THROTTLE over a connection
+func TestServer_Throttle(t *testing.T) {
+	conn, r := newTestConn(t)
+
+	command(t, conn, "CL.THROTTLE", "user123", "1", "1", "3600")
+	assert.Equal(t, []string{"*5", ":0", ":2", ":1", ":-1", ":3600"}, readReply(t, r))
+
+	command(t, conn, "cl.throttle", "user123", "1", "1", "3600", "1")
+	assert.Equal(t, []string{"*5", ":0", ":2", ":0", ":-1", ":7200"}, readReply(t, r), "Commands should be case insensitive")
+
+	command(t, conn, "CL.THROTTLE", "user123", "1", "1", "3600")
+	reply := readReply(t, r)
+	assert.Equal(t, ":1", reply[1], "The third request should be limited")
+	assert.Equal(t, ":3600", reply[4])
+
+	command(t, conn, "CL.THROTTLE", "user123", "1", "1")
+	assert.Equal(t, []string{"-ERR wrong number of arguments for 'cl.throttle' command"}, readReply(t, r))
+
+	command(t, conn, "CL.THROTTLE", "user123", "one", "1", "3600")
+	assert.Equal(t, []string{"-ERR value is not an integer or out of range"}, readReply(t, r))
+
+	command(t, conn, "CL.THROTTLE", "user123", "1", "0", "3600")
+	assert.Equal(t, []string{"-ERR count and period must be greater than 0"}, readReply(t, r))
+}
+
+// TestServer_Commands tests the other commands of the clients, pipelined and inline commands
+func TestServer_Commands(t *testing.T) {
+	conn, r := newTestConn(t)
+
+	// Pipelined
+	command(t, conn, "PING")
+	command(t, conn, "PING", "hello")
+	command(t, conn, "SELECT", "0")
+	command(t, conn, "GET", "key")
+	assert.Equal(t, []string{"+PONG"}, readReply(t, r))
+	assert.Equal(t, []string{"$5", "hello"}, readReply(t, r))
+	assert.Equal(t, []string{"+OK"}, readReply(t, r))
+	assert.Equal(t, []string{"-ERR unknown command 'GET'"}, readReply(t, r))
+
+	// Inline, like telnet
+	_, err := conn.Write([]byte("ECHO hi\r\nCL.THROTTLE user 0 1 60\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"$2", "hi"}, readReply(t, r))
+	assert.Equal(t, []string{"*5", ":0", ":1", ":0", ":-1", ":60"}, readReply(t, r))
+
+	command(t, conn, "QUIT")
+	assert.Equal(t, []string{"+OK"}, readReply(t, r))
+	_, err = r.ReadByte()
+	assert.Error(t, err, "The connection should be closed after QUIT")
+}
+
+// TestServer_ProtocolError tests that a malformed command closes the connection
+func TestServer_ProtocolError(t *testing.T) {
+	conn, r := newTestConn(t)
+
+	_, err := conn.Write([]byte("*1\r\n+PING\r\n"))
+	assert.NoError(t, err)
+	reply := readReply(t, r)
+	assert.True(t, strings.HasPrefix(reply[0], "-ERR Protocol error"), reply[0])
+	_, err = r.ReadByte()
+	assert.Error(t, err)
+}
+
+// TestReadLine tests that the lines longer than a bulk string are rejected
+func TestReadLine(t *testing.T) {
+	line, err := readLine(bufio.NewReaderSize(strings.NewReader(strings.Repeat("a", 100)+"\r\n"), 16))
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 100), line, "Lines longer than the buffer should be read whole")
+
+	_, err = readLine(bufio.NewReader(strings.NewReader(strings.Repeat("a", maxBulkLen+1))))
+	assert.ErrorIs(t, err, errProtocol)
+
+	_, err = readLine(bufio.NewReader(strings.NewReader("PING")))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// TestServer_Close tests that Serve returns after Close
+func TestServer_Close(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := NewServer(WithQuiet(true))
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	command(t, conn, "PING")
+	_, err = readLine(bufio.NewReader(conn))
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Close())
+	assert.ErrorIs(t, <-errCh, ErrServerClosed)
+}
@@ -0,0 +1,158 @@
+package resp
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// Limit is the limit of a CL.THROTTLE call: bursts of maxBurst+1 requests, refilled at count requests per period
+type Limit struct {
+	MaxBurst int64
+	Count    int64
+	Period   time.Duration
+}
+
+// Throttle is the reply of CL.THROTTLE
+type Throttle struct {
+	Limited    bool
+	Limit      int64         // Max requests in a burst, max_burst + 1
+	Remaining  int64         // Requests left in the burst
+	RetryAfter time.Duration // Time until the request would be allowed, -1 if allowed or if it never will be
+	ResetAfter time.Duration // Time until the burst is full again
+}
+
+// Reply returns the five integers of the reply of redis-cell, durations are in seconds, rounded up
+func (t Throttle) Reply() []int64 {
+	limited, retryAfter := int64(0), int64(-1)
+	if t.Limited {
+		limited = 1
+	}
+	if t.RetryAfter >= 0 {
+		retryAfter = seconds(t.RetryAfter)
+	}
+	return []int64{limited, t.Limit, t.Remaining, retryAfter, seconds(t.ResetAfter)}
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// bucket is the engine of a key, with the limit it was created for
+type bucket struct {
+	limit  Limit
+	engine engine.Engine
+	config *engine.Config
+}
+
+// evictInterval is how often the full buckets are dropped
+const evictInterval = time.Minute
+
+// Throttler gives every key a token bucket of the limit of its calls. Like the engines per key, buckets are
+// created on the first call of a key, and dropped once they are full again, as a new bucket would behave the same.
+// A call with another limit replaces the bucket of the key.
+type Throttler struct {
+	mutex        sync.Mutex
+	buckets      map[string]*bucket
+	lastEviction time.Time
+}
+
+func NewThrottler() *Throttler {
+	return &Throttler{buckets: map[string]*bucket{}}
+}
+
+// ThrottleAt checks a request costing quantity requests of the key at the given time. A quantity of 0 only
+// returns the state of the limit.
+func (t *Throttler) ThrottleAt(at time.Time, key string, limit Limit, quantity int64) (Throttle, error) {
+	if limit.MaxBurst < 0 {
+		return Throttle{}, fmt.Errorf("max_burst must not be negative")
+	}
+	if limit.Count <= 0 || limit.Period <= 0 {
+		return Throttle{}, fmt.Errorf("count and period must be greater than 0")
+	}
+	if quantity < 0 {
+		return Throttle{}, fmt.Errorf("quantity must not be negative")
+	}
+
+	// Buckets aren't dropped while a request is checked
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.evictFull(at)
+	b, err := t.bucket(at, key, limit)
+	if err != nil {
+		return Throttle{}, err
+	}
+	level, ok := b.engine.(engine.LevelEngine)
+	if !ok {
+		return Throttle{}, fmt.Errorf("engine of the key doesn't expose its tokens")
+	}
+
+	allowed := engine.AllowNAt(b.engine, at, uint64(quantity))
+
+	tokens, capacity := level.Level(at)
+	tokensPerMs := b.config.FillRate
+	reply := Throttle{
+		Limited:    !allowed,
+		Limit:      int64(capacity),
+		Remaining:  int64(math.Floor(tokens)),
+		RetryAfter: -1,
+		ResetAfter: time.Duration((capacity - tokens) / tokensPerMs * float64(time.Millisecond)),
+	}
+	// A request costing more than a burst is never allowed
+	if !allowed && float64(quantity) <= capacity {
+		reply.RetryAfter = time.Duration((float64(quantity) - tokens) / tokensPerMs * float64(time.Millisecond))
+	}
+	return reply, nil
+}
+
+// bucket returns the bucket of the key, creating it if needed or if the limit changed.
+// Caller must hold the mutex.
+func (t *Throttler) bucket(at time.Time, key string, limit Limit) (*bucket, error) {
+	if b, ok := t.buckets[key]; ok && b.limit == limit {
+		return b, nil
+	}
+
+	opts := []engine.Option{
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(uint64(limit.MaxBurst) + 1),
+		engine.WithFillRate(float64(limit.Count) / float64(limit.Period.Milliseconds())),
+		engine.WithConsumeRate(1),
+		engine.WithVirtualTime(at),
+		engine.WithQuiet(true),
+	}
+	e, err := engine.EngineFactory(opts...)
+	if err != nil {
+		return nil, err
+	}
+	b := &bucket{limit: limit, engine: e, config: engine.NewConfig(opts...)}
+	t.buckets[key] = b
+	return b, nil
+}
+
+// evictFull drops the full buckets, at most once every evictInterval.
+// Caller must hold the mutex.
+func (t *Throttler) evictFull(at time.Time) {
+	if at.Sub(t.lastEviction) < evictInterval {
+		return
+	}
+	t.lastEviction = at
+
+	for key, b := range t.buckets {
+		if l, ok := b.engine.(engine.LevelEngine); ok {
+			if tokens, capacity := l.Level(at); tokens >= capacity {
+				delete(t.buckets, key)
+			}
+		}
+	}
+}
+
+// Keys returns the number of keys with a bucket
+func (t *Throttler) Keys() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.buckets)
+}
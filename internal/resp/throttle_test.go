@@ -0,0 +1,94 @@
+package resp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestThrottler tests the replies of a key like redis-cell: a burst of max_burst+1 requests refilled at count per period
+func TestThrottler(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttler := NewThrottler()
+	// Bursts of 3 requests, a request every 2 seconds
+	limit := Limit{MaxBurst: 2, Count: 30, Period: time.Minute}
+
+	reply, err := throttler.ThrottleAt(at, "user", limit, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 3, 2, -1, 2}, reply.Reply())
+
+	reply, _ = throttler.ThrottleAt(at, "user", limit, 2)
+	assert.Equal(t, []int64{0, 3, 0, -1, 6}, reply.Reply(), "A quantity should consume as many requests")
+
+	reply, _ = throttler.ThrottleAt(at.Add(500*time.Millisecond), "user", limit, 1)
+	assert.True(t, reply.Limited)
+	assert.Equal(t, 1500*time.Millisecond, reply.RetryAfter)
+	assert.Equal(t, []int64{1, 3, 0, 2, 6}, reply.Reply(), "Durations should be rounded up to seconds")
+
+	reply, _ = throttler.ThrottleAt(at.Add(2*time.Second), "user", limit, 1)
+	assert.False(t, reply.Limited, "A request should be refilled after 2 seconds")
+
+	reply, _ = throttler.ThrottleAt(at.Add(2*time.Second), "other", limit, 0)
+	assert.Equal(t, []int64{0, 3, 3, -1, 0}, reply.Reply(), "A quantity of 0 should not consume")
+	assert.Equal(t, 2, throttler.Keys())
+}
+
+// TestThrottler_OverBurst tests that a request costing more than a burst is limited without a retry time, like redis-cell
+func TestThrottler_OverBurst(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttler := NewThrottler()
+
+	reply, err := throttler.ThrottleAt(at, "user", Limit{MaxBurst: 2, Count: 30, Period: time.Minute}, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), reply.RetryAfter)
+	assert.Equal(t, []int64{1, 3, 3, -1, 0}, reply.Reply())
+}
+
+// TestThrottler_EvictFull tests that the buckets full again are dropped
+func TestThrottler_EvictFull(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttler := NewThrottler()
+	// A request every 2 seconds
+	limit := Limit{MaxBurst: 0, Count: 30, Period: time.Minute}
+
+	_, _ = throttler.ThrottleAt(at, "user", limit, 1)
+	_, _ = throttler.ThrottleAt(at.Add(evictInterval-time.Second), "other", limit, 1)
+	assert.Equal(t, 2, throttler.Keys())
+
+	_, _ = throttler.ThrottleAt(at.Add(evictInterval), "third", limit, 0)
+	assert.Equal(t, 2, throttler.Keys(), "Only the full bucket of user should be dropped")
+
+	reply, _ := throttler.ThrottleAt(at.Add(evictInterval), "user", limit, 1)
+	assert.False(t, reply.Limited, "A dropped bucket should be full")
+}
+
+// TestThrottler_LimitChange tests that a call with another limit replaces the bucket of the key
+func TestThrottler_LimitChange(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttler := NewThrottler()
+
+	reply, _ := throttler.ThrottleAt(at, "user", Limit{MaxBurst: 0, Count: 1, Period: time.Hour}, 1)
+	assert.False(t, reply.Limited)
+	reply, _ = throttler.ThrottleAt(at, "user", Limit{MaxBurst: 0, Count: 1, Period: time.Hour}, 1)
+	assert.True(t, reply.Limited)
+
+	reply, _ = throttler.ThrottleAt(at, "user", Limit{MaxBurst: 4, Count: 1, Period: time.Hour}, 1)
+	assert.False(t, reply.Limited)
+	assert.Equal(t, int64(5), reply.Limit)
+}
+
+// TestThrottler_Invalid tests the validation of the limits
+func TestThrottler_Invalid(t *testing.T) {
+	throttler := NewThrottler()
+	at := time.Now()
+
+	_, err := throttler.ThrottleAt(at, "user", Limit{MaxBurst: -1, Count: 1, Period: time.Second}, 1)
+	assert.Error(t, err)
+	_, err = throttler.ThrottleAt(at, "user", Limit{MaxBurst: 1, Count: 0, Period: time.Second}, 1)
+	assert.Error(t, err)
+	_, err = throttler.ThrottleAt(at, "user", Limit{MaxBurst: 1, Count: 1, Period: 0}, 1)
+	assert.Error(t, err)
+	_, err = throttler.ThrottleAt(at, "user", Limit{MaxBurst: 1, Count: 1, Period: time.Second}, -1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,232 @@
+package rls
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/fixedsizewindow"
+)
+
+// Policy is the limits of a domain, in the layout of the configuration of the Lyft rate limit service:
+// a tree of descriptors matching the entries of the descriptors sent by Envoy, in order.
+type Policy struct {
+	Domain      string        `yaml:"domain"`
+	Descriptors []*Descriptor `yaml:"descriptors"`
+}
+
+// Descriptor matches an entry of a descriptor by key, and by value if set. A descriptor without a value
+// matches any value, with a limiter per value, e.g. a limit per remote address.
+type Descriptor struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+
+	// Engine configuration of the limit, written like the engine configurations of the compare command,
+	// e.g. "token-bucket:capacity=10,fill-duration=100". Unset parameters are taken from the engine flags.
+	Engine string `yaml:"engine"`
+	// Limit of the Lyft rate limit service, a calendar window aligned to the unit, instead of an engine
+	RateLimit *RateLimit `yaml:"rate_limit"`
+
+	// Descriptors matching the next entry
+	Descriptors []*Descriptor `yaml:"descriptors"`
+
+	limiter *limiter
+}
+
+// RateLimit is a limit of the Lyft rate limit service, e.g. {unit: minute, requests_per_unit: 100}
+type RateLimit struct {
+	Unit            string `yaml:"unit"`
+	RequestsPerUnit uint64 `yaml:"requests_per_unit"`
+}
+
+// LoadFile reads a policy from a YAML file
+func LoadFile(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Load reads and validates a policy
+func Load(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	if p.Domain == "" {
+		return nil, fmt.Errorf("policy must have a domain")
+	}
+	if err := validate(p.Descriptors, ""); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func validate(descriptors []*Descriptor, parent string) error {
+	seen := map[string]bool{}
+	for _, d := range descriptors {
+		name := d.name(parent)
+		if d.Key == "" {
+			return fmt.Errorf("descriptor %s: key is required", name)
+		}
+		if seen[d.Key+"="+d.Value] {
+			return fmt.Errorf("descriptor %s: duplicated", name)
+		}
+		seen[d.Key+"="+d.Value] = true
+
+		if _, err := d.engineOptions(); err != nil {
+			return fmt.Errorf("descriptor %s: %w", name, err)
+		}
+		if err := validate(d.Descriptors, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name writes the path of the descriptor like "path=/login.remote_address"
+func (d *Descriptor) name(parent string) string {
+	name := d.Key
+	if d.Value != "" {
+		name += "=" + d.Value
+	}
+	if parent != "" {
+		name = parent + "." + name
+	}
+	return name
+}
+
+// engineOptions converts the limit of the descriptor to engine options, nil if it has no limit
+func (d *Descriptor) engineOptions() ([]engine.Option, error) {
+	switch {
+	case d.Engine != "" && d.RateLimit != nil:
+		return nil, fmt.Errorf("engine and rate_limit are exclusive")
+	case d.Engine != "":
+		return engine.ParseSpec(d.Engine)
+	case d.RateLimit != nil:
+		return d.RateLimit.engineOptions()
+	default:
+		return nil, nil
+	}
+}
+
+// engineOptions converts the limit to a calendar window of the unit, aligned to the clock like the Lyft windows
+func (l *RateLimit) engineOptions() ([]engine.Option, error) {
+	if l.RequestsPerUnit == 0 {
+		return nil, fmt.Errorf("requests_per_unit must be greater than 0")
+	}
+
+	period, err := fixedsizewindow.ParsePeriod(strings.ToLower(l.Unit))
+	if err != nil {
+		return nil, fmt.Errorf("invalid unit %q, must be one of second, minute, hour, day, month", l.Unit)
+	}
+	return []engine.Option{
+		engine.WithCapacity(l.RequestsPerUnit),
+		engine.WithEngineType(engine.CalendarWindow),
+		engine.WithPeriod(period),
+	}, nil
+}
+
+// compile creates the limiters of the descriptors, on top of the base engine options
+func compile(descriptors []*Descriptor, parent string, base []engine.Option) error {
+	for _, d := range descriptors {
+		name := d.name(parent)
+		opts, err := d.engineOptions()
+		if err != nil {
+			return fmt.Errorf("descriptor %s: %w", name, err)
+		}
+		if opts != nil {
+			d.limiter, err = newLimiter(name, append(append([]engine.Option{}, base...), opts...))
+			if err != nil {
+				return fmt.Errorf("descriptor %s: %w", name, err)
+			}
+		}
+		if err := compile(d.Descriptors, name, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match returns the descriptor matching all the entries, in order, preferring the descriptors with the
+// value of the entry to the ones matching any value. Nil if an entry doesn't match.
+func match(descriptors []*Descriptor, entries []Entry) *Descriptor {
+	var matched *Descriptor
+	for _, entry := range entries {
+		next := (*Descriptor)(nil)
+		for _, d := range descriptors {
+			if d.Key != entry.Key {
+				continue
+			}
+			if d.Value == entry.Value {
+				next = d
+				break
+			}
+			if d.Value == "" {
+				next = d
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		matched, descriptors = next, next.Descriptors
+	}
+	return matched
+}
+
+// Entry is an entry of a descriptor sent by Envoy
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// limiter is the engine of a descriptor, with a limit per value of the entries matching it
+// (see engine.WithPerKey), except the fair queue, which already has a queue per value.
+type limiter struct {
+	name   string
+	engine engine.Engine
+	config *engine.Config
+}
+
+func newLimiter(name string, opts []engine.Option) (*limiter, error) {
+	config := engine.NewConfig(opts...)
+	if config.EngineType != engine.FairQueue {
+		opts = append(opts, engine.WithPerKey(true))
+	}
+
+	e, err := engine.EngineFactory(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &limiter{name: name, engine: e, config: config}, nil
+}
+
+// check checks a request costing hits requests against the limit of the key, and returns the requests
+// left if the engine exposes them, and how long until the limit of the key is back to its full quota
+func (l *limiter) check(at time.Time, key string, hits uint64) (allowed bool, remaining int64, reset time.Duration) {
+	allowed = engine.AllowNAtKey(l.engine, at, key, hits)
+
+	e := engine.EngineAtKey(l.engine, key)
+	if e == nil {
+		e = l.engine
+	}
+	if allowed {
+		remaining, _ = l.config.Remaining(e, at)
+	}
+	return allowed, remaining, l.config.Reset(e, at)
+}
@@ -0,0 +1,152 @@
+package rls
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	rlspb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// Server implements the Envoy rate limit service, envoy.service.ratelimit.v3.RateLimitService.
+// Every descriptor of a request is checked against the limit of the descriptor of the policy it matches,
+// the request is over limit if any descriptor is. Descriptors without a limit and unknown domains are allowed.
+type Server struct {
+	rlspb.UnimplementedRateLimitServiceServer
+
+	policies map[string]*Policy
+	quiet    bool
+}
+
+// NewServer creates the limiters of the policies, on top of the base engine options.
+// An error is returned if two policies have the same domain or a limit is invalid.
+func NewServer(policies []*Policy, base []engine.Option, opts ...Option) (*Server, error) {
+	o := newOptions(opts...)
+	s := &Server{
+		policies: map[string]*Policy{},
+		quiet:    o.quiet,
+	}
+	for _, p := range policies {
+		if _, ok := s.policies[p.Domain]; ok {
+			return nil, fmt.Errorf("domain %q: duplicated", p.Domain)
+		}
+		if err := compile(p.Descriptors, "", base); err != nil {
+			return nil, fmt.Errorf("domain %q: %w", p.Domain, err)
+		}
+		s.policies[p.Domain] = p
+	}
+	return s, nil
+}
+
+func (s *Server) ShouldRateLimit(_ context.Context, req *rlspb.RateLimitRequest) (*rlspb.RateLimitResponse, error) {
+	if req.GetDomain() == "" {
+		return nil, status.Error(codes.InvalidArgument, "domain is required")
+	}
+	if len(req.GetDescriptors()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "descriptors are required")
+	}
+
+	now := time.Now()
+	resp := &rlspb.RateLimitResponse{OverallCode: rlspb.RateLimitResponse_OK}
+	for _, descriptor := range req.GetDescriptors() {
+		entries := make([]Entry, 0, len(descriptor.GetEntries()))
+		for _, e := range descriptor.GetEntries() {
+			entries = append(entries, Entry{Key: e.GetKey(), Value: e.GetValue()})
+		}
+
+		// Envoy counts a request with a hits addend of 0 once
+		hits := uint64(max(req.GetHitsAddend(), 1))
+		if h := descriptor.GetHitsAddend(); h != nil {
+			hits = max(h.GetValue(), 1)
+		}
+
+		ds := s.check(now, req.GetDomain(), entries, hits)
+		if ds.GetCode() == rlspb.RateLimitResponse_OVER_LIMIT {
+			resp.OverallCode = rlspb.RateLimitResponse_OVER_LIMIT
+		}
+		resp.Statuses = append(resp.Statuses, ds)
+
+		if !s.quiet {
+			fmt.Printf("Descriptor %s %s %s\n", req.GetDomain(), describe(entries), ds.GetCode())
+		}
+	}
+	return resp, nil
+}
+
+// check checks a descriptor against the limit it matches, the engine is keyed by the entries of the descriptor
+func (s *Server) check(at time.Time, domain string, entries []Entry, hits uint64) *rlspb.RateLimitResponse_DescriptorStatus {
+	p, ok := s.policies[domain]
+	if !ok {
+		return &rlspb.RateLimitResponse_DescriptorStatus{Code: rlspb.RateLimitResponse_OK}
+	}
+	d := match(p.Descriptors, entries)
+	if d == nil || d.limiter == nil {
+		return &rlspb.RateLimitResponse_DescriptorStatus{Code: rlspb.RateLimitResponse_OK}
+	}
+
+	allowed, remaining, reset := d.limiter.check(at, describe(entries), hits)
+	ds := &rlspb.RateLimitResponse_DescriptorStatus{
+		Code:               rlspb.RateLimitResponse_OK,
+		CurrentLimit:       currentLimit(d.limiter.name, d.limiter.config, at),
+		LimitRemaining:     uint32(min(remaining, math.MaxUint32)),
+		DurationUntilReset: durationpb.New(reset),
+	}
+	if !allowed {
+		ds.Code = rlspb.RateLimitResponse_OVER_LIMIT
+	}
+	return ds
+}
+
+// units of the limits returned to Envoy, from the shortest
+var units = []struct {
+	unit     rlspb.RateLimitResponse_RateLimit_Unit
+	duration time.Duration
+}{
+	{rlspb.RateLimitResponse_RateLimit_SECOND, time.Second},
+	{rlspb.RateLimitResponse_RateLimit_MINUTE, time.Minute},
+	{rlspb.RateLimitResponse_RateLimit_HOUR, time.Hour},
+	{rlspb.RateLimitResponse_RateLimit_DAY, 24 * time.Hour},
+	{rlspb.RateLimitResponse_RateLimit_WEEK, 7 * 24 * time.Hour},
+	{rlspb.RateLimitResponse_RateLimit_YEAR, 365 * 24 * time.Hour},
+}
+
+// currentLimit writes the quota of the engine as requests per unit. Calendar windows use their period, other engines
+// the shortest unit containing their window, e.g. 5 requests per 500ms is 10 requests per second.
+func currentLimit(name string, config *engine.Config, at time.Time) *rlspb.RateLimitResponse_RateLimit {
+	quota, window := config.Quota(at)
+	limit := &rlspb.RateLimitResponse_RateLimit{Name: name, RequestsPerUnit: uint32(min(quota, math.MaxUint32))}
+
+	if config.EngineType == engine.CalendarWindow {
+		limit.Unit = rlspb.RateLimitResponse_RateLimit_Unit(rlspb.RateLimitResponse_RateLimit_Unit_value[strings.ToUpper(string(config.Period))])
+		return limit
+	}
+	if window <= 0 {
+		return limit
+	}
+
+	for _, u := range units {
+		if window <= u.duration || u.unit == rlspb.RateLimitResponse_RateLimit_YEAR {
+			limit.Unit = u.unit
+			perUnit := math.Round(float64(quota) * float64(u.duration) / float64(window))
+			limit.RequestsPerUnit = uint32(min(max(perUnit, 1), math.MaxUint32))
+			break
+		}
+	}
+	return limit
+}
+
+// describe writes the entries of a descriptor like "path=/login,remote_address=10.0.0.1"
+func describe(entries []Entry) string {
+	parts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		parts = append(parts, e.Key+"="+e.Value)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,238 @@
+package rls
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ratelimitpb "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlspb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+const testPolicy = `
+domain: mesh
+descriptors:
+  - key: remote_address
+    engine: token-bucket:capacity=2
+  - key: path
+    value: /login
+    descriptors:
+      - key: remote_address
+        rate_limit:
+          unit: minute
+          requests_per_unit: 1
+  - key: path
+    engine: fixed-window:capacity=3,window-size=500
+`
+
+// testBase are the engine flags of the test servers, a token is refilled every hour
+var testBase = []engine.Option{engine.WithFillRate(1.0 / 3600000), engine.WithConsumeRate(1), engine.WithLocation(time.UTC)}
+
+// newTestClient starts the server of the policy in process and returns a client connected to it
+func newTestClient(t *testing.T, policy string) rlspb.RateLimitServiceClient {
+	p, err := Load(strings.NewReader(policy))
+	assert.NoError(t, err)
+	s, err := NewServer([]*Policy{p}, testBase, WithQuiet(true))
+	assert.NoError(t, err)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	rlspb.RegisterRateLimitServiceServer(server, s)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return rlspb.NewRateLimitServiceClient(conn)
+}
+
+func descriptor(entries ...string) *ratelimitpb.RateLimitDescriptor {
+	d := &ratelimitpb.RateLimitDescriptor{}
+	for i := 0; i+1 < len(entries); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitpb.RateLimitDescriptor_Entry{Key: entries[i], Value: entries[i+1]})
+	}
+	return d
+}
+
+func shouldRateLimit(t *testing.T, client rlspb.RateLimitServiceClient, domain string, descriptors ...*ratelimitpb.RateLimitDescriptor) *rlspb.RateLimitResponse {
+	resp, err := client.ShouldRateLimit(context.Background(), &rlspb.RateLimitRequest{Domain: domain, Descriptors: descriptors})
+	assert.NoError(t, err)
+	return resp
+}
+
+// TestServer_PerValue tests that a descriptor without a value has a limit per value, with the status of the limit
+func TestServer_PerValue(t *testing.T) {
+	client := newTestClient(t, testPolicy)
+
+	resp := shouldRateLimit(t, client, "mesh", descriptor("remote_address", "10.0.0.1"))
+	assert.Equal(t, rlspb.RateLimitResponse_OK, resp.GetOverallCode())
+	assert.Len(t, resp.GetStatuses(), 1)
+	s := resp.GetStatuses()[0]
+	assert.Equal(t, rlspb.RateLimitResponse_OK, s.GetCode())
+	assert.Equal(t, uint32(1), s.GetLimitRemaining())
+	assert.Equal(t, "remote_address", s.GetCurrentLimit().GetName())
+	assert.Equal(t, time.Hour, s.GetDurationUntilReset().AsDuration(), "Reset should be the time to refill a token")
+
+	shouldRateLimit(t, client, "mesh", descriptor("remote_address", "10.0.0.1"))
+	resp = shouldRateLimit(t, client, "mesh", descriptor("remote_address", "10.0.0.1"))
+	assert.Equal(t, rlspb.RateLimitResponse_OVER_LIMIT, resp.GetOverallCode())
+	assert.Equal(t, rlspb.RateLimitResponse_OVER_LIMIT, resp.GetStatuses()[0].GetCode())
+	assert.Equal(t, uint32(0), resp.GetStatuses()[0].GetLimitRemaining())
+	assert.InDelta(t, 2*time.Hour, resp.GetStatuses()[0].GetDurationUntilReset().AsDuration(), float64(time.Second), "Reset should be the time to refill both tokens")
+
+	resp = shouldRateLimit(t, client, "mesh", descriptor("remote_address", "10.0.0.2"))
+	assert.Equal(t, rlspb.RateLimitResponse_OK, resp.GetOverallCode(), "Another value should have its own limit")
+}
+
+// TestServer_Nested tests that the deepest descriptor matching all the entries applies, and that the descriptors
+// with the value of an entry are preferred to the ones matching any value
+func TestServer_Nested(t *testing.T) {
+	client := newTestClient(t, testPolicy)
+
+	login := descriptor("path", "/login", "remote_address", "10.0.0.1")
+	resp := shouldRateLimit(t, client, "mesh", login)
+	assert.Equal(t, rlspb.RateLimitResponse_OK, resp.GetOverallCode())
+	limit := resp.GetStatuses()[0].GetCurrentLimit()
+	assert.Equal(t, "path=/login.remote_address", limit.GetName())
+	assert.Equal(t, rlspb.RateLimitResponse_RateLimit_MINUTE, limit.GetUnit())
+	assert.Equal(t, uint32(1), limit.GetRequestsPerUnit())
+
+	resp = shouldRateLimit(t, client, "mesh", login)
+	assert.Equal(t, rlspb.RateLimitResponse_OVER_LIMIT, resp.GetOverallCode())
+	assert.LessOrEqual(t, resp.GetStatuses()[0].GetDurationUntilReset().AsDuration(), time.Minute)
+
+	// Only path matches the first entry, the remote address doesn't match a descriptor below it
+	resp = shouldRateLimit(t, client, "mesh", descriptor("path", "/search", "remote_address", "10.0.0.1"))
+	assert.Equal(t, rlspb.RateLimitResponse_OK, resp.GetOverallCode())
+	assert.Nil(t, resp.GetStatuses()[0].GetCurrentLimit(), "A partial match should have no limit")
+
+	resp = shouldRateLimit(t, client, "mesh", descriptor("path", "/search"))
+	limit = resp.GetStatuses()[0].GetCurrentLimit()
+	assert.Equal(t, rlspb.RateLimitResponse_RateLimit_SECOND, limit.GetUnit())
+	assert.Equal(t, uint32(6), limit.GetRequestsPerUnit(), "3 requests per 500ms should be 6 per second")
+}
+
+// TestLimiter_Second tests that the Lyft limits of a second are aligned to the second, like the longer units
+func TestLimiter_Second(t *testing.T) {
+	opts, err := (&RateLimit{Unit: "SECOND", RequestsPerUnit: 1}).engineOptions()
+	assert.NoError(t, err)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 900*int(time.Millisecond), time.UTC)
+	l, err := newLimiter("second", append(opts, engine.WithVirtualTime(start)))
+	assert.NoError(t, err)
+
+	allowed, _, reset := l.check(start, "a", 1)
+	assert.True(t, allowed)
+	assert.Equal(t, 100*time.Millisecond, reset, "Window should end at the next second")
+	allowed, _, _ = l.check(start.Add(50*time.Millisecond), "a", 1)
+	assert.False(t, allowed)
+	allowed, _, _ = l.check(start.Add(100*time.Millisecond), "a", 1)
+	assert.True(t, allowed, "Request in the next second should be allowed")
+}
+
+// TestServer_Descriptors tests that a request is over limit if any of its descriptors is
+func TestServer_Descriptors(t *testing.T) {
+	client := newTestClient(t, testPolicy)
+
+	unknown := descriptor("user", "alice")
+	ip := descriptor("remote_address", "10.0.0.1")
+	ip.HitsAddend = wrapperspb.UInt64(2)
+	resp := shouldRateLimit(t, client, "mesh", unknown, ip)
+	assert.Equal(t, rlspb.RateLimitResponse_OK, resp.GetOverallCode())
+	assert.Len(t, resp.GetStatuses(), 2)
+	assert.Nil(t, resp.GetStatuses()[0].GetCurrentLimit(), "Unknown descriptors should be allowed without a limit")
+	assert.Equal(t, uint32(0), resp.GetStatuses()[1].GetLimitRemaining(), "The hits addend should consume 2 tokens")
+
+	resp = shouldRateLimit(t, client, "mesh", unknown, descriptor("remote_address", "10.0.0.1"))
+	assert.Equal(t, rlspb.RateLimitResponse_OVER_LIMIT, resp.GetOverallCode())
+	assert.Equal(t, rlspb.RateLimitResponse_OK, resp.GetStatuses()[0].GetCode())
+	assert.Equal(t, rlspb.RateLimitResponse_OVER_LIMIT, resp.GetStatuses()[1].GetCode())
+
+	resp = shouldRateLimit(t, client, "other", descriptor("remote_address", "10.0.0.1"))
+	assert.Equal(t, rlspb.RateLimitResponse_OK, resp.GetOverallCode(), "Unknown domains should be allowed")
+
+	_, err := client.ShouldRateLimit(context.Background(), &rlspb.RateLimitRequest{Descriptors: []*ratelimitpb.RateLimitDescriptor{ip}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// TestLoad tests the validation of policies
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy string
+		err    string
+	}{
+		{
+			name:   "Missing domain",
+			policy: "descriptors: [{key: a, engine: token-bucket}]",
+			err:    "policy must have a domain",
+		},
+		{
+			name:   "Missing key",
+			policy: "domain: d\ndescriptors: [{value: a, engine: token-bucket}]",
+			err:    "key is required",
+		},
+		{
+			name:   "Duplicated descriptor",
+			policy: "domain: d\ndescriptors: [{key: a}, {key: a}]",
+			err:    "descriptor a: duplicated",
+		},
+		{
+			name:   "Engine and rate limit",
+			policy: "domain: d\ndescriptors: [{key: a, engine: token-bucket, rate_limit: {unit: second, requests_per_unit: 1}}]",
+			err:    "exclusive",
+		},
+		{
+			name:   "Invalid unit",
+			policy: "domain: d\ndescriptors: [{key: a, descriptors: [{key: b, rate_limit: {unit: week, requests_per_unit: 1}}]}]",
+			err:    "descriptor a.b: invalid unit",
+		},
+		{
+			name:   "Unknown field",
+			policy: "domain: d\nlimits: []",
+			err:    "invalid policy",
+		},
+		{
+			name:   "Valid",
+			policy: testPolicy,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(tc.policy))
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
+
+// TestNewServer tests that invalid engines and duplicated domains are rejected
+func TestNewServer(t *testing.T) {
+	p, err := Load(strings.NewReader("domain: d\ndescriptors: [{key: a, engine: leaky-bucket}]"))
+	assert.NoError(t, err)
+	_, err = NewServer([]*Policy{p}, nil)
+	assert.ErrorContains(t, err, "descriptor a")
+
+	p, err = Load(strings.NewReader(testPolicy))
+	assert.NoError(t, err)
+	_, err = NewServer([]*Policy{p, p}, testBase)
+	assert.ErrorContains(t, err, "duplicated")
+}
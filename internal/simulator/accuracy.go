@@ -0,0 +1,91 @@
+package simulator
+
+import (
+	"time"
+)
+
+// Oracle is the ideal limit an engine approximates: at most Capacity requests
+// allowed in any sliding window of size Window.
+type Oracle struct {
+	Capacity int64         `json:"capacity"`
+	Window   time.Duration `json:"window_ns"`
+}
+
+// Accuracy measures how far the decisions of an engine are from the oracle.
+type Accuracy struct {
+	Allowed int64 `json:"allowed"`
+	// Requests an exact limiter would have allowed on the same traffic
+	OracleAllowed int64 `json:"oracle_allowed"`
+	// Requests on which the engine and the exact limiter decided differently
+	Disagreements int64 `json:"disagreements"`
+
+	// Requests allowed while the capacity was already used in the window, i.e. limit violations
+	OverAdmitted int64 `json:"over_admitted"`
+	// Requests denied while the window still had capacity left
+	UnderAdmitted int64 `json:"under_admitted"`
+
+	// Max requests allowed in any sliding window, at most the capacity for an exact limiter
+	PeakAllowed int64 `json:"peak_allowed"`
+	// Relative error of the number of allowed requests compared to the exact limiter
+	AdmitError float64 `json:"admit_error"`
+}
+
+// slidingCount counts the requests allowed in the last window, like a sliding window log
+type slidingCount struct {
+	window  time.Duration
+	allowed []time.Time
+	start   int // First allowed request in the window
+}
+
+// count returns the number of requests allowed in (at-window, at]
+func (s *slidingCount) count(at time.Time) int64 {
+	for s.start < len(s.allowed) && at.Sub(s.allowed[s.start]) >= s.window {
+		s.start++
+	}
+	return int64(len(s.allowed) - s.start)
+}
+
+func (s *slidingCount) add(at time.Time) {
+	s.allowed = append(s.allowed, at)
+}
+
+// Accuracy compares the decisions of the simulation to the oracle.
+// Over and under admissions are judged against the requests the engine itself allowed,
+// the exact limiter is run on the same arrivals to count the disagreements.
+func (r *Result) Accuracy(oracle Oracle) Accuracy {
+	if oracle.Window <= 0 {
+		panic("oracle window must be greater than 0")
+	}
+
+	a := Accuracy{PeakAllowed: r.PeakAllowed(oracle.Window)}
+	engine := &slidingCount{window: oracle.Window}
+	exact := &slidingCount{window: oracle.Window}
+
+	for _, req := range r.Requests {
+		inWindow := engine.count(req.ArriveAt)
+		if req.Allowed {
+			a.Allowed++
+			engine.add(req.ArriveAt)
+			if inWindow >= oracle.Capacity {
+				a.OverAdmitted++
+			}
+		} else if inWindow < oracle.Capacity {
+			a.UnderAdmitted++
+		}
+
+		exactAllowed := exact.count(req.ArriveAt) < oracle.Capacity
+		if exactAllowed {
+			a.OracleAllowed++
+			exact.add(req.ArriveAt)
+		}
+		if exactAllowed != req.Allowed {
+			a.Disagreements++
+		}
+	}
+
+	if a.OracleAllowed > 0 {
+		a.AdmitError = float64(a.Allowed-a.OracleAllowed) / float64(a.OracleAllowed)
+	}
+
+	return a
+}
@@ -0,0 +1,94 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+)
+
+// TestResult_Accuracy tests over and under admissions against the oracle.
+func TestResult_Accuracy(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	oracle := Oracle{Capacity: 2, Window: time.Second}
+
+	testCases := []struct {
+		name      string
+		decisions []decision
+		expected  Accuracy
+	}{
+		{
+			name:      "Exact",
+			decisions: []decision{{0, true}, {100, true}, {200, false}, {1000, true}, {1050, false}, {1100, true}},
+			expected:  Accuracy{Allowed: 4, OracleAllowed: 4, PeakAllowed: 2},
+		},
+		{
+			// The fixed window boundary problem: 2 requests at the end of a window and 2 at the start of the next one
+			name:      "Over-admission",
+			decisions: []decision{{800, true}, {900, true}, {1000, true}, {1100, true}},
+			expected: Accuracy{
+				Allowed: 4, OracleAllowed: 2, Disagreements: 2,
+				OverAdmitted: 2, PeakAllowed: 4, AdmitError: 1,
+			},
+		},
+		{
+			name:      "Under-admission",
+			decisions: []decision{{0, true}, {100, false}, {1500, false}},
+			expected: Accuracy{
+				Allowed: 1, OracleAllowed: 3, Disagreements: 2,
+				UnderAdmitted: 2, PeakAllowed: 1, AdmitError: -2.0 / 3.0,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := newResult(start, map[int64][]decision{1: tc.decisions})
+			assert.Equal(t, tc.expected, result.Accuracy(oracle))
+		})
+	}
+}
+
+// TestAccuracy_Engines tests the accuracy of the engines on the same bursty traffic.
+func TestAccuracy_Engines(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	oracle := Oracle{Capacity: 10, Window: time.Second}
+
+	run := func(engineType engine.EngineType) Accuracy {
+		ratelimiter, err := engine.EngineFactory(
+			engine.WithEngineType(engineType),
+			engine.WithCapacity(10),
+			engine.WithWindowSize(1000),
+			engine.WithVirtualTime(start),
+		)
+		assert.NoError(t, err)
+
+		p, err := profile.New(profile.OnOff, profile.Params{Rate: 50, On: 2 * time.Second, Off: 3 * time.Second})
+		assert.NoError(t, err)
+
+		result := newTestSimulator(t,
+			WithRateLimiter(ratelimiter),
+			WithNumWorker(2),
+			WithNumRequests(2000),
+			WithProfile(p),
+			WithSeed(42),
+			WithVirtualTime(start),
+			WithQuiet(true),
+		).Run()
+		return result.Accuracy(oracle)
+	}
+
+	log := run(engine.SlidingWindowLog)
+	assert.Equal(t, int64(0), log.OverAdmitted, "Sliding window log is exact")
+	assert.LessOrEqual(t, log.PeakAllowed, oracle.Capacity)
+
+	fixed := run(engine.FixedWindow)
+	assert.Greater(t, fixed.OverAdmitted, int64(0), "Fixed window over-admits around window boundaries")
+	assert.Greater(t, fixed.PeakAllowed, oracle.Capacity)
+
+	counter := run(engine.SlidingWindowCounter)
+	assert.Less(t, counter.PeakAllowed, fixed.PeakAllowed, "Sliding window counter should be closer to the oracle than the fixed window")
+}
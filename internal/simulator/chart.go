@@ -0,0 +1,243 @@
+package simulator
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	chartColumns     = 60  // Max columns of the terminal charts
+	chartHeight      = 10  // Rows of the request chart
+	levelChartHeight = 6   // Rows of the level chart
+	htmlColumns      = 200 // Max bars of the HTML charts
+	htmlWidth        = 800
+	htmlHeight       = 200
+)
+
+// merge sums consecutive buckets so there are at most maxColumns of them,
+// the merged bucket keeps the last level observed.
+func (s Summary) merge(maxColumns int) ([]Bucket, time.Duration) {
+	if len(s.Buckets) <= maxColumns {
+		return s.Buckets, s.BucketSize
+	}
+
+	factor := (len(s.Buckets) + maxColumns - 1) / maxColumns
+	merged := []Bucket{}
+	for i, b := range s.Buckets {
+		if i%factor == 0 {
+			merged = append(merged, Bucket{Start: b.Start})
+		}
+		m := &merged[len(merged)-1]
+		m.Allowed += b.Allowed
+		m.Denied += b.Denied
+		if b.Level != nil {
+			m.Level = b.Level
+		}
+	}
+	for i := range merged {
+		merged[i].AdmitRate = admitRate(merged[i].Allowed, merged[i].Allowed+merged[i].Denied)
+	}
+
+	return merged, time.Duration(factor) * s.BucketSize
+}
+
+// PrintChart draws the allowed and denied requests over time and the level of the engine
+// as ASCII charts.
+func (s Summary) PrintChart(w io.Writer) {
+	if len(s.Buckets) == 0 {
+		return
+	}
+	buckets, bucketSize := s.merge(chartColumns)
+
+	peak := int64(0)
+	for _, b := range buckets {
+		peak = max(peak, b.Allowed+b.Denied)
+	}
+
+	fmt.Fprintf(w, "\nRequests every %v (# allowed, x denied)\n", bucketSize)
+	label := len(fmt.Sprint(peak))
+	for row := chartHeight; row > 0; row-- {
+		axis := ""
+		if row == chartHeight {
+			axis = fmt.Sprint(peak)
+		}
+		var line strings.Builder
+		for _, b := range buckets {
+			allowed := scale(float64(b.Allowed), float64(peak), chartHeight)
+			total := scale(float64(b.Allowed+b.Denied), float64(peak), chartHeight)
+			switch {
+			case row <= allowed:
+				line.WriteByte('#')
+			case row <= total:
+				line.WriteByte('x')
+			default:
+				line.WriteByte(' ')
+			}
+		}
+		fmt.Fprintf(w, "  %*s |%s\n", label, axis, strings.TrimRight(line.String(), " "))
+	}
+	printTimeAxis(w, label, len(buckets), s.Duration)
+
+	if s.LevelName == "" || s.LevelMax <= 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%s every %v (max %g)\n", capitalize(s.LevelName), bucketSize, s.LevelMax)
+	label = len(fmt.Sprintf("%g", s.LevelMax))
+	for row := levelChartHeight; row > 0; row-- {
+		axis := ""
+		if row == levelChartHeight {
+			axis = fmt.Sprintf("%g", s.LevelMax)
+		}
+		var line strings.Builder
+		for _, b := range buckets {
+			// The level is unknown in buckets without requests
+			if b.Level != nil && float64(row) <= math.Round(math.Min(*b.Level, s.LevelMax)/s.LevelMax*levelChartHeight) {
+				line.WriteByte('|')
+			} else {
+				line.WriteByte(' ')
+			}
+		}
+		fmt.Fprintf(w, "  %*s |%s\n", label, axis, strings.TrimRight(line.String(), " "))
+	}
+	printTimeAxis(w, label, len(buckets), s.Duration)
+}
+
+// printTimeAxis draws the x axis of a chart, from the start to the end of the simulation
+func printTimeAxis(w io.Writer, label int, columns int, duration time.Duration) {
+	fmt.Fprintf(w, "  %*s +%s\n", label, "0", strings.Repeat("-", columns))
+	end := fmt.Sprintf("+%v", duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "  %*s  %-*s%s\n", label, "", max(columns-len(end), len("+0s")+1), "+0s", end)
+}
+
+// scale returns the number of rows of a value on a chart of the given height,
+// any value above 0 takes at least one row.
+func scale(value float64, maxValue float64, height int) int {
+	if value <= 0 || maxValue <= 0 {
+		return 0
+	}
+	return max(1, int(math.Round(math.Min(value, maxValue)/maxValue*float64(height))))
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// htmlBar is a bucket drawn on the request chart of the HTML report
+type htmlBar struct {
+	X, Width                float64
+	AllowedY, AllowedHeight float64
+	DeniedY, DeniedHeight   float64
+	Title                   string
+}
+
+type htmlReport struct {
+	Summary      Summary
+	AdmitPercent float64
+	BucketSize   time.Duration
+	Peak         int64
+	Bars         []htmlBar
+	LevelPoints  string // Points of the level polyline, empty if the engine has no level
+	LevelName    string
+	Width        int
+	Height       int
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Rate limiter simulation</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td { padding: 2px 12px 2px 0; }
+svg { border: 1px solid #ccc; background: #fafafa; }
+.allowed { fill: #2e9e44; }
+.denied { fill: #d9363e; }
+.level { fill: none; stroke: #1f5fbf; stroke-width: 2; }
+</style>
+</head>
+<body>
+<h1>Rate limiter simulation</h1>
+<table>
+<tr><td>Requests</td><td>{{.Summary.Total}}</td></tr>
+<tr><td>Allowed</td><td>{{.Summary.Allowed}} ({{printf "%.1f%%" .AdmitPercent}})</td></tr>
+<tr><td>Denied</td><td>{{.Summary.Denied}}</td></tr>
+<tr><td>Duration</td><td>{{.Summary.Duration}}</td></tr>
+<tr><td>Throughput</td><td>{{printf "%.2f" .Summary.Throughput}} allowed requests/s</td></tr>
+<tr><td>Longest allowed streak</td><td>{{.Summary.LongestAllowedStreak}} requests</td></tr>
+<tr><td>Longest denial streak</td><td>{{.Summary.LongestDenialStreak}} requests</td></tr>
+</table>
+
+<h2>Requests every {{.BucketSize}}</h2>
+<p>Green: allowed, red: denied, up to {{.Peak}} requests per bucket.</p>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+{{- range .Bars}}
+<g><title>{{.Title}}</title>
+<rect class="allowed" x="{{.X}}" y="{{.AllowedY}}" width="{{.Width}}" height="{{.AllowedHeight}}"/>
+<rect class="denied" x="{{.X}}" y="{{.DeniedY}}" width="{{.Width}}" height="{{.DeniedHeight}}"/>
+</g>
+{{- end}}
+</svg>
+{{- if .LevelPoints}}
+
+<h2>{{.LevelName}} over time</h2>
+<p>Up to {{.Summary.LevelMax}}.</p>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+<polyline class="level" points="{{.LevelPoints}}"/>
+</svg>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes a self-contained HTML report with the summary and the charts as inline SVG
+func (s Summary) WriteHTML(w io.Writer) error {
+	buckets, bucketSize := s.merge(htmlColumns)
+	report := htmlReport{
+		Summary:      s,
+		AdmitPercent: 100 * s.AdmitRate,
+		BucketSize:   bucketSize,
+		Bars:         []htmlBar{},
+		LevelName:    capitalize(s.LevelName),
+		Width:        htmlWidth,
+		Height:       htmlHeight,
+	}
+
+	for _, b := range buckets {
+		report.Peak = max(report.Peak, b.Allowed+b.Denied)
+	}
+
+	barWidth := float64(htmlWidth) / float64(max(len(buckets), 1))
+	var points []string
+	for i, b := range buckets {
+		bar := htmlBar{
+			X:     float64(i) * barWidth,
+			Width: barWidth,
+			Title: fmt.Sprintf("+%v: %d allowed, %d denied", b.Start.Sub(s.Start), b.Allowed, b.Denied),
+		}
+		if report.Peak > 0 {
+			bar.AllowedHeight = float64(htmlHeight) * float64(b.Allowed) / float64(report.Peak)
+			bar.DeniedHeight = float64(htmlHeight) * float64(b.Denied) / float64(report.Peak)
+		}
+		bar.AllowedY = float64(htmlHeight) - bar.AllowedHeight
+		bar.DeniedY = bar.AllowedY - bar.DeniedHeight
+		report.Bars = append(report.Bars, bar)
+
+		if b.Level != nil && s.LevelMax > 0 {
+			y := float64(htmlHeight) * (1 - math.Min(*b.Level, s.LevelMax)/s.LevelMax)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", bar.X+barWidth/2, y))
+		}
+	}
+	report.LevelPoints = strings.Join(points, " ")
+
+	return htmlTemplate.Execute(w, report)
+}
@@ -0,0 +1,139 @@
+package simulator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+func level(l float64) *float64 {
+	return &l
+}
+
+// TestSimulator_Level tests that the level of the engine is recorded with every decision.
+func TestSimulator_Level(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ratelimiter, err := engine.EngineFactory(
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(5),
+		engine.WithFillRate(1.0/1000),
+		engine.WithConsumeRate(1),
+		engine.WithVirtualTime(start),
+	)
+	assert.NoError(t, err)
+
+	result := newTestSimulator(t,
+		WithRateLimiter(ratelimiter),
+		WithNumWorker(1),
+		WithNumRequests(10),
+		WithWaitTime(100),
+		WithVirtualTime(start),
+		WithQuiet(true),
+	).Run()
+
+	assert.Equal(t, "tokens", result.LevelName)
+	assert.Equal(t, 5.0, result.LevelMax)
+	assert.Len(t, result.Requests, 10)
+	for _, req := range result.Requests {
+		assert.GreaterOrEqual(t, req.Level, 0.0)
+		assert.LessOrEqual(t, req.Level, 5.0)
+	}
+	assert.Less(t, result.Requests[4].Level, 1.0, "Burst should consume the tokens")
+
+	summary := result.Summary(500 * time.Millisecond)
+	assert.Equal(t, "tokens", summary.LevelName)
+	assert.Equal(t, result.Requests[4].Level, *summary.Buckets[0].Level, "Bucket should keep the last level")
+}
+
+// TestSummary_PrintChart tests the ASCII charts of the requests and the level.
+func TestSummary_PrintChart(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := Summary{
+		Start:      start,
+		Duration:   4 * time.Second,
+		LevelName:  "tokens",
+		LevelMax:   10,
+		BucketSize: time.Second,
+		Buckets: []Bucket{
+			{Start: start, Allowed: 10, Denied: 0, Level: level(10)},
+			{Start: start.Add(time.Second), Allowed: 5, Denied: 5, Level: level(0)},
+			{Start: start.Add(2 * time.Second), Allowed: 1, Denied: 0, Level: level(5)},
+			{Start: start.Add(3 * time.Second)},
+		},
+	}
+
+	var buf bytes.Buffer
+	s.PrintChart(&buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "Requests every 1s")
+	assert.Contains(t, out, "10 |#x\n", "Busiest bucket should reach the top row")
+	assert.Contains(t, out, "   |##\n", "Denied requests should be stacked on the allowed ones")
+	assert.Contains(t, out, "Tokens every 1s (max 10)")
+	assert.Contains(t, out, "10 ||\n", "Full level should reach the top row")
+	assert.Contains(t, out, "   || |\n", "Empty level should not be drawn")
+	assert.Contains(t, out, "+4s")
+
+	buf.Reset()
+	s.LevelName = ""
+	s.PrintChart(&buf)
+	assert.NotContains(t, buf.String(), "Tokens")
+}
+
+// TestSummary_ChartMerge tests that long simulations are merged into fewer columns.
+func TestSummary_ChartMerge(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := Summary{Start: start, BucketSize: time.Second}
+	for i := 0; i < 150; i++ {
+		s.Buckets = append(s.Buckets, Bucket{Start: start.Add(time.Duration(i) * time.Second), Allowed: 1, Denied: 1})
+		if i%3 != 2 {
+			s.Buckets[i].Level = level(float64(i))
+		}
+	}
+
+	buckets, bucketSize := s.merge(chartColumns)
+	assert.Equal(t, 3*time.Second, bucketSize)
+	assert.Len(t, buckets, 50)
+	assert.Equal(t, int64(3), buckets[0].Allowed)
+	assert.Equal(t, int64(3), buckets[0].Denied)
+	assert.Equal(t, 0.5, buckets[0].AdmitRate)
+	assert.Equal(t, 1.0, *buckets[0].Level, "Merged bucket should keep the last level observed")
+
+	var buf bytes.Buffer
+	s.PrintChart(&buf)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		assert.LessOrEqual(t, len(line), chartColumns+10)
+	}
+}
+
+// TestSummary_WriteHTML tests that the HTML report is self-contained with SVG charts.
+func TestSummary_WriteHTML(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := newResult(start, map[int64][]decision{
+		1: {{0, true}, {200, false}, {1200, true}},
+	})
+	result.LevelName = "queue depth"
+	result.LevelMax = 2
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.Summary(time.Second).WriteHTML(&buf))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, "<!DOCTYPE html>"))
+	assert.Equal(t, 4, strings.Count(out, "<rect "), "2 buckets with an allowed and a denied bar")
+	assert.Contains(t, out, "0s: 1 allowed, 1 denied")
+	assert.Contains(t, out, "Queue depth over time")
+	assert.Contains(t, out, "<polyline")
+	assert.NotContains(t, out, "<script", "Report should not need scripts")
+	assert.NotContains(t, out, "http://", "Report should not load external resources")
+
+	buf.Reset()
+	result.LevelName = ""
+	assert.NoError(t, result.Summary(time.Second).WriteHTML(&buf))
+	assert.NotContains(t, buf.String(), "<polyline")
+}
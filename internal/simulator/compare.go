@@ -0,0 +1,194 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// ComparisonRow is the outcome of one engine in a comparison.
+type ComparisonRow struct {
+	Name                 string  `json:"name"`
+	Total                int64   `json:"total"`
+	Allowed              int64   `json:"allowed"`
+	Denied               int64   `json:"denied"`
+	AdmitRate            float64 `json:"admit_rate"`
+	Throughput           float64 `json:"throughput"`   // Allowed requests/s
+	PeakAllowed          int64   `json:"peak_allowed"` // Max allowed requests in any sliding window
+	LongestAllowedStreak int64   `json:"longest_allowed_streak"`
+	LongestDenialStreak  int64   `json:"longest_denial_streak"`
+
+	Fairness *float64         `json:"fairness,omitempty"` // Set if the traffic has tenants
+	Queueing *QueueingSummary `json:"queueing,omitempty"` // Set if the engine or the service has a queue
+	Accuracy *Accuracy        `json:"accuracy,omitempty"` // Set if the comparison has an oracle
+}
+
+// Comparison compares the results of several engines on the same traffic.
+type Comparison struct {
+	PeakWindow time.Duration   `json:"peak_window_ns"`   // Size of the sliding window of PeakAllowed
+	Oracle     *Oracle         `json:"oracle,omitempty"` // Measure the accuracy of the engines if set
+	Rows       []ComparisonRow `json:"engines"`
+}
+
+func NewComparison(peakWindow time.Duration) *Comparison {
+	if peakWindow <= 0 {
+		panic("peak window must be greater than 0")
+	}
+	return &Comparison{PeakWindow: peakWindow, Rows: []ComparisonRow{}}
+}
+
+// Add adds the result of an engine to the comparison
+func (c *Comparison) Add(name string, r *Result) {
+	s := r.Summary(c.PeakWindow)
+	row := ComparisonRow{
+		Name:                 name,
+		Total:                s.Total,
+		Allowed:              s.Allowed,
+		Denied:               s.Denied,
+		AdmitRate:            s.AdmitRate,
+		Throughput:           s.Throughput,
+		PeakAllowed:          r.PeakAllowed(c.PeakWindow),
+		LongestAllowedStreak: s.LongestAllowedStreak,
+		LongestDenialStreak:  s.LongestDenialStreak,
+	}
+
+	if len(s.Tenants) > 0 {
+		row.Fairness = &s.Fairness
+	}
+	row.Queueing = s.Queueing
+	if c.Oracle != nil {
+		accuracy := r.Accuracy(*c.Oracle)
+		row.Accuracy = &accuracy
+	}
+	c.Rows = append(c.Rows, row)
+}
+
+// Print writes the comparison as a table
+func (c *Comparison) Print(w io.Writer) {
+	fairness := len(c.Rows) > 0 && c.Rows[0].Fairness != nil
+	queueing := c.queueing()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ENGINE\tREQUESTS\tALLOWED\tDENIED\tADMIT RATE\tTHROUGHPUT\tPEAK/%v\tLONGEST BURST\tDENIAL STREAK", c.PeakWindow)
+	if fairness {
+		fmt.Fprintf(tw, "\tFAIRNESS")
+	}
+	if queueing {
+		fmt.Fprintf(tw, "\tQUEUE P50\tQUEUE P99\tMAX QUEUE AGE")
+	}
+	fmt.Fprintln(tw)
+	for _, row := range c.Rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\t%.2f/s\t%d\t%d\t%d",
+			row.Name, row.Total, row.Allowed, row.Denied, 100*row.AdmitRate,
+			row.Throughput, row.PeakAllowed, row.LongestAllowedStreak, row.LongestDenialStreak,
+		)
+		if row.Fairness != nil {
+			fmt.Fprintf(tw, "\t%.3f", *row.Fairness)
+		}
+		if q := row.Queueing; q != nil {
+			fmt.Fprintf(tw, "\t%v\t%v\t%v", q.Delay.P50, q.Delay.P99, q.MaxQueueAge)
+		} else if queueing {
+			fmt.Fprintf(tw, "\t-\t-\t-")
+		}
+		fmt.Fprintln(tw)
+	}
+	_ = tw.Flush()
+
+	if c.Oracle == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "\nAccuracy against at most %d requests in any %v\n", c.Oracle.Capacity, c.Oracle.Window)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ENGINE\tALLOWED\tEXACT\tADMIT ERROR\tOVER-ADMITTED\tUNDER-ADMITTED\tDISAGREEMENTS\tPEAK/%v\n", c.Oracle.Window)
+	for _, row := range c.Rows {
+		a := row.Accuracy
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+.1f%%\t%d\t%d\t%d\t%d\n",
+			row.Name, a.Allowed, a.OracleAllowed, 100*a.AdmitError,
+			a.OverAdmitted, a.UnderAdmitted, a.Disagreements, a.PeakAllowed,
+		)
+	}
+	_ = tw.Flush()
+}
+
+// queueing returns true if any engine has a queue, engines without one are compared with empty columns
+func (c *Comparison) queueing() bool {
+	for _, row := range c.Rows {
+		if row.Queueing != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the comparison as JSON
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// WriteCSV writes one record per engine
+func (c *Comparison) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{
+		"name", "total", "allowed", "denied", "admit_rate", "throughput",
+		"peak_allowed", "longest_allowed_streak", "longest_denial_streak",
+	}
+	fairness := len(c.Rows) > 0 && c.Rows[0].Fairness != nil
+	if fairness {
+		header = append(header, "fairness")
+	}
+	queueing := c.queueing()
+	if queueing {
+		header = append(header, "queue_p50_ns", "queue_p95_ns", "queue_p99_ns", "max_queue_age_ns")
+	}
+	if c.Oracle != nil {
+		header = append(header, "oracle_allowed", "admit_error", "over_admitted", "under_admitted", "disagreements")
+	}
+	_ = writer.Write(header)
+
+	for _, row := range c.Rows {
+		record := []string{
+			row.Name,
+			strconv.FormatInt(row.Total, 10),
+			strconv.FormatInt(row.Allowed, 10),
+			strconv.FormatInt(row.Denied, 10),
+			strconv.FormatFloat(row.AdmitRate, 'f', -1, 64),
+			strconv.FormatFloat(row.Throughput, 'f', -1, 64),
+			strconv.FormatInt(row.PeakAllowed, 10),
+			strconv.FormatInt(row.LongestAllowedStreak, 10),
+			strconv.FormatInt(row.LongestDenialStreak, 10),
+		}
+		if fairness {
+			record = append(record, strconv.FormatFloat(*row.Fairness, 'f', -1, 64))
+		}
+		if q := row.Queueing; q != nil {
+			record = append(record,
+				strconv.FormatInt(int64(q.Delay.P50), 10),
+				strconv.FormatInt(int64(q.Delay.P95), 10),
+				strconv.FormatInt(int64(q.Delay.P99), 10),
+				strconv.FormatInt(int64(q.MaxQueueAge), 10),
+			)
+		} else if queueing {
+			record = append(record, "", "", "", "")
+		}
+		if a := row.Accuracy; a != nil {
+			record = append(record,
+				strconv.FormatInt(a.OracleAllowed, 10),
+				strconv.FormatFloat(a.AdmitError, 'f', -1, 64),
+				strconv.FormatInt(a.OverAdmitted, 10),
+				strconv.FormatInt(a.UnderAdmitted, 10),
+				strconv.FormatInt(a.Disagreements, 10),
+			)
+		}
+		_ = writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,47 @@
+package simulator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestComparison tests that the comparison reports each engine on the same traffic.
+func TestComparison(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	strict := newResult(start, map[int64][]decision{
+		1: {{0, true}, {400, true}, {800, false}, {1200, true}, {1600, false}},
+	})
+	lenient := newResult(start, map[int64][]decision{
+		1: {{0, true}, {400, true}, {800, true}, {1200, true}, {1600, true}},
+	})
+
+	c := NewComparison(time.Second)
+	c.Add("strict", strict)
+	c.Add("lenient", lenient)
+
+	assert.Equal(t, []ComparisonRow{
+		{
+			Name: "strict", Total: 5, Allowed: 3, Denied: 2, AdmitRate: 0.6, Throughput: 3 / 1.6,
+			PeakAllowed: 2, LongestAllowedStreak: 2, LongestDenialStreak: 1,
+		},
+		{
+			Name: "lenient", Total: 5, Allowed: 5, Denied: 0, AdmitRate: 1, Throughput: 5 / 1.6,
+			PeakAllowed: 3, LongestAllowedStreak: 5, LongestDenialStreak: 0,
+		},
+	}, c.Rows)
+
+	var out bytes.Buffer
+	c.Print(&out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "PEAK/1s")
+	assert.True(t, strings.HasPrefix(lines[1], "strict"))
+
+	out.Reset()
+	assert.NoError(t, c.WriteCSV(&out))
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 3, "CSV should have a header and a record per engine")
+}
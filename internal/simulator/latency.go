@@ -0,0 +1,43 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Percentiles are the distribution of a duration over the requests.
+type Percentiles struct {
+	P50 time.Duration `json:"p50_ns"`
+	P95 time.Duration `json:"p95_ns"`
+	P99 time.Duration `json:"p99_ns"`
+	Max time.Duration `json:"max_ns"`
+}
+
+// NewPercentiles returns the nearest-rank percentiles of the durations, zero if there are none
+func NewPercentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(i, 0)]
+	}
+
+	return Percentiles{
+		P50: rank(0.50),
+		P95: rank(0.95),
+		P99: rank(0.99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+func (p Percentiles) String() string {
+	return fmt.Sprintf("p50=%v p95=%v p99=%v max=%v", p.P50, p.P95, p.P99, p.Max)
+}
@@ -0,0 +1,50 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewPercentiles tests the nearest-rank percentiles of durations
+func TestNewPercentiles(t *testing.T) {
+	testCases := []struct {
+		name      string
+		durations []time.Duration
+		expected  Percentiles
+	}{
+		{
+			name:     "No durations",
+			expected: Percentiles{},
+		},
+		{
+			name:      "Single duration",
+			durations: []time.Duration{time.Second},
+			expected:  Percentiles{P50: time.Second, P95: time.Second, P99: time.Second, Max: time.Second},
+		},
+		{
+			name:      "Unsorted durations",
+			durations: []time.Duration{4, 1, 3, 2},
+			expected:  Percentiles{P50: 2, P95: 4, P99: 4, Max: 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewPercentiles(tc.durations))
+		})
+	}
+
+	// 1ms to 100ms
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	p := NewPercentiles(durations)
+	assert.Equal(t, 50*time.Millisecond, p.P50)
+	assert.Equal(t, 95*time.Millisecond, p.P95)
+	assert.Equal(t, 99*time.Millisecond, p.P99)
+	assert.Equal(t, 100*time.Millisecond, p.Max)
+	assert.Equal(t, 100*time.Millisecond, durations[0], "Durations should not be sorted in place")
+}
@@ -1,6 +1,12 @@
 package simulator
 
-import "github.com/minhthong582000/rate-limiter/internal/engine"
+import (
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+)
 
 type Option func(*Simulator)
 
@@ -39,3 +45,72 @@ func WithStopChannel(stopCh <-chan struct{}) Option {
 		s.stopCh = stopCh
 	}
 }
+
+func WithPriorityMix(mix priority.Mix) Option {
+	return func(s *Simulator) {
+		s.priorityMix = &mix
+	}
+}
+
+// WithProfile replaces the fixed wait time and jitter with an arrival process.
+func WithProfile(p profile.Profile) Option {
+	return func(s *Simulator) {
+		s.profile = p
+	}
+}
+
+// WithSeed makes the random parts of the simulation reproducible.
+func WithSeed(seed uint64) Option {
+	return func(s *Simulator) {
+		s.seed = seed
+	}
+}
+
+// WithSpeed scales the time between the requests of a replayed trace, e.g. 2 replays it twice as fast.
+func WithSpeed(speed float64) Option {
+	return func(s *Simulator) {
+		s.speed = speed
+	}
+}
+
+// WithVirtualTime runs a discrete-event simulation: requests are sent with synthetic timestamps
+// starting at startTime, without sleeping. The engine must be created with the same start time.
+func WithVirtualTime(startTime time.Time) Option {
+	return func(s *Simulator) {
+		s.virtual = true
+		s.startTime = startTime
+	}
+}
+
+// WithQuiet stops logging every request, only the result is collected.
+func WithQuiet(quiet bool) Option {
+	return func(s *Simulator) {
+		s.quiet = quiet
+	}
+}
+
+// WithTenants replaces the workers with tenants, each sending its share of the requests
+// with its own key and arrival process. The rate limiter should be keyed.
+func WithTenants(tenants ...Tenant) Option {
+	return func(s *Simulator) {
+		s.tenants = tenants
+	}
+}
+
+// WithOpenLoop replaces the workers' arrival process with an open loop: requests are sent at the given rate
+// for the given duration, even when the workers are busy, instead of the number of requests.
+// It can't be combined with tenants, an arrival process or a wait time.
+func WithOpenLoop(rate float64, duration time.Duration) Option {
+	return func(s *Simulator) {
+		s.rate = rate
+		s.duration = duration
+	}
+}
+
+// WithService processes the allowed requests with a concurrency limiter instead of the queue of the engine,
+// to measure the queueing delay of engines without a queue.
+func WithService(service *Service) Option {
+	return func(s *Simulator) {
+		s.service = service
+	}
+}
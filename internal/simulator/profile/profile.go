@@ -0,0 +1,197 @@
+package profile
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Type string
+
+const (
+	Constant  Type = "constant"
+	Poisson   Type = "poisson"
+	OnOff     Type = "on-off"
+	Ramp      Type = "ramp"
+	Diurnal   Type = "diurnal"
+	Spike     Type = "spike"
+	Histogram Type = "histogram"
+)
+
+// Profile is an arrival process. Next returns the delay until the next request,
+// given the time elapsed since the start of the simulation when the previous request arrived.
+type Profile interface {
+	Next(elapsed time.Duration, rng *rand.Rand) time.Duration
+}
+
+// Params are the parameters of the profiles, each profile only uses some of them.
+type Params struct {
+	Interval  time.Duration // Constant: time between requests
+	Jitter    time.Duration // Constant: random jitter added to the interval, in [-jitter, jitter)
+	Rate      float64       // Poisson, on-off, spike: rate in requests/s. Ramp: start rate. Diurnal: mean rate
+	PeakRate  float64       // Ramp: end rate. Diurnal, spike: peak rate
+	Period    time.Duration // Ramp: ramp duration. Diurnal: period of the sine. Spike: time between spikes
+	On        time.Duration // On-off: duration of bursts. Spike: duration of spikes
+	Off       time.Duration // On-off: duration of silences
+	Bucket    time.Duration // Histogram: duration of a bucket
+	Histogram []float64     // Histogram: number of requests in each bucket, replayed in a loop
+}
+
+func New(profileType Type, params Params) (Profile, error) {
+	switch profileType {
+	case Constant:
+		if params.Interval <= 0 {
+			return nil, fmt.Errorf("interval must be greater than 0")
+		}
+		if params.Jitter < 0 || params.Jitter > params.Interval {
+			return nil, fmt.Errorf("jitter must be between 0 and interval")
+		}
+		return &constant{interval: params.Interval, jitter: params.Jitter}, nil
+	case Poisson:
+		if params.Rate <= 0 {
+			return nil, fmt.Errorf("rate must be greater than 0")
+		}
+		return &poisson{rate: params.Rate}, nil
+	case OnOff:
+		if params.Rate <= 0 || params.On <= 0 || params.Off < 0 {
+			return nil, fmt.Errorf("rate and on duration must be greater than 0, off duration must not be negative")
+		}
+		cycle := params.On + params.Off
+		return &varying{
+			maxRate: params.Rate,
+			rate: func(t time.Duration) float64 {
+				if t%cycle < params.On {
+					return params.Rate
+				}
+				return 0
+			},
+		}, nil
+	case Ramp:
+		// The rate stays at the peak rate after the ramp, no request would ever come at 0
+		if params.Rate < 0 || params.PeakRate <= 0 || params.Period <= 0 {
+			return nil, fmt.Errorf("rate must not be negative, peak rate and period must be greater than 0")
+		}
+		return &varying{
+			maxRate: math.Max(params.Rate, params.PeakRate),
+			rate: func(t time.Duration) float64 {
+				if t >= params.Period {
+					return params.PeakRate
+				}
+				progress := float64(t) / float64(params.Period)
+				return params.Rate + (params.PeakRate-params.Rate)*progress
+			},
+		}, nil
+	case Diurnal:
+		if params.Rate <= 0 || params.PeakRate < params.Rate || params.Period <= 0 {
+			return nil, fmt.Errorf("rate and period must be greater than 0, peak rate must be greater than or equal to rate")
+		}
+		amplitude := params.PeakRate - params.Rate
+		return &varying{
+			maxRate: params.PeakRate,
+			rate: func(t time.Duration) float64 {
+				phase := 2 * math.Pi * float64(t%params.Period) / float64(params.Period)
+				return math.Max(0, params.Rate+amplitude*math.Sin(phase))
+			},
+		}, nil
+	case Spike:
+		if params.Rate < 0 || params.PeakRate <= 0 || params.Period <= 0 || params.On <= 0 || params.On > params.Period {
+			return nil, fmt.Errorf("peak rate, period and spike duration must be greater than 0, spike duration must not exceed period")
+		}
+		return &varying{
+			maxRate: math.Max(params.Rate, params.PeakRate),
+			rate: func(t time.Duration) float64 {
+				if t%params.Period < params.On {
+					return params.PeakRate
+				}
+				return params.Rate
+			},
+		}, nil
+	case Histogram:
+		if params.Bucket <= 0 || len(params.Histogram) == 0 {
+			return nil, fmt.Errorf("bucket must be greater than 0 and histogram must not be empty")
+		}
+		maxCount := 0.0
+		for _, count := range params.Histogram {
+			if count < 0 {
+				return nil, fmt.Errorf("histogram counts must not be negative")
+			}
+			maxCount = math.Max(maxCount, count)
+		}
+		if maxCount == 0 {
+			return nil, fmt.Errorf("histogram must have at least one request")
+		}
+		bucketSeconds := params.Bucket.Seconds()
+		return &varying{
+			maxRate: maxCount / bucketSeconds,
+			rate: func(t time.Duration) float64 {
+				i := int(t/params.Bucket) % len(params.Histogram)
+				return params.Histogram[i] / bucketSeconds
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid profile %q", profileType)
+	}
+}
+
+// ParseHistogram parses a comma separated list of request counts, e.g. "5,10,50,10".
+func ParseHistogram(s string) ([]float64, error) {
+	var counts []float64
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		count, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid histogram count %q", field)
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// constant sends requests at a fixed interval with a uniform jitter
+type constant struct {
+	interval time.Duration
+	jitter   time.Duration
+}
+
+func (c *constant) Next(_ time.Duration, rng *rand.Rand) time.Duration {
+	if c.jitter <= 0 {
+		return c.interval
+	}
+	return c.interval + time.Duration(rng.Int64N(int64(2*c.jitter))) - c.jitter
+}
+
+// poisson sends requests with exponentially distributed inter-arrival times
+type poisson struct {
+	rate float64 // requests/s
+}
+
+func (p *poisson) Next(_ time.Duration, rng *rand.Rand) time.Duration {
+	return seconds(rng.ExpFloat64() / p.rate)
+}
+
+// varying is a non-homogeneous Poisson process with a time-varying rate, sampled by thinning:
+// candidates are drawn at maxRate and kept with probability rate(t)/maxRate.
+type varying struct {
+	rate    func(t time.Duration) float64 // requests/s at time t
+	maxRate float64                       // Upper bound of rate
+}
+
+func (v *varying) Next(elapsed time.Duration, rng *rand.Rand) time.Duration {
+	t := elapsed
+	for {
+		t += seconds(rng.ExpFloat64() / v.maxRate)
+		if rng.Float64()*v.maxRate < v.rate(t) {
+			return t - elapsed
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,156 @@
+package profile
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// arrivals generates the arrival times of a profile until the given duration
+func arrivals(t *testing.T, p Profile, until time.Duration) []time.Duration {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	var result []time.Duration
+	elapsed := time.Duration(0)
+	for {
+		elapsed += p.Next(elapsed, rng)
+		if elapsed >= until {
+			return result
+		}
+		result = append(result, elapsed)
+	}
+}
+
+// countBetween counts the arrivals in [from, to)
+func countBetween(arrivals []time.Duration, from, to time.Duration) int {
+	count := 0
+	for _, a := range arrivals {
+		if a >= from && a < to {
+			count++
+		}
+	}
+	return count
+}
+
+// TestConstant tests the fixed interval profile with jitter.
+func TestConstant(t *testing.T) {
+	p, err := New(Constant, Params{Interval: 100 * time.Millisecond, Jitter: 20 * time.Millisecond})
+	assert.NoError(t, err)
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 1000; i++ {
+		gap := p.Next(0, rng)
+		assert.GreaterOrEqual(t, gap, 80*time.Millisecond)
+		assert.Less(t, gap, 120*time.Millisecond)
+	}
+}
+
+// TestPoisson tests that the Poisson profile has the configured average rate.
+func TestPoisson(t *testing.T) {
+	p, err := New(Poisson, Params{Rate: 100})
+	assert.NoError(t, err)
+
+	result := arrivals(t, p, 100*time.Second)
+	assert.InDelta(t, 10000, len(result), 300, "Should send about 100 requests/s")
+}
+
+// TestOnOff tests that no request is sent during the off periods.
+func TestOnOff(t *testing.T) {
+	p, err := New(OnOff, Params{Rate: 100, On: time.Second, Off: 4 * time.Second})
+	assert.NoError(t, err)
+
+	result := arrivals(t, p, 50*time.Second)
+	for _, a := range result {
+		assert.Less(t, a%(5*time.Second), time.Second, "Request should be in an on period")
+	}
+	assert.InDelta(t, 1000, len(result), 100, "Should send about 100 requests/s during 10 on periods")
+}
+
+// TestRamp tests that the rate increases linearly and stays at the peak after the ramp.
+func TestRamp(t *testing.T) {
+	p, err := New(Ramp, Params{Rate: 0, PeakRate: 200, Period: 10 * time.Second})
+	assert.NoError(t, err)
+
+	result := arrivals(t, p, 20*time.Second)
+	first := countBetween(result, 0, 5*time.Second)
+	second := countBetween(result, 5*time.Second, 10*time.Second)
+	after := countBetween(result, 10*time.Second, 20*time.Second)
+
+	assert.InDelta(t, 250, first, 60, "Average rate of the first half should be 50 requests/s")
+	assert.InDelta(t, 750, second, 100, "Average rate of the second half should be 150 requests/s")
+	assert.InDelta(t, 2000, after, 150, "Rate should stay at the peak after the ramp")
+}
+
+// TestDiurnal tests that the rate follows a sine wave.
+func TestDiurnal(t *testing.T) {
+	p, err := New(Diurnal, Params{Rate: 100, PeakRate: 200, Period: 40 * time.Second})
+	assert.NoError(t, err)
+
+	result := arrivals(t, p, 40*time.Second)
+	peak := countBetween(result, 5*time.Second, 15*time.Second)
+	trough := countBetween(result, 25*time.Second, 35*time.Second)
+
+	assert.Greater(t, peak, 2*trough, "Peak should have a lot more requests than trough")
+	assert.InDelta(t, 4000, len(result), 200, "Average rate should be 100 requests/s")
+}
+
+// TestSpike tests that spikes happen periodically on top of the base rate.
+func TestSpike(t *testing.T) {
+	p, err := New(Spike, Params{Rate: 10, PeakRate: 500, Period: 10 * time.Second, On: time.Second})
+	assert.NoError(t, err)
+
+	result := arrivals(t, p, 30*time.Second)
+	for i := 0; i < 3; i++ {
+		start := time.Duration(i) * 10 * time.Second
+		assert.InDelta(t, 500, countBetween(result, start, start+time.Second), 80, "Spike %d", i+1)
+		assert.InDelta(t, 90, countBetween(result, start+time.Second, start+10*time.Second), 40, "Base %d", i+1)
+	}
+}
+
+// TestHistogram tests that a histogram is replayed in a loop.
+func TestHistogram(t *testing.T) {
+	counts, err := ParseHistogram("100, 0, 300")
+	assert.NoError(t, err)
+
+	p, err := New(Histogram, Params{Bucket: time.Second, Histogram: counts})
+	assert.NoError(t, err)
+
+	result := arrivals(t, p, 30*time.Second)
+	for i := 0; i < 10; i++ {
+		start := time.Duration(i) * 3 * time.Second
+		assert.InDelta(t, 100, countBetween(result, start, start+time.Second), 40)
+		assert.Equal(t, 0, countBetween(result, start+time.Second, start+2*time.Second))
+		assert.InDelta(t, 300, countBetween(result, start+2*time.Second, start+3*time.Second), 60)
+	}
+}
+
+// TestNew_InvalidParams tests that invalid parameters are rejected.
+func TestNew_InvalidParams(t *testing.T) {
+	testCases := []struct {
+		name        string
+		profileType Type
+		params      Params
+	}{
+		{name: "Unknown profile", profileType: Type("sawtooth")},
+		{name: "Constant without interval", profileType: Constant},
+		{name: "Constant with big jitter", profileType: Constant, params: Params{Interval: time.Second, Jitter: 2 * time.Second}},
+		{name: "Poisson without rate", profileType: Poisson},
+		{name: "On-off without on", profileType: OnOff, params: Params{Rate: 1}},
+		{name: "Ramp without period", profileType: Ramp, params: Params{Rate: 1, PeakRate: 2}},
+		{name: "Ramp down to 0", profileType: Ramp, params: Params{Rate: 10, PeakRate: 0, Period: time.Second}},
+		{name: "Diurnal with peak below mean", profileType: Diurnal, params: Params{Rate: 2, PeakRate: 1, Period: time.Second}},
+		{name: "Spike longer than period", profileType: Spike, params: Params{PeakRate: 1, Period: time.Second, On: 2 * time.Second}},
+		{name: "Empty histogram", profileType: Histogram, params: Params{Bucket: time.Second}},
+		{name: "Histogram without requests", profileType: Histogram, params: Params{Bucket: time.Second, Histogram: []float64{0, 0}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.profileType, tc.params)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Print writes a human readable summary
+func (s Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nSummary\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "  Requests:\t%d\n", s.Total)
+	fmt.Fprintf(tw, "  Allowed:\t%d (%.1f%%)\n", s.Allowed, 100*s.AdmitRate)
+	fmt.Fprintf(tw, "  Denied:\t%d (%.1f%%)\n", s.Denied, 100*admitRate(s.Denied, s.Total))
+	fmt.Fprintf(tw, "  Duration:\t%v\n", s.Duration)
+	fmt.Fprintf(tw, "  Throughput:\t%.2f allowed requests/s\n", s.Throughput)
+	fmt.Fprintf(tw, "  Longest allowed streak:\t%d requests\n", s.LongestAllowedStreak)
+	fmt.Fprintf(tw, "  Longest denial streak:\t%d requests\n", s.LongestDenialStreak)
+	_ = tw.Flush()
+
+	if o := s.OpenLoop; o != nil {
+		fmt.Fprintf(w, "\nOpen loop\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(tw, "  Offered:\t%.2f requests/s for %v, %d requests scheduled\n", o.Rate, o.Duration, o.Scheduled)
+		fmt.Fprintf(tw, "  Sent:\t%.2f requests/s, %d requests\n", o.SendRate, o.Sent)
+		fmt.Fprintf(tw, "  Latency:\t%v\n", o.Latency)
+		_ = tw.Flush()
+	}
+
+	if q := s.Queueing; q != nil {
+		fmt.Fprintf(w, "\nQueueing (%s)\n", q.Queue)
+		tw = tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(tw, "  Processed:\t%d requests, %d still queued\n", q.Processed, q.Waiting)
+		fmt.Fprintf(tw, "  Queueing delay:\t%v\n", q.Delay)
+		fmt.Fprintf(tw, "  Max queue age:\t%v\n", q.MaxQueueAge)
+		_ = tw.Flush()
+	}
+
+	if len(s.Buckets) > 0 {
+		fmt.Fprintf(w, "\nAdmit rate every %v\n", s.BucketSize)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "  START\tALLOWED\tDENIED\tADMIT RATE\n")
+		for _, b := range s.Buckets {
+			fmt.Fprintf(tw, "  +%v\t%d\t%d\t%.1f%%\n", b.Start.Sub(s.Start), b.Allowed, b.Denied, 100*b.AdmitRate)
+		}
+		_ = tw.Flush()
+	}
+
+	if len(s.Workers) > 0 {
+		fmt.Fprintf(w, "\nWorkers\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "  WORKER\tREQUESTS\tALLOWED\tDENIED\tADMIT RATE\n")
+		for _, ws := range s.Workers {
+			fmt.Fprintf(tw, "  %d\t%d\t%d\t%d\t%.1f%%\n", ws.Worker, ws.Total, ws.Allowed, ws.Denied, 100*ws.AdmitRate)
+		}
+		_ = tw.Flush()
+	}
+
+	if len(s.Tenants) > 0 {
+		fmt.Fprintf(w, "\nTenants\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "  TENANT\tREQUESTS\tALLOWED\tDENIED\tADMIT RATE\tTHROUGHPUT\tFAIR SHARE\n")
+		for _, t := range s.Tenants {
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%.1f%%\t%.2f/s\t%.0f\n",
+				t.Key, t.Total, t.Allowed, t.Denied, 100*t.AdmitRate, t.Throughput, t.FairShare)
+		}
+		_ = tw.Flush()
+		fmt.Fprintf(w, "  Fairness (Jain index): %.3f\n", s.Fairness)
+	}
+}
+
+// WriteJSON writes the summary as JSON
+func (s Summary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// WriteCSV writes one record per request. The timestamp, key and cost columns
+// make the output a trace that can be replayed.
+func (r *Result) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"timestamp", "key", "cost", "worker", "id", "class", "allowed"}
+	if r.LevelName != "" {
+		header = append(header, "level")
+	}
+	if r.Queue != "" {
+		header = append(header, "processed_at")
+	}
+	_ = writer.Write(header)
+
+	for _, req := range r.Requests {
+		record := []string{
+			req.ArriveAt.Format(time.RFC3339Nano),
+			req.Key,
+			strconv.FormatUint(req.Cost, 10),
+			strconv.FormatInt(req.Worker, 10),
+			strconv.FormatInt(req.ID, 10),
+			req.Class,
+			strconv.FormatBool(req.Allowed),
+		}
+		if r.LevelName != "" {
+			record = append(record, strconv.FormatFloat(req.Level, 'f', -1, 64))
+		}
+		if r.Queue != "" {
+			processedAt := ""
+			if !req.ProcessedAt.IsZero() {
+				processedAt = req.ProcessedAt.Format(time.RFC3339Nano)
+			}
+			record = append(record, processedAt)
+		}
+		_ = writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,359 @@
+package simulator
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// Request is the decision of the rate limiter on a simulated request.
+type Request struct {
+	Worker   int64     `json:"worker"` // 0 for replayed requests
+	ID       int64     `json:"id"`
+	Key      string    `json:"key,omitempty"`
+	Class    string    `json:"class,omitempty"`
+	Cost     uint64    `json:"cost"`
+	ArriveAt time.Time `json:"timestamp"`
+	Allowed  bool      `json:"allowed"`
+	Level    float64   `json:"level"` // Level of the engine after the decision, if it has one
+
+	// Open loop only: time the request was scheduled at, and time from then to the decision
+	ScheduledAt time.Time     `json:"scheduled_at,omitempty"`
+	Latency     time.Duration `json:"latency_ns,omitempty"`
+
+	// Time the allowed request left the queue and started being processed, zero if it was still queued
+	// at the end of the simulation or if the simulation has no queue
+	ProcessedAt time.Time `json:"processed_at,omitempty"`
+}
+
+// Result holds the decisions of a simulation, in arrival order.
+type Result struct {
+	Requests  []Request
+	Tenants   []string  // Keys of the simulated tenants, if any
+	LevelName string    // Empty if the engine doesn't expose its level
+	LevelMax  float64   // Maximum of the level, e.g. the capacity
+	OpenLoop  *OpenLoop // Load of an open loop simulation, nil for a closed loop
+	Queue     string    // Queue holding the allowed requests before they are processed, empty if none
+}
+
+// OpenLoop is the load offered by an open loop simulation.
+type OpenLoop struct {
+	Rate      float64       `json:"rate"` // Requests/s
+	Duration  time.Duration `json:"duration_ns"`
+	Scheduled int64         `json:"scheduled"` // Requests scheduled, more than the requests sent if the simulation was stopped
+}
+
+// recorder collects the decisions of concurrent workers
+type recorder struct {
+	mutex       sync.Mutex
+	requests    []Request
+	ratelimiter engine.LevelEngine // Nil if the engine doesn't expose its level
+	levelName   string
+	levelMax    float64
+	tenants     []string
+	openLoop    *OpenLoop
+
+	// Processing times reported by the queue of the engine, by arrival time in nanoseconds
+	queue     string
+	processed map[int64][]time.Time
+}
+
+// newRecorder records the decisions of the engine, and its level if it has one
+func newRecorder(e engine.Engine, start time.Time) *recorder {
+	r := &recorder{}
+	if l, ok := e.(engine.LevelEngine); ok {
+		r.ratelimiter = l
+		r.levelName = l.LevelName()
+		_, r.levelMax = l.Level(start)
+	}
+	return r
+}
+
+func (r *recorder) record(req Request) {
+	if r.ratelimiter != nil {
+		req.Level, _ = r.ratelimiter.Level(req.ArriveAt)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests = append(r.requests, req)
+}
+
+// queued reports the requests processed by the queue of the engine
+func (r *recorder) queued(e engine.Engine) {
+	q, ok := e.(engine.QueueEngine)
+	if !ok {
+		return
+	}
+	if q.OnProcessed(r.onProcessed) {
+		r.queue = engineQueue
+		r.processed = map[int64][]time.Time{}
+	}
+}
+
+// onProcessed records a request leaving the queue of the engine
+func (r *recorder) onProcessed(arriveAt, processedAt time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := arriveAt.UnixNano()
+	r.processed[key] = append(r.processed[key], processedAt)
+}
+
+// schedule counts a request scheduled by the open loop generator
+func (r *recorder) schedule() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.openLoop.Scheduled++
+}
+
+func (r *recorder) result() *Result {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	requests := append([]Request{}, r.requests...)
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].ArriveAt.Before(requests[j].ArriveAt)
+	})
+	// Requests arriving at the same time are processed in arrival order
+	for i := range requests {
+		key := requests[i].ArriveAt.UnixNano()
+		if times := r.processed[key]; requests[i].Allowed && len(times) > 0 {
+			requests[i].ProcessedAt = times[0]
+			r.processed[key] = times[1:]
+		}
+	}
+
+	result := &Result{
+		Requests:  requests,
+		Tenants:   r.tenants,
+		LevelName: r.levelName,
+		LevelMax:  r.levelMax,
+		Queue:     r.queue,
+	}
+	if r.openLoop != nil {
+		openLoop := *r.openLoop
+		result.OpenLoop = &openLoop
+	}
+	return result
+}
+
+// Bucket counts the decisions in a time bucket.
+type Bucket struct {
+	Start     time.Time `json:"start"`
+	Allowed   int64     `json:"allowed"`
+	Denied    int64     `json:"denied"`
+	AdmitRate float64   `json:"admit_rate"`
+	Level     *float64  `json:"level,omitempty"` // Last level of the engine in the bucket, nil without requests
+}
+
+// WorkerSummary counts the decisions on the requests of a worker.
+type WorkerSummary struct {
+	Worker    int64   `json:"worker"`
+	Total     int64   `json:"total"`
+	Allowed   int64   `json:"allowed"`
+	Denied    int64   `json:"denied"`
+	AdmitRate float64 `json:"admit_rate"`
+}
+
+// Summary is an overview of the decisions of a simulation.
+type Summary struct {
+	Total     int64   `json:"total"`
+	Allowed   int64   `json:"allowed"`
+	Denied    int64   `json:"denied"`
+	AdmitRate float64 `json:"admit_rate"`
+
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	Duration   time.Duration `json:"duration_ns"` // Between the first and the last request
+	Throughput float64       `json:"throughput"`  // Allowed requests/s
+
+	LongestAllowedStreak int64 `json:"longest_allowed_streak"` // Consecutive allowed requests, the largest burst let through
+	LongestDenialStreak  int64 `json:"longest_denial_streak"`  // Consecutive denied requests
+
+	LevelName string  `json:"level_name,omitempty"`
+	LevelMax  float64 `json:"level_max,omitempty"`
+
+	BucketSize time.Duration   `json:"bucket_size_ns"`
+	Buckets    []Bucket        `json:"buckets"`
+	Workers    []WorkerSummary `json:"workers,omitempty"`
+
+	Tenants []TenantSummary `json:"tenants,omitempty"`
+	// Jain's index of the allowed requests of each tenant relative to its fair share, 1 is perfectly fair
+	Fairness float64 `json:"fairness,omitempty"`
+
+	OpenLoop *OpenLoopSummary `json:"open_loop,omitempty"`
+	Queueing *QueueingSummary `json:"queueing,omitempty"`
+}
+
+// QueueingSummary is the time the allowed requests waited in a queue before being processed.
+type QueueingSummary struct {
+	Queue     string      `json:"queue"`
+	Processed int64       `json:"processed"`
+	Waiting   int64       `json:"waiting"` // Allowed requests still queued at the end of the simulation
+	Delay     Percentiles `json:"delay"`   // Queueing delay of the processed requests
+	// Longest time a request spent in the queue, counting the requests still queued until the end of the simulation
+	MaxQueueAge time.Duration `json:"max_queue_age_ns"`
+}
+
+// OpenLoopSummary compares the load offered by an open loop simulation to the load sent.
+type OpenLoopSummary struct {
+	OpenLoop
+	Sent     int64   `json:"sent"`
+	SendRate float64 `json:"send_rate"` // Requests/s sent over the duration of the load
+	// Time from the scheduled time of the requests to the decision, including the time waiting for a free worker
+	Latency Percentiles `json:"latency"`
+}
+
+// Summary counts the decisions of the simulation, the admit rate over time
+// is counted in buckets of the given size.
+func (r *Result) Summary(bucketSize time.Duration) Summary {
+	if bucketSize <= 0 {
+		panic("bucket size must be greater than 0")
+	}
+
+	s := Summary{LevelName: r.LevelName, LevelMax: r.LevelMax, BucketSize: bucketSize, Buckets: []Bucket{}}
+	if len(r.Requests) == 0 {
+		return s
+	}
+
+	s.Start = r.Requests[0].ArriveAt
+	s.End = r.Requests[len(r.Requests)-1].ArriveAt
+	s.Duration = s.End.Sub(s.Start)
+
+	workers := map[int64]*WorkerSummary{}
+	allowedStreak, deniedStreak := int64(0), int64(0)
+	for _, req := range r.Requests {
+		i := int(req.ArriveAt.Sub(s.Start) / bucketSize)
+		for len(s.Buckets) <= i {
+			s.Buckets = append(s.Buckets, Bucket{Start: s.Start.Add(time.Duration(len(s.Buckets)) * bucketSize)})
+		}
+		if r.LevelName != "" {
+			level := req.Level
+			s.Buckets[i].Level = &level
+		}
+
+		w, ok := workers[req.Worker]
+		if !ok && req.Worker > 0 && len(r.Tenants) == 0 { // Tenants have their own summary
+			w = &WorkerSummary{Worker: req.Worker}
+			workers[req.Worker] = w
+		}
+
+		s.Total++
+		if req.Allowed {
+			s.Allowed++
+			s.Buckets[i].Allowed++
+			allowedStreak++
+			deniedStreak = 0
+			s.LongestAllowedStreak = max(s.LongestAllowedStreak, allowedStreak)
+		} else {
+			s.Denied++
+			s.Buckets[i].Denied++
+			allowedStreak = 0
+			deniedStreak++
+			s.LongestDenialStreak = max(s.LongestDenialStreak, deniedStreak)
+		}
+
+		if w != nil {
+			w.Total++
+			if req.Allowed {
+				w.Allowed++
+			} else {
+				w.Denied++
+			}
+		}
+	}
+
+	s.AdmitRate = admitRate(s.Allowed, s.Total)
+	if s.Duration > 0 {
+		s.Throughput = float64(s.Allowed) / s.Duration.Seconds()
+	}
+	for i := range s.Buckets {
+		s.Buckets[i].AdmitRate = admitRate(s.Buckets[i].Allowed, s.Buckets[i].Allowed+s.Buckets[i].Denied)
+	}
+	for _, w := range workers {
+		w.AdmitRate = admitRate(w.Allowed, w.Total)
+		s.Workers = append(s.Workers, *w)
+	}
+	sort.Slice(s.Workers, func(i, j int) bool {
+		return s.Workers[i].Worker < s.Workers[j].Worker
+	})
+	s.Tenants, s.Fairness = r.tenantSummaries(s.Duration)
+	s.OpenLoop = r.openLoopSummary()
+	s.Queueing = r.queueingSummary(s.End)
+
+	return s
+}
+
+// openLoopSummary measures the load actually sent and the latency of the requests, nil for a closed loop
+func (r *Result) openLoopSummary() *OpenLoopSummary {
+	if r.OpenLoop == nil {
+		return nil
+	}
+
+	s := &OpenLoopSummary{OpenLoop: *r.OpenLoop, Sent: int64(len(r.Requests))}
+	if s.Duration > 0 {
+		s.SendRate = float64(s.Sent) / s.Duration.Seconds()
+	}
+	latencies := make([]time.Duration, len(r.Requests))
+	for i, req := range r.Requests {
+		latencies[i] = req.Latency
+	}
+	s.Latency = NewPercentiles(latencies)
+	return s
+}
+
+// queueingSummary measures the queueing delay of the allowed requests, nil if the simulation has no queue
+func (r *Result) queueingSummary(end time.Time) *QueueingSummary {
+	if r.Queue == "" {
+		return nil
+	}
+
+	s := &QueueingSummary{Queue: r.Queue}
+	var delays []time.Duration
+	for _, req := range r.Requests {
+		if !req.Allowed {
+			continue
+		}
+		if req.ProcessedAt.IsZero() {
+			s.Waiting++
+			s.MaxQueueAge = max(s.MaxQueueAge, end.Sub(req.ArriveAt))
+			continue
+		}
+		s.Processed++
+		delays = append(delays, req.ProcessedAt.Sub(req.ArriveAt))
+	}
+	s.Delay = NewPercentiles(delays)
+	s.MaxQueueAge = max(s.MaxQueueAge, s.Delay.Max)
+	return s
+}
+
+// PeakAllowed returns the maximum number of requests allowed in any sliding window
+// of the given size, i.e. in any interval [t, t+window).
+func (r *Result) PeakAllowed(window time.Duration) int64 {
+	peak := int64(0)
+	start := 0 // First allowed request in the window
+	var allowed []time.Time
+
+	for _, req := range r.Requests {
+		if !req.Allowed {
+			continue
+		}
+
+		allowed = append(allowed, req.ArriveAt)
+		for req.ArriveAt.Sub(allowed[start]) >= window {
+			start++
+		}
+		peak = max(peak, int64(len(allowed)-start))
+	}
+
+	return peak
+}
+
+func admitRate(allowed int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(allowed) / float64(total)
+}
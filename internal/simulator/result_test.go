@@ -0,0 +1,137 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/simulator/trace"
+)
+
+// decision is the arrival offset in milliseconds and the decision on a request
+type decision struct {
+	at      int64
+	allowed bool
+}
+
+// newResult creates a result from the decisions on the requests of each worker
+func newResult(start time.Time, workers map[int64][]decision) *Result {
+	r := &recorder{}
+	for worker, requests := range workers {
+		for i, req := range requests {
+			r.record(Request{
+				Worker:   worker,
+				ID:       int64(i),
+				Cost:     1,
+				ArriveAt: start.Add(time.Duration(req.at) * time.Millisecond),
+				Allowed:  req.allowed,
+			})
+		}
+	}
+	return r.result()
+}
+
+// TestResult_Summary tests the counts, buckets, streaks and per-worker breakdown of a summary.
+func TestResult_Summary(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := newResult(start, map[int64][]decision{
+		1: {{0, true}, {200, false}, {400, false}, {2000, true}},
+		2: {{100, true}, {300, false}, {1500, true}},
+	})
+
+	// Requests are sorted by arrival
+	for i := 1; i < len(result.Requests); i++ {
+		assert.False(t, result.Requests[i].ArriveAt.Before(result.Requests[i-1].ArriveAt))
+	}
+
+	s := result.Summary(time.Second)
+	assert.Equal(t, int64(7), s.Total)
+	assert.Equal(t, int64(4), s.Allowed)
+	assert.Equal(t, int64(3), s.Denied)
+	assert.InDelta(t, 4.0/7.0, s.AdmitRate, 1e-9)
+	assert.Equal(t, 2*time.Second, s.Duration)
+	assert.InDelta(t, 2.0, s.Throughput, 1e-9, "4 allowed requests in 2s")
+	assert.Equal(t, int64(3), s.LongestDenialStreak, "Requests at 200, 300 and 400ms are denied in a row")
+	assert.Equal(t, int64(2), s.LongestAllowedStreak)
+
+	assert.Equal(t, []Bucket{
+		{Start: start, Allowed: 2, Denied: 3, AdmitRate: 0.4},
+		{Start: start.Add(time.Second), Allowed: 1, Denied: 0, AdmitRate: 1},
+		{Start: start.Add(2 * time.Second), Allowed: 1, Denied: 0, AdmitRate: 1},
+	}, s.Buckets)
+
+	assert.Equal(t, []WorkerSummary{
+		{Worker: 1, Total: 4, Allowed: 2, Denied: 2, AdmitRate: 0.5},
+		{Worker: 2, Total: 3, Allowed: 2, Denied: 1, AdmitRate: 2.0 / 3.0},
+	}, s.Workers)
+}
+
+// TestResult_SummaryEmpty tests the summary of a simulation without requests.
+func TestResult_SummaryEmpty(t *testing.T) {
+	s := (&Result{}).Summary(time.Second)
+	assert.Equal(t, int64(0), s.Total)
+	assert.Equal(t, 0.0, s.AdmitRate)
+	assert.Empty(t, s.Buckets)
+
+	assert.Panics(t, func() {
+		(&Result{}).Summary(0)
+	}, "Summary with a zero bucket size should panic")
+}
+
+// TestSummary_WriteJSON tests that the summary is exported as JSON.
+func TestSummary_WriteJSON(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := newResult(start, map[int64][]decision{
+		1: {{0, true}, {500, false}},
+	})
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.Summary(time.Second).WriteJSON(&buf))
+
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, 2.0, decoded["total"])
+	assert.Equal(t, 1.0, decoded["denied"])
+	assert.Equal(t, 1.0, decoded["longest_denial_streak"])
+	assert.Len(t, decoded["buckets"], 1)
+	assert.Len(t, decoded["workers"], 1)
+}
+
+// TestResult_WriteCSV tests that the CSV export can be replayed as a trace.
+func TestResult_WriteCSV(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := newResult(start, map[int64][]decision{
+		1: {{0, true}, {500, false}},
+	})
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteCSV(&buf))
+	assert.True(t, strings.HasPrefix(buf.String(), "timestamp,key,cost,worker,id,class,allowed\n"))
+
+	reader, err := trace.NewReader(&buf, trace.CSV)
+	assert.NoError(t, err)
+	e, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, start, e.Time)
+	e, err = reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(500*time.Millisecond), e.Time)
+}
+
+// TestResult_PeakAllowed tests the maximum number of allowed requests in any sliding window.
+func TestResult_PeakAllowed(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := newResult(start, map[int64][]decision{
+		// A fixed window of 1s would allow 3 requests at the end of a window and 3 at the start of the next one
+		1: {{0, true}, {700, true}, {800, true}, {900, true}, {950, false}, {1000, true}, {1100, true}, {1200, true}, {2500, true}},
+	})
+
+	assert.Equal(t, int64(6), result.PeakAllowed(time.Second), "6 requests are allowed between 700ms and 1200ms")
+	assert.Equal(t, int64(3), result.PeakAllowed(300*time.Millisecond))
+	assert.Equal(t, int64(1), result.PeakAllowed(time.Nanosecond))
+	assert.Equal(t, int64(0), (&Result{}).PeakAllowed(time.Second))
+}
@@ -0,0 +1,238 @@
+package scenario
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/internal/simulator"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+)
+
+// Outcome is the result of a scenario and the expectations it failed.
+type Outcome struct {
+	Scenario *Scenario
+	Result   *simulator.Result
+	Failures []string
+}
+
+func (o *Outcome) Passed() bool {
+	return len(o.Failures) == 0
+}
+
+func (o *Outcome) fail(format string, args ...any) {
+	o.Failures = append(o.Failures, fmt.Sprintf(format, args...))
+}
+
+// Run sends the requests and the traffic of the scenario to a new engine and checks the expectations.
+// An error is returned if the scenario can't run, failed expectations are in the outcome.
+func (s *Scenario) Run(stopCh <-chan struct{}) (*Outcome, error) {
+	ratelimiter, err := s.newEngine(stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests may be written out of order or overlap with count and every, the engine must see them in time order
+	type arrival struct {
+		index, n int
+		at       time.Time
+	}
+	var arrivals []arrival
+	for i, r := range s.Requests {
+		at, _ := s.requestTime(r.At)
+		for n := 0; n < max(r.Count, 1); n++ {
+			arrivals = append(arrivals, arrival{index: i, n: n, at: at.Add(time.Duration(n) * r.Every)})
+		}
+	}
+	sort.SliceStable(arrivals, func(i, j int) bool {
+		return arrivals[i].at.Before(arrivals[j].at)
+	})
+
+	o := &Outcome{Scenario: s, Result: &simulator.Result{}}
+	end := s.Start
+	for _, a := range arrivals {
+		r := s.Requests[a.index]
+		req := s.send(ratelimiter, r, a.at)
+		req.ID = int64(len(o.Result.Requests))
+		o.Result.Requests = append(o.Result.Requests, req)
+		end = a.at
+
+		if r.Expect != "" && (r.Expect == "allow") != req.Allowed {
+			got := "deny"
+			if req.Allowed {
+				got = "allow"
+			}
+			o.fail("request %d (#%d) at +%v: expected %s, got %s", a.index+1, a.n+1, a.at.Sub(s.Start), r.Expect, got)
+		}
+	}
+
+	if s.Traffic != nil {
+		result, err := s.Traffic.run(ratelimiter, end, stopCh)
+		if err != nil {
+			return nil, fmt.Errorf("traffic: %w", err)
+		}
+		o.Result.Requests = append(o.Result.Requests, result.Requests...)
+		o.Result.Tenants = result.Tenants
+	}
+
+	// Requests at exact times may be out of order
+	sort.SliceStable(o.Result.Requests, func(i, j int) bool {
+		return o.Result.Requests[i].ArriveAt.Before(o.Result.Requests[j].ArriveAt)
+	})
+
+	s.Expect.check(o)
+	return o, nil
+}
+
+// newEngine creates the engine of the scenario in virtual time, invalid configurations are returned as errors
+func (s *Scenario) newEngine(stopCh <-chan struct{}) (engine.Engine, error) {
+	opts, err := s.engineOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts,
+		engine.WithStopChannel(stopCh),
+		engine.WithVirtualTime(s.Start),
+		engine.WithQuiet(true),
+	)
+	return engine.EngineFactory(opts...)
+}
+
+// send checks a request with the engine, with its key if the engine is keyed, its priority if set, or its cost
+func (s *Scenario) send(e engine.Engine, r Request, arriveAt time.Time) simulator.Request {
+	req := simulator.Request{Key: r.Key, Class: r.Priority, Cost: max(r.Cost, 1), ArriveAt: arriveAt}
+
+	if _, ok := e.(engine.KeyedEngine); ok && r.Key != "" {
+		req.Allowed = engine.AllowNAtKey(e, arriveAt, r.Key, req.Cost)
+	} else if r.Priority != "" {
+		class, _ := priority.ParseClass(r.Priority)
+		req.Allowed = engine.AllowAtPriority(e, arriveAt, class)
+	} else {
+		req.Allowed = engine.AllowNAt(e, arriveAt, req.Cost)
+	}
+	return req
+}
+
+// run generates the traffic in virtual time from the given start
+func (t *Traffic) run(e engine.Engine, start time.Time, stopCh <-chan struct{}) (*simulator.Result, error) {
+	profileType := profile.Type(t.Profile)
+	if profileType == "" {
+		profileType = profile.Constant
+	}
+	params := profile.Params{
+		Interval: t.WaitTime,
+		Jitter:   t.Jitter,
+		Rate:     t.Rate,
+		PeakRate: t.PeakRate,
+		Period:   t.Period,
+		On:       t.On,
+		Off:      t.Off,
+	}
+	if params.Interval == 0 {
+		params.Interval = 100 * time.Millisecond
+	}
+	p, err := profile.New(profileType, params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s profile: %w", profileType, err)
+	}
+
+	opts := []simulator.Option{
+		simulator.WithRateLimiter(e),
+		simulator.WithNumWorker(max(t.Workers, 1)),
+		simulator.WithNumRequests(t.Requests),
+		simulator.WithProfile(p),
+		simulator.WithSeed(max(t.Seed, 1)),
+		simulator.WithVirtualTime(start),
+		simulator.WithQuiet(true),
+		simulator.WithStopChannel(stopCh),
+	}
+
+	if len(t.Tenants) > 0 {
+		var tenants []simulator.Tenant
+		for _, spec := range t.Tenants {
+			tenant, err := simulator.ParseTenant(spec, profileType, params)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tenant %q: %w", spec, err)
+			}
+			tenants = append(tenants, tenant)
+		}
+		opts = append(opts, simulator.WithTenants(tenants...))
+	}
+
+	sim, err := simulator.NewSimulator(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sim.Run(), nil
+}
+
+// check records the bounds the result of the scenario is out of
+func (e Expect) check(o *Outcome) {
+	r := o.Result
+	// A single bucket, only the totals are used and scenarios may span years
+	s := r.Summary(math.MaxInt64)
+
+	e.Allowed.check(o, "allowed requests", float64(s.Allowed))
+	e.Denied.check(o, "denied requests", float64(s.Denied))
+	e.AdmitRate.check(o, "admit rate", s.AdmitRate)
+
+	if e.Peak != nil {
+		if peak := r.PeakAllowed(e.Peak.Window); peak > e.Peak.Max {
+			o.fail("peak: %d requests allowed in %v, expected at most %d", peak, e.Peak.Window, e.Peak.Max)
+		}
+	}
+
+	keys := make([]string, 0, len(e.Keys))
+	for key := range e.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		bound := e.Keys[key]
+		total, allowed := int64(0), int64(0)
+		for _, req := range r.Requests {
+			if req.Key == key {
+				total++
+				if req.Allowed {
+					allowed++
+				}
+			}
+		}
+		if total == 0 {
+			o.fail("key %q: no requests", key)
+			continue
+		}
+		bound.check(o, fmt.Sprintf("admit rate of key %q", key), float64(allowed)/float64(total))
+	}
+
+	if e.Fairness != nil {
+		if len(s.Tenants) == 0 {
+			o.fail("fairness: the traffic has no tenants")
+		} else {
+			e.Fairness.check(o, "fairness", s.Fairness)
+		}
+	}
+}
+
+func (b *Bound) check(o *Outcome, name string, value float64) {
+	if b == nil {
+		return
+	}
+	if b.Min != nil && value < *b.Min {
+		o.fail("%s: %s, expected at least %s", name, format(value), format(*b.Min))
+	}
+	if b.Max != nil && value > *b.Max {
+		o.fail("%s: %s, expected at most %s", name, format(value), format(*b.Max))
+	}
+}
+
+// format writes counts without decimals and rates with 3 decimals
+func format(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.3f", v)
+}
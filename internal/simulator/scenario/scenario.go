@@ -0,0 +1,237 @@
+package scenario
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+)
+
+// defaultStart is the virtual time of the start of a scenario without a start time
+var defaultStart = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Scenario is a rate limiting policy, the traffic it gets and the decisions it is expected to make.
+// Scenarios always run in virtual time, so they are fast and reproducible.
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+
+	// Engine configuration, with the params of the engine configurations of the compare command,
+	// e.g. {type: token-bucket, capacity: 10, fill-duration: 100}. Unset params default to the
+	// flags of the run command. priority-shares takes the format of the --priority-shares flag.
+	Engine map[string]any `yaml:"engine"`
+	// Virtual time of the start of the scenario, default is 2025-01-01T00:00:00Z
+	Start time.Time `yaml:"start"`
+
+	// Requests sent at exact times, in the given order
+	Requests []Request `yaml:"requests"`
+	// Generated traffic, sent after the requests
+	Traffic *Traffic `yaml:"traffic"`
+
+	// Bounds on the decisions of the whole scenario
+	Expect Expect `yaml:"expect"`
+}
+
+// Request is one or more requests sent at an exact time.
+type Request struct {
+	// Offset from the start of the scenario (e.g. "1500ms") or timestamp (RFC3339)
+	At       string `yaml:"at"`
+	Key      string `yaml:"key"`
+	Cost     uint64 `yaml:"cost"`     // Default is 1
+	Priority string `yaml:"priority"` // critical, normal or sheddable, default is normal
+
+	// Send the request count times, every given duration. Default is once
+	Count int           `yaml:"count"`
+	Every time.Duration `yaml:"every"`
+
+	// Expected decision of every request: allow or deny. Not checked if empty
+	Expect string `yaml:"expect"`
+}
+
+// Traffic is generated like the traffic of the run command.
+type Traffic struct {
+	Requests int64  `yaml:"requests"`
+	Workers  int64  `yaml:"workers"` // Default is 1
+	Seed     uint64 `yaml:"seed"`    // Default is 1, so the scenario is reproducible
+
+	// Arrival process of each worker, see the --profile flags of the run command
+	Profile  string        `yaml:"profile"` // Default is constant
+	WaitTime time.Duration `yaml:"wait-time"`
+	Jitter   time.Duration `yaml:"jitter"`
+	Rate     float64       `yaml:"rate"`
+	PeakRate float64       `yaml:"peak-rate"`
+	Period   time.Duration `yaml:"period"`
+	On       time.Duration `yaml:"on"`
+	Off      time.Duration `yaml:"off"`
+
+	// Tenants sending their own traffic instead of the workers, in the format of the --tenant flag
+	Tenants []string `yaml:"tenants"`
+}
+
+// Bound is an inclusive range, unset ends are not checked.
+type Bound struct {
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+// Expect are the bounds on the decisions of a scenario.
+type Expect struct {
+	Allowed   *Bound `yaml:"allowed"`
+	Denied    *Bound `yaml:"denied"`
+	AdmitRate *Bound `yaml:"admit-rate"`
+
+	// Max requests allowed in any sliding window
+	Peak *struct {
+		Window time.Duration `yaml:"window"`
+		Max    int64         `yaml:"max"`
+	} `yaml:"peak"`
+
+	// Admit rate of each tenant or key
+	Keys map[string]Bound `yaml:"keys"`
+	// Jain's index of the tenants, see the multi-tenant summary
+	Fairness *Bound `yaml:"fairness"`
+}
+
+// LoadFile reads a scenario from a YAML file, named after the file if it has no name
+func LoadFile(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = path
+	}
+	return s, nil
+}
+
+// Load reads and validates a scenario
+func Load(r io.Reader) (*Scenario, error) {
+	s := &Scenario{}
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(s); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	if s.Start.IsZero() {
+		s.Start = defaultStart
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Scenario) validate() error {
+	if _, err := s.engineOptions(); err != nil {
+		return err
+	}
+
+	if len(s.Requests) == 0 && s.Traffic == nil {
+		return fmt.Errorf("scenario must have requests or traffic")
+	}
+
+	for i, r := range s.Requests {
+		if _, err := s.requestTime(r.At); err != nil {
+			return fmt.Errorf("request %d: %w", i+1, err)
+		}
+		if r.Priority != "" {
+			if _, err := priority.ParseClass(r.Priority); err != nil {
+				return fmt.Errorf("request %d: %w", i+1, err)
+			}
+			// The engines don't check a cost per priority class
+			if r.Cost > 1 {
+				return fmt.Errorf("request %d: priority and cost are exclusive", i+1)
+			}
+		}
+		if r.Count < 0 || r.Every < 0 {
+			return fmt.Errorf("request %d: count and every must not be negative", i+1)
+		}
+		if r.Expect != "" && r.Expect != "allow" && r.Expect != "deny" {
+			return fmt.Errorf("request %d: expect must be allow or deny", i+1)
+		}
+	}
+
+	if s.Traffic != nil && s.Traffic.Requests <= 0 {
+		return fmt.Errorf("traffic: number of requests must be greater than 0")
+	}
+
+	if p := s.Expect.Peak; p != nil && p.Window <= 0 {
+		return fmt.Errorf("expect: peak window must be greater than 0")
+	}
+
+	return nil
+}
+
+// requestTime returns the time of a request, given as an offset from the start or a timestamp
+func (s *Scenario) requestTime(at string) (time.Time, error) {
+	if at == "" {
+		return s.Start, nil
+	}
+	if offset, err := time.ParseDuration(at); err == nil {
+		return s.Start.Add(offset), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, must be an offset like 1500ms or a RFC3339 timestamp", at)
+	}
+	return t, nil
+}
+
+// engineOptions converts the engine configuration to engine options, on top of the defaults of the run command
+func (s *Scenario) engineOptions() ([]engine.Option, error) {
+	typeName, ok := s.Engine["type"]
+	if !ok {
+		return nil, fmt.Errorf("engine: type is required")
+	}
+
+	// Write the configuration as an engine configuration of the compare command, in a stable order
+	var params []string
+	opts := defaultEngineOptions()
+	for key, value := range s.Engine {
+		switch key {
+		case "type":
+		case "priority-shares":
+			shares, err := priority.ParseShares(fmt.Sprint(value))
+			if err != nil {
+				return nil, fmt.Errorf("engine: invalid priority shares: %w", err)
+			}
+			opts = append(opts, engine.WithPriorityShares(shares))
+		default:
+			params = append(params, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	sort.Strings(params)
+
+	specOpts, err := engine.ParseSpec(fmt.Sprintf("%v:%s", typeName, strings.Join(params, ",")))
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	return append(opts, specOpts...), nil
+}
+
+// defaultEngineOptions are the defaults of the engine flags of the run command
+func defaultEngineOptions() []engine.Option {
+	return []engine.Option{
+		engine.WithCapacity(5),
+		engine.WithFillRate(1.0 / 500),
+		engine.WithConsumeRate(1),
+		engine.WithLeakRate(500 * time.Millisecond),
+		engine.WithWindowSize(1000),
+		engine.WithPeriod("day"),
+		engine.WithLocation(time.UTC),
+	}
+}
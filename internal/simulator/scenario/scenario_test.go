@@ -0,0 +1,135 @@
+package scenario
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestScenarios tests that the example scenarios pass.
+func TestScenarios(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.yaml")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			s, err := LoadFile(file)
+			assert.NoError(t, err)
+
+			outcome, err := s.Run(nil)
+			assert.NoError(t, err)
+			assert.Empty(t, outcome.Failures)
+		})
+	}
+}
+
+// TestScenario_Failures tests that mismatched decisions and bounds are reported.
+func TestScenario_Failures(t *testing.T) {
+	s, err := Load(strings.NewReader(`
+engine:
+  type: token-bucket
+  capacity: 2
+requests:
+  - at: 0s
+    count: 3
+    expect: allow
+  - at: 2026-01-01T00:00:00Z
+    key: alice
+expect:
+  allowed: {max: 2}
+  denied: {min: 2}
+  keys:
+    bob: {min: 1}
+`))
+	assert.NoError(t, err)
+
+	outcome, err := s.Run(nil)
+	assert.NoError(t, err)
+	assert.False(t, outcome.Passed())
+	assert.Equal(t, []string{
+		"request 1 (#3) at +0s: expected allow, got deny",
+		"allowed requests: 3, expected at most 2",
+		"denied requests: 1, expected at least 2",
+		`key "bob": no requests`,
+	}, outcome.Failures)
+	assert.Len(t, outcome.Result.Requests, 4)
+}
+
+// TestRun_OutOfOrder tests that the requests are sent in time order, not in the order of the file
+func TestRun_OutOfOrder(t *testing.T) {
+	s, err := Load(strings.NewReader(`
+engine:
+  type: token-bucket
+  capacity: 1
+  fill-duration: 500
+requests:
+  - at: 0s
+    count: 3
+    every: 1s
+  - at: 500ms
+    expect: allow
+  - at: 1500ms
+    expect: allow
+`))
+	assert.NoError(t, err)
+
+	outcome, err := s.Run(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, outcome.Failures)
+	for i := 1; i < len(outcome.Result.Requests); i++ {
+		assert.False(t, outcome.Result.Requests[i].ArriveAt.Before(outcome.Result.Requests[i-1].ArriveAt))
+	}
+}
+
+// TestRun_KeyedCost tests that the cost of a request of a key is checked against the limit of the key
+func TestRun_KeyedCost(t *testing.T) {
+	s, err := Load(strings.NewReader(`
+engine:
+  type: token-bucket
+  capacity: 3
+  fill-duration: 100000
+  per-key: true
+requests:
+  - {at: 0s, key: alice, cost: 3, expect: allow}
+  - {at: 1ms, key: alice, expect: deny}
+  - {at: 2ms, key: bob, cost: 2, expect: allow}
+  - {at: 3ms, key: bob, cost: 2, expect: deny}
+`))
+	assert.NoError(t, err)
+
+	outcome, err := s.Run(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, outcome.Failures)
+}
+
+// TestLoad_Invalid tests that invalid scenarios are rejected before running.
+func TestLoad_Invalid(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"no engine type":      "engine: {capacity: 1}\nrequests: [{at: 0s}]",
+		"unknown engine":      "engine: {type: magic}\nrequests: [{at: 0s}]",
+		"unknown param":       "engine: {type: token-bucket, speed: 1}\nrequests: [{at: 0s}]",
+		"unknown field":       "engine: {type: token-bucket}\nrequest: [{at: 0s}]",
+		"no traffic":          "engine: {type: token-bucket}",
+		"invalid time":        "engine: {type: token-bucket}\nrequests: [{at: soon}]",
+		"invalid expect":      "engine: {type: token-bucket}\nrequests: [{at: 0s, expect: maybe}]",
+		"invalid priority":    "engine: {type: token-bucket}\nrequests: [{at: 0s, priority: vip}]",
+		"no traffic requests": "engine: {type: token-bucket}\ntraffic: {rate: 1}",
+		"no peak window":      "engine: {type: token-bucket}\nrequests: [{at: 0s}]\nexpect: {peak: {max: 1}}",
+		"priority and cost":   "engine: {type: token-bucket}\nrequests: [{at: 0s, priority: normal, cost: 2}]",
+	} {
+		_, err := Load(strings.NewReader(yaml))
+		assert.Error(t, err, name)
+	}
+}
+
+// TestRun_InvalidEngine tests that engine configurations the engines reject are returned as errors.
+func TestRun_InvalidEngine(t *testing.T) {
+	s, err := Load(strings.NewReader("engine: {type: token-bucket, consume-rate: 10}\nrequests: [{at: 0s}]"))
+	assert.NoError(t, err)
+
+	_, err = s.Run(nil)
+	assert.Error(t, err, "Consume rate above the capacity should be rejected")
+}
@@ -0,0 +1,80 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/pkg/priorityqueue"
+)
+
+// ServiceDistribution is the distribution of the time to process a request.
+type ServiceDistribution string
+
+const (
+	ConstantService    ServiceDistribution = "constant"
+	ExponentialService ServiceDistribution = "exponential"
+)
+
+// engineQueue is the queue of the results whose requests are processed by the queue of the engine
+const engineQueue = "engine queue"
+
+// Service processes the allowed requests behind the rate limiter, like a concurrency limiter in front of
+// a backend: at most Concurrency requests are processed at a time, the others wait in a FIFO queue.
+type Service struct {
+	Concurrency  int64
+	Time         time.Duration // Mean time to process a request
+	Distribution ServiceDistribution
+}
+
+// NewService validates a service configuration
+func NewService(concurrency int64, serviceTime time.Duration, distribution ServiceDistribution) (*Service, error) {
+	if concurrency <= 0 {
+		return nil, fmt.Errorf("concurrency must be greater than 0")
+	}
+	if serviceTime <= 0 {
+		return nil, fmt.Errorf("service time must be greater than 0")
+	}
+	if distribution != ConstantService && distribution != ExponentialService {
+		return nil, fmt.Errorf("invalid service time distribution %q, must be constant or exponential", distribution)
+	}
+	return &Service{Concurrency: concurrency, Time: serviceTime, Distribution: distribution}, nil
+}
+
+func (s *Service) String() string {
+	return fmt.Sprintf("concurrency %d, %s service time %v", s.Concurrency, s.Distribution, s.Time)
+}
+
+// next returns the time to process a request
+func (s *Service) next(rng *rand.Rand) time.Duration {
+	if s.Distribution == ExponentialService {
+		return time.Duration(rng.ExpFloat64() * float64(s.Time))
+	}
+	return s.Time
+}
+
+// process sets the time every allowed request starts being processed, the requests must be in arrival order.
+// A request is processed as soon as it arrives if a slot is free, or when the earliest slot frees up.
+func (s *Service) process(requests []Request, rng *rand.Rand) {
+	// Times the busy slots free up, a slot is free if fewer than Concurrency are busy
+	busy := priorityqueue.NewPriorityQueue(uint64(s.Concurrency), func(a, b time.Time) bool {
+		return a.Before(b)
+	})
+
+	for i := range requests {
+		r := &requests[i]
+		if !r.Allowed {
+			continue
+		}
+
+		start := r.ArriveAt
+		if busy.IsFull() {
+			free, _ := busy.Pop()
+			if free.After(start) {
+				start = free
+			}
+		}
+		r.ProcessedAt = start
+		_ = busy.Push(start.Add(s.next(rng)))
+	}
+}
@@ -0,0 +1,131 @@
+package simulator
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+// TestNewService tests the validation of the service configuration
+func TestNewService(t *testing.T) {
+	_, err := NewService(4, 100*time.Millisecond, ExponentialService)
+	assert.NoError(t, err)
+
+	_, err = NewService(0, 100*time.Millisecond, ConstantService)
+	assert.Error(t, err, "Zero concurrency should fail")
+
+	_, err = NewService(4, 0, ConstantService)
+	assert.Error(t, err, "Zero service time should fail")
+
+	_, err = NewService(4, 100*time.Millisecond, "uniform")
+	assert.Error(t, err, "Unknown distribution should fail")
+}
+
+// TestService_Process tests that the allowed requests wait for a free slot, in arrival order
+func TestService_Process(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	service, err := NewService(2, 100*time.Millisecond, ConstantService)
+	assert.NoError(t, err)
+
+	requests := []Request{
+		{ArriveAt: start, Allowed: true},
+		{ArriveAt: start, Allowed: true},
+		{ArriveAt: start.Add(10 * time.Millisecond), Allowed: false},
+		{ArriveAt: start.Add(20 * time.Millisecond), Allowed: true},
+		{ArriveAt: start.Add(30 * time.Millisecond), Allowed: true},
+		{ArriveAt: start.Add(time.Second), Allowed: true},
+	}
+	service.process(requests, rand.New(rand.NewPCG(1, 0)))
+
+	expected := []time.Time{
+		start,
+		start,
+		{}, // Denied requests are not processed
+		start.Add(100 * time.Millisecond),
+		start.Add(100 * time.Millisecond),
+		start.Add(time.Second), // Slots are free again
+	}
+	for i, req := range requests {
+		assert.Equal(t, expected[i], req.ProcessedAt, "Request %d", i)
+	}
+
+	r := &Result{Requests: requests, Queue: service.String()}
+	q := r.Summary(time.Second).Queueing
+	assert.Equal(t, int64(5), q.Processed)
+	assert.Equal(t, int64(0), q.Waiting)
+	assert.Equal(t, 80*time.Millisecond, q.Delay.P99)
+	assert.Equal(t, 80*time.Millisecond, q.MaxQueueAge)
+}
+
+// TestSimulator_Queueing tests that the queueing delay comes from the queue of the engine, or from the service if set
+func TestSimulator_Queueing(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Leaky bucket", func(t *testing.T) {
+		ratelimiter, err := engine.EngineFactory(
+			engine.WithEngineType(engine.LeakyBucket),
+			engine.WithCapacity(5),
+			engine.WithLeakRate(100*time.Millisecond),
+			engine.WithVirtualTime(start),
+			engine.WithQuiet(true),
+		)
+		assert.NoError(t, err)
+
+		// A request every 50ms, twice the drain rate: the queue fills up
+		result := newTestSimulator(t,
+			WithRateLimiter(ratelimiter),
+			WithNumWorker(1),
+			WithNumRequests(40),
+			WithWaitTime(50),
+			WithVirtualTime(start),
+			WithQuiet(true),
+		).Run()
+
+		assert.Equal(t, engineQueue, result.Queue)
+		s := result.Summary(time.Second)
+		assert.Equal(t, s.Allowed, s.Queueing.Processed+s.Queueing.Waiting, "Allowed requests should be processed or queued")
+		assert.Greater(t, s.Queueing.Waiting, int64(0), "The queue should not be empty at the end")
+		assert.Greater(t, s.Queueing.Delay.P99, 400*time.Millisecond, "Requests should wait behind a full queue")
+		assert.LessOrEqual(t, s.Queueing.Delay.Max, 600*time.Millisecond, "Requests should wait at most capacity drain ticks")
+		for _, req := range result.Requests {
+			if !req.ProcessedAt.IsZero() {
+				assert.False(t, req.ProcessedAt.Before(req.ArriveAt), "Requests should be processed after they arrive")
+			}
+		}
+	})
+
+	t.Run("Service", func(t *testing.T) {
+		ratelimiter, err := engine.EngineFactory(
+			engine.WithEngineType(engine.TokenBucket),
+			engine.WithCapacity(10),
+			engine.WithFillRate(1.0/50),
+			engine.WithConsumeRate(1),
+			engine.WithVirtualTime(start),
+		)
+		assert.NoError(t, err)
+
+		service, err := NewService(1, 100*time.Millisecond, ExponentialService)
+		assert.NoError(t, err)
+
+		result := newTestSimulator(t,
+			WithRateLimiter(ratelimiter),
+			WithNumWorker(1),
+			WithNumRequests(20),
+			WithWaitTime(50),
+			WithService(service),
+			WithSeed(1),
+			WithVirtualTime(start),
+			WithQuiet(true),
+		).Run()
+
+		assert.Equal(t, "concurrency 1, exponential service time 100ms", result.Queue)
+		s := result.Summary(time.Second)
+		assert.Equal(t, s.Allowed, s.Queueing.Processed, "The service should process every allowed request")
+		assert.Greater(t, s.Queueing.Delay.Max, time.Duration(0), "Requests arriving twice as fast as they are served should wait")
+		assert.Equal(t, s.Queueing.Delay.Max, s.Queueing.MaxQueueAge)
+	})
+}
@@ -1,13 +1,18 @@
 package simulator
 
 import (
-	"crypto/rand"
+	"errors"
 	"fmt"
-	"math/big"
+	"io"
+	"math/rand/v2"
 	"sync"
 	"time"
 
 	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/trace"
+	"github.com/minhthong582000/rate-limiter/pkg/priorityqueue"
 )
 
 type Simulator struct {
@@ -16,19 +21,91 @@ type Simulator struct {
 	numRequests int64
 	waitTime    int64
 	jitter      int64
+	profile     profile.Profile // Arrival process of each worker
+	priorityMix *priority.Mix   // Send requests with random priorities if set
+	tenants     []Tenant        // Tenants sending their own traffic instead of the workers, if set
+	rate        float64         // Open loop only: requests/s sent regardless of the progress of the workers
+	duration    time.Duration   // Open loop only: duration of the traffic
+	service     *Service        // Processes the allowed requests instead of the queue of the engine, if set
+	seed        uint64          // Seed of the random generators, random if 0
+	virtual     bool            // Feed synthetic timestamps to the engine instead of sleeping
+	startTime   time.Time       // Virtual time only: time of the start of the simulation
+	speed       float64         // Replay only: speed of the replay compared to the trace
+	quiet       bool            // Don't log every request
+	recorder    *recorder       // Decisions of the current run
 	stopCh      <-chan struct{}
 }
 
-func NewSimulator(opts ...Option) *Simulator {
+// NewSimulator returns a simulator of the options, or an error if they can't be combined
+func NewSimulator(opts ...Option) (*Simulator, error) {
 	s := &Simulator{}
 	for _, opt := range opts {
 		opt(s)
 	}
 
-	return s
+	if s.seed == 0 {
+		s.seed = rand.Uint64()
+	}
+
+	if s.speed <= 0 {
+		s.speed = 1
+	}
+
+	if s.rate > 0 && s.numWorker <= 0 {
+		s.numWorker = 1
+	}
+
+	// The open loop sends requests at its own rate, it would silently ignore them
+	if s.rate > 0 && (len(s.tenants) > 0 || s.profile != nil || s.waitTime != 0 || s.jitter != 0) {
+		return nil, fmt.Errorf("tenants, arrival profiles and wait times can't be combined with an open loop")
+	}
+
+	if s.profile == nil && (s.waitTime != 0 || s.jitter != 0) {
+		// Send requests every wait time with a random jitter by default, back to back without wait time
+		p, err := profile.New(profile.Constant, profile.Params{
+			Interval: time.Duration(s.waitTime) * time.Millisecond,
+			Jitter:   time.Duration(s.jitter) * time.Millisecond,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid wait time or jitter: %w", err)
+		}
+		s.profile = p
+	}
+
+	return s, nil
+}
+
+// now returns the current time of the simulation
+func (s *Simulator) now() time.Time {
+	if s.virtual {
+		return s.startTime
+	}
+	return time.Now()
 }
 
-func (s *Simulator) worker(id int64, reqCh <-chan int64) {
+// sleepUntil returns false if the simulator is stopped before t
+func (s *Simulator) sleepUntil(t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.stopCh:
+		return false
+	}
+}
+
+// newRand returns the random generator of a worker, so that runs with the same seed are reproducible
+func (s *Simulator) newRand(id int64) *rand.Rand {
+	return rand.New(rand.NewPCG(s.seed, uint64(id)))
+}
+
+func (s *Simulator) worker(id int64, start time.Time, reqCh <-chan int64) {
+	rng := s.newRand(id)
+	elapsed := time.Duration(0) // Arrival time of the last request since start
+	first := true
+
 	for {
 		select {
 		case <-s.stopCh:
@@ -38,26 +115,270 @@ func (s *Simulator) worker(id int64, reqCh <-chan int64) {
 				return
 			}
 
-			rj := int64(0)
-			if s.jitter > 0 {
-				randomJitter, _ := rand.Int(rand.Reader, big.NewInt(s.jitter*2))
-				rj = randomJitter.Int64() - s.jitter
+			// The first request is sent at once, then every request waits for its arrival time given by the profile.
+			// Arrivals are scheduled from the start time, so they don't drift with the processing time.
+			if !first && s.profile != nil {
+				elapsed += s.profile.Next(elapsed, rng)
+				if !s.sleepUntil(start.Add(elapsed)) {
+					return
+				}
+			}
+			first = false
+
+			s.send(Request{Worker: id, ID: req, ArriveAt: time.Now()}, rng)
+		}
+	}
+}
+
+// tenantWorker sends the requests of a tenant, following the arrival process of the tenant
+func (s *Simulator) tenantWorker(id int64, t Tenant, numRequests int64, start time.Time) {
+	rng := s.newRand(id)
+	elapsed := time.Duration(0)
+
+	for req := int64(0); req < numRequests; req++ {
+		// The first request is sent at once, like the workers
+		if req > 0 {
+			elapsed += t.Profile.Next(elapsed, rng)
+			if !s.sleepUntil(start.Add(elapsed)) {
+				return
+			}
+		}
+		s.send(Request{Worker: id, ID: req, Key: t.Key, ArriveAt: time.Now()}, rng)
+	}
+}
+
+// send checks a request with the rate limiter, the request has its worker, ID, key and arrival time set.
+// Requests of tenants are checked with the key of the tenant, without priority.
+func (s *Simulator) send(r Request, rng *rand.Rand) {
+	r.Cost = 1
+	if r.Key != "" {
+		r.Allowed = engine.AllowAtKey(s.ratelimiter, r.ArriveAt, r.Key)
+	} else if s.priorityMix != nil {
+		class := s.priorityMix.Pick(rng.Float64())
+		r.Class = class.String()
+		r.Allowed = engine.AllowAtPriority(s.ratelimiter, r.ArriveAt, class)
+	} else {
+		r.Allowed = s.ratelimiter.AllowAt(r.ArriveAt)
+	}
+	if !s.virtual && !r.ScheduledAt.IsZero() {
+		r.Latency = time.Since(r.ScheduledAt)
+	}
+	s.recorder.record(r)
+
+	if s.quiet {
+		return
+	}
+
+	decision := "DENIED"
+	if r.Allowed {
+		decision = "ALLOWED"
+	}
+	if r.Key != "" {
+		fmt.Printf("Request %d.%d %s, key=%q, ts=\"%v\"\n", r.Worker, r.ID, decision, r.Key, r.ArriveAt)
+	} else if r.Class != "" {
+		fmt.Printf("Request %d.%d %s, class=%s, ts=\"%v\"\n", r.Worker, r.ID, decision, r.Class, r.ArriveAt)
+	} else {
+		fmt.Printf("Request %d.%d %s, ts=\"%v\"\n", r.Worker, r.ID, decision, r.ArriveAt)
+	}
+}
+
+// openLoopBuffer is the number of scheduled requests waiting for a free worker before the generator blocks
+const openLoopBuffer = 1024
+
+// virtualWorker is the state of a worker or a tenant in a virtual time simulation
+type virtualWorker struct {
+	id        int64
+	key       string // Key of the tenant, empty for workers
+	profile   profile.Profile
+	rng       *rand.Rand
+	elapsed   time.Duration // Arrival time of the next request since start
+	remaining int64         // Requests left to send
+}
+
+// virtualWorkers returns the senders of a virtual time simulation: the tenants if any, the workers otherwise
+func (s *Simulator) virtualWorkers() []*virtualWorker {
+	var workers []*virtualWorker
+	if len(s.tenants) > 0 {
+		for i, count := range splitRequests(s.tenants, s.numRequests) {
+			if count > 0 {
+				workers = append(workers, &virtualWorker{
+					id: int64(i + 1), key: s.tenants[i].Key, profile: s.tenants[i].Profile, remaining: count,
+				})
+			}
+		}
+	} else {
+		for i := int64(1); i <= s.numWorker; i++ {
+			workers = append(workers, &virtualWorker{id: i, profile: s.profile, remaining: s.numRequests})
+		}
+	}
+
+	// The first requests are sent at the start time, like in real time
+	for _, w := range workers {
+		w.rng = s.newRand(w.id)
+	}
+	return workers
+}
+
+// runVirtual is a discrete-event simulation: the worker with the earliest arrival
+// sends the next request, and the clock jumps to its arrival time without sleeping.
+func (s *Simulator) runVirtual() {
+	senders := s.virtualWorkers()
+	if len(senders) == 0 {
+		return
+	}
+
+	workers := priorityqueue.NewPriorityQueue(uint64(len(senders)), func(a, b *virtualWorker) bool {
+		return a.elapsed < b.elapsed
+	})
+	for _, w := range senders {
+		_ = workers.Push(w)
+	}
+
+	for req := int64(0); req < s.numRequests && !workers.IsEmpty(); req++ {
+		select {
+		case <-s.stopCh:
+			return
+		default:
+		}
+
+		w, _ := workers.Pop()
+		s.send(Request{Worker: w.id, ID: req, Key: w.key, ArriveAt: s.startTime.Add(w.elapsed)}, w.rng)
+
+		w.remaining--
+		if w.remaining > 0 {
+			if w.profile != nil {
+				w.elapsed += w.profile.Next(w.elapsed, w.rng)
+			}
+			_ = workers.Push(w)
+		}
+	}
+}
+
+// runTenants sends the requests of every tenant in parallel
+func (s *Simulator) runTenants() {
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i, count := range splitRequests(s.tenants, s.numRequests) {
+		wg.Add(1)
+		go func(id int64, t Tenant, count int64) {
+			defer wg.Done()
+			s.tenantWorker(id, t, count, start)
+		}(int64(i+1), s.tenants[i], count)
+	}
+
+	wg.Wait()
+}
+
+// runOpenLoop sends requests at the rate for the duration, whatever the progress of the workers.
+// A closed loop waits for a worker before sending its next request, so a slow rate limiter slows down
+// the traffic and the requests that should have been sent meanwhile are never measured (coordinated omission).
+// Here requests are scheduled up front: a request waiting for a free worker is sent late,
+// and its latency is counted from its scheduled time, not from the time a worker picked it up.
+func (s *Simulator) runOpenLoop() {
+	interval := time.Duration(float64(time.Second) / s.rate)
+	s.recorder.openLoop = &OpenLoop{Rate: s.rate, Duration: s.duration}
+
+	start := s.now()
+	if s.virtual {
+		// Requests are sent at their scheduled time, by the workers in turn
+		rngs := make([]*rand.Rand, s.numWorker)
+		for i := range rngs {
+			rngs[i] = s.newRand(int64(i + 1))
+		}
+		for req := int64(0); time.Duration(req)*interval < s.duration; req++ {
+			select {
+			case <-s.stopCh:
+				return
+			default:
 			}
 
-			now := time.Now()
-			if s.ratelimiter.AllowAt(now) {
-				fmt.Printf("Request %d.%d ALLOWED, ts=\"%v\"\n", id, req, now)
-			} else {
-				fmt.Printf("Request %d.%d DENIED, ts=\"%v\"\n", id, req, now)
+			at := start.Add(time.Duration(req) * interval)
+			worker := req%s.numWorker + 1
+			s.recorder.schedule()
+			s.send(Request{Worker: worker, ID: req, ArriveAt: at, ScheduledAt: at}, rngs[worker-1])
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	requestCh := make(chan Request, openLoopBuffer)
+	for i := int64(1); i <= s.numWorker; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			rng := s.newRand(id)
+			for r := range requestCh {
+				r.Worker = id
+				r.ArriveAt = time.Now()
+				s.send(r, rng)
 			}
-			time.Sleep(time.Duration(s.waitTime+rj) * time.Millisecond)
+		}(i)
+	}
+
+	// The generator only blocks when the buffer is full, the requests are still scheduled on time
+	for req := int64(0); time.Duration(req)*interval < s.duration; req++ {
+		at := start.Add(time.Duration(req) * interval)
+		if !s.sleepUntil(at) {
+			break
 		}
+		s.recorder.schedule()
+		requestCh <- Request{ID: req, ScheduledAt: at}
+	}
+
+	close(requestCh)
+	wg.Wait()
+}
+
+// startRecording starts recording the decisions of a run, and the requests processed by the queue of the engine
+func (s *Simulator) startRecording() {
+	s.recorder = newRecorder(s.ratelimiter, s.now())
+	if s.service == nil {
+		s.recorder.queued(s.ratelimiter)
 	}
 }
 
-func (s *Simulator) Run() {
+// result returns the decisions of the run, with the processing times of the service if set
+func (s *Simulator) result() *Result {
+	if q, ok := s.ratelimiter.(engine.QueueEngine); ok && s.recorder.queue != "" {
+		q.OnProcessed(nil)
+	}
+
+	r := s.recorder.result()
+	if s.service != nil {
+		// The service is simulated after the run, from the arrivals of the allowed requests
+		s.service.process(r.Requests, s.newRand(0))
+		r.Queue = s.service.String()
+	}
+	return r
+}
+
+// Run sends the requests to the rate limiter and returns the decisions,
+// stopping early if the stop channel is closed.
+func (s *Simulator) Run() *Result {
+	s.startRecording()
+	for _, t := range s.tenants {
+		s.recorder.tenants = append(s.recorder.tenants, t.Key)
+	}
+
+	if s.rate > 0 {
+		s.runOpenLoop()
+		return s.result()
+	}
+
+	if s.virtual {
+		s.runVirtual()
+		return s.result()
+	}
+
+	if len(s.tenants) > 0 {
+		s.runTenants()
+		return s.result()
+	}
+
 	var wg sync.WaitGroup
 	requestCh := make(chan int64, s.numRequests)
+	start := time.Now()
 
 	// Start workers
 	for i := int64(0); i < s.numWorker; i++ {
@@ -66,11 +387,15 @@ func (s *Simulator) Run() {
 		go func(id int64) {
 			defer func() {
 				wg.Done()
-				fmt.Printf("Worker %d stopped\n", id)
+				if !s.quiet {
+					fmt.Printf("Worker %d stopped\n", id)
+				}
 			}()
 
-			fmt.Printf("Worker %d started\n", id)
-			s.worker(id, requestCh)
+			if !s.quiet {
+				fmt.Printf("Worker %d started\n", id)
+			}
+			s.worker(id, start, requestCh)
 		}(i + 1)
 	}
 
@@ -81,7 +406,7 @@ func (s *Simulator) Run() {
 		case <-s.stopCh:
 			close(requestCh)
 			wg.Wait()
-			return
+			return s.result()
 		default:
 			requestCh <- i
 		}
@@ -89,4 +414,66 @@ func (s *Simulator) Run() {
 
 	close(requestCh)
 	wg.Wait()
+	return s.result()
+}
+
+// Replay feeds the requests recorded in a trace to the rate limiter, keeping the time between them
+// scaled by the speed. In virtual time, the first request arrives at the start time.
+// The decisions made before a trace error are returned with the error.
+func (s *Simulator) Replay(r *trace.Reader) (*Result, error) {
+	s.startRecording()
+
+	var first time.Time
+	start := time.Now()
+	if s.virtual {
+		start = s.startTime
+	}
+
+	for req := int64(0); ; req++ {
+		e, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return s.result(), nil
+		}
+		if err != nil {
+			return s.result(), err
+		}
+
+		if req == 0 {
+			first = e.Time
+		}
+		arriveAt := start.Add(time.Duration(float64(e.Time.Sub(first)) / s.speed))
+
+		if s.virtual {
+			select {
+			case <-s.stopCh:
+				return s.result(), nil
+			default:
+			}
+		} else {
+			if !s.sleepUntil(arriveAt) {
+				return s.result(), nil
+			}
+			arriveAt = time.Now()
+		}
+
+		allowed := s.replayOne(e, arriveAt)
+		s.recorder.record(Request{ID: req, Key: e.Key, Cost: e.Cost, ArriveAt: arriveAt, Allowed: allowed})
+		if s.quiet {
+			continue
+		}
+		if allowed {
+			fmt.Printf("Request %d ALLOWED, key=%q, cost=%d, ts=\"%v\"\n", req, e.Key, e.Cost, arriveAt)
+		} else {
+			fmt.Printf("Request %d DENIED, key=%q, cost=%d, ts=\"%v\"\n", req, e.Key, e.Cost, arriveAt)
+		}
+	}
+}
+
+// replayOne checks a recorded request with the rate limiter,
+// keyed engines track the request per key, other engines take its cost into account.
+func (s *Simulator) replayOne(e trace.Event, arriveAt time.Time) bool {
+	if k, ok := s.ratelimiter.(engine.KeyedEngine); ok && e.Key != "" {
+		return k.AllowAtKey(arriveAt, e.Key)
+	}
+	return engine.AllowNAt(s.ratelimiter, arriveAt, e.Cost)
 }
@@ -1,6 +1,7 @@
 package simulator
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -8,8 +9,18 @@ import (
 	"go.uber.org/mock/gomock"
 
 	"github.com/minhthong582000/rate-limiter/internal/engine/mocks"
+	"github.com/minhthong582000/rate-limiter/internal/engine/priority"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/trace"
 )
 
+// newTestSimulator returns a simulator of valid options
+func newTestSimulator(t *testing.T, opts ...Option) *Simulator {
+	sim, err := NewSimulator(opts...)
+	assert.NoError(t, err)
+	return sim
+}
+
 // TestSimulator_Run tests the normal operation of the simulator
 func TestSimulator_Run(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -122,7 +133,7 @@ func TestSimulator_Run(t *testing.T) {
 			stopCh := make(chan struct{})
 			defer close(stopCh)
 
-			sim := NewSimulator(
+			sim := newTestSimulator(t,
 				WithRateLimiter(mockEngine),
 				WithNumWorker(tc.numWorker),
 				WithNumRequests(tc.numRequests),
@@ -154,7 +165,7 @@ func TestSimulator_Shutdown(t *testing.T) {
 
 	stopCh := make(chan struct{})
 
-	sim := NewSimulator(
+	sim := newTestSimulator(t,
 		WithRateLimiter(mockEngine),
 		WithNumWorker(2),
 		WithNumRequests(5),
@@ -191,7 +202,7 @@ func TestSimulator_EarlyStop(t *testing.T) {
 	stopCh := make(chan struct{})
 	close(stopCh) // Stop immediately
 
-	sim := NewSimulator(
+	sim := newTestSimulator(t,
 		WithRateLimiter(mockEngine),
 		WithNumWorker(2),
 		WithNumRequests(5),
@@ -203,3 +214,294 @@ func TestSimulator_EarlyStop(t *testing.T) {
 	// Run the simulator
 	sim.Run()
 }
+
+// TestSimulator_PriorityMix tests that requests carry a priority when a mix is configured
+func TestSimulator_PriorityMix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// MockEngine doesn't support priorities, the simulator should fall back to AllowAt
+	mockEngine := mocks.NewMockEngine(ctrl)
+	mockEngine.EXPECT().AllowAt(gomock.Any()).Return(true).Times(5)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	sim := newTestSimulator(t,
+		WithRateLimiter(mockEngine),
+		WithNumWorker(2),
+		WithNumRequests(5),
+		WithWaitTime(10),
+		WithPriorityMix(priority.Mix{1, 1, 1}),
+		WithStopChannel(stopCh),
+	)
+	sim.Run()
+}
+
+// TestSimulator_Profile tests that workers send requests following the arrival profile
+func TestSimulator_Profile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var arrivals []time.Time
+	mockEngine := mocks.NewMockEngine(ctrl)
+	mockEngine.EXPECT().AllowAt(gomock.Any()).DoAndReturn(func(arriveAt time.Time) bool {
+		arrivals = append(arrivals, arriveAt)
+		return true
+	}).Times(20)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	p, err := profile.New(profile.Poisson, profile.Params{Rate: 1000})
+	assert.NoError(t, err)
+
+	sim := newTestSimulator(t,
+		WithRateLimiter(mockEngine),
+		WithNumWorker(1),
+		WithNumRequests(20),
+		WithProfile(p),
+		WithStopChannel(stopCh),
+	)
+
+	start := time.Now()
+	sim.Run()
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "20 requests at 1000 requests/s should be fast")
+	for i := 1; i < len(arrivals); i++ {
+		assert.False(t, arrivals[i].Before(arrivals[i-1]), "Arrivals should be in order")
+	}
+}
+
+// TestSimulator_SendThenWait tests that a worker sends its first request at once and waits after every request
+func TestSimulator_SendThenWait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, virtual := range []bool{false, true} {
+		startTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		var arrivals []time.Time
+		mockEngine := mocks.NewMockEngine(ctrl)
+		mockEngine.EXPECT().AllowAt(gomock.Any()).DoAndReturn(func(arriveAt time.Time) bool {
+			arrivals = append(arrivals, arriveAt)
+			return true
+		}).Times(2)
+
+		opts := []Option{WithRateLimiter(mockEngine), WithNumWorker(1), WithNumRequests(2), WithWaitTime(100)}
+		if virtual {
+			opts = append(opts, WithVirtualTime(startTime))
+		} else {
+			startTime = time.Now()
+		}
+		newTestSimulator(t, opts...).Run()
+
+		assert.Len(t, arrivals, 2)
+		assert.Less(t, arrivals[0].Sub(startTime), 50*time.Millisecond, "The first request should be sent at once")
+		assert.GreaterOrEqual(t, arrivals[1].Sub(arrivals[0]), 100*time.Millisecond, "The next request should wait")
+	}
+}
+
+// TestNewSimulator_InvalidWaitTime tests that an invalid wait time or jitter is rejected instead of running without profile
+func TestNewSimulator_InvalidWaitTime(t *testing.T) {
+	_, err := NewSimulator(WithWaitTime(10), WithJitter(20))
+	assert.Error(t, err)
+	_, err = NewSimulator(WithWaitTime(-1))
+	assert.Error(t, err)
+}
+
+// TestSimulator_VirtualTime tests that a virtual time simulation is fast and reproducible with the same seed
+func TestSimulator_VirtualTime(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	startTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(seed uint64) []time.Time {
+		var arrivals []time.Time
+		mockEngine := mocks.NewMockEngine(ctrl)
+		mockEngine.EXPECT().AllowAt(gomock.Any()).DoAndReturn(func(arriveAt time.Time) bool {
+			arrivals = append(arrivals, arriveAt)
+			return true
+		}).Times(100)
+
+		// 1 request/s per worker would take almost a minute in real time
+		p, err := profile.New(profile.Poisson, profile.Params{Rate: 1})
+		assert.NoError(t, err)
+
+		sim := newTestSimulator(t,
+			WithRateLimiter(mockEngine),
+			WithNumWorker(2),
+			WithNumRequests(100),
+			WithProfile(p),
+			WithSeed(seed),
+			WithVirtualTime(startTime),
+		)
+		sim.Run()
+		return arrivals
+	}
+
+	start := time.Now()
+	first := run(42)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "Virtual time simulation should not sleep")
+
+	assert.Equal(t, first, run(42), "Same seed should produce the same arrivals")
+	assert.NotEqual(t, first, run(43), "Different seeds should produce different arrivals")
+
+	assert.False(t, first[0].Before(startTime), "Arrivals should start at the start time")
+	for i := 1; i < len(first); i++ {
+		assert.False(t, first[i].Before(first[i-1]), "Arrivals should be in order")
+	}
+}
+
+// TestSimulator_OpenLoop tests that an open loop sends requests at the rate, even when the rate limiter is slow
+func TestSimulator_OpenLoop(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("Virtual time", func(t *testing.T) {
+		startTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		mockEngine := mocks.NewMockEngine(ctrl)
+		mockEngine.EXPECT().AllowAt(gomock.Any()).Return(true).Times(10)
+
+		result := newTestSimulator(t,
+			WithRateLimiter(mockEngine),
+			WithNumWorker(3),
+			WithOpenLoop(10, time.Second),
+			WithVirtualTime(startTime),
+			WithQuiet(true),
+		).Run()
+
+		assert.Len(t, result.Requests, 10)
+		for i, req := range result.Requests {
+			at := startTime.Add(time.Duration(i) * 100 * time.Millisecond)
+			assert.Equal(t, at, req.ArriveAt, "Requests should arrive at their scheduled time")
+			assert.Equal(t, at, req.ScheduledAt)
+			assert.Equal(t, int64(i%3+1), req.Worker, "Workers should send the requests in turn")
+		}
+
+		s := result.Summary(time.Second)
+		assert.NotNil(t, s.OpenLoop)
+		assert.Equal(t, int64(10), s.OpenLoop.Scheduled)
+		assert.Equal(t, int64(10), s.OpenLoop.Sent)
+		assert.InDelta(t, 10.0, s.OpenLoop.SendRate, 1e-9)
+		assert.Equal(t, Percentiles{}, s.OpenLoop.Latency, "Virtual time requests should have no latency")
+	})
+
+	t.Run("Slow rate limiter", func(t *testing.T) {
+		// A single worker takes 20ms per request while a request is scheduled every 5ms:
+		// a closed loop would send 5 requests, the open loop sends all 20 and counts their wait.
+		mockEngine := mocks.NewMockEngine(ctrl)
+		mockEngine.EXPECT().AllowAt(gomock.Any()).DoAndReturn(func(time.Time) bool {
+			time.Sleep(20 * time.Millisecond)
+			return true
+		}).Times(20)
+
+		result := newTestSimulator(t,
+			WithRateLimiter(mockEngine),
+			WithNumWorker(1),
+			WithOpenLoop(200, 100*time.Millisecond),
+			WithQuiet(true),
+		).Run()
+
+		assert.Len(t, result.Requests, 20)
+		s := result.Summary(time.Second)
+		assert.Equal(t, int64(20), s.OpenLoop.Scheduled)
+		assert.Greater(t, s.OpenLoop.Latency.Max, 200*time.Millisecond, "Late requests should count the time waiting for the worker")
+		assert.GreaterOrEqual(t, s.OpenLoop.Latency.P99, s.OpenLoop.Latency.P50)
+	})
+
+	t.Run("Early stop", func(t *testing.T) {
+		stopCh := make(chan struct{})
+		mockEngine := mocks.NewMockEngine(ctrl)
+		mockEngine.EXPECT().AllowAt(gomock.Any()).Return(true).AnyTimes()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(stopCh)
+		}()
+
+		start := time.Now()
+		result := newTestSimulator(t,
+			WithRateLimiter(mockEngine),
+			WithNumWorker(2),
+			WithOpenLoop(100, time.Minute),
+			WithQuiet(true),
+			WithStopChannel(stopCh),
+		).Run()
+
+		assert.Less(t, time.Since(start), time.Second, "Simulation should stop early")
+		assert.Equal(t, result.OpenLoop.Scheduled, int64(len(result.Requests)), "Scheduled requests should all be sent")
+		assert.Less(t, result.OpenLoop.Scheduled, int64(6000))
+	})
+
+	t.Run("Arrival process", func(t *testing.T) {
+		p, err := profile.New(profile.Poisson, profile.Params{Rate: 10})
+		assert.NoError(t, err)
+
+		_, err = NewSimulator(WithOpenLoop(10, time.Second), WithProfile(p))
+		assert.Error(t, err, "Profile should be rejected")
+		_, err = NewSimulator(WithOpenLoop(10, time.Second), WithWaitTime(100))
+		assert.Error(t, err, "Wait time should be rejected")
+		_, err = NewSimulator(WithOpenLoop(10, time.Second), WithTenants(Tenant{Key: "a", Share: 1}))
+		assert.Error(t, err, "Tenants should be rejected")
+	})
+}
+
+// TestSimulator_Replay tests that a trace is replayed with the time between requests scaled by the speed
+func TestSimulator_Replay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	input := `timestamp,key
+2024-06-04T10:00:00Z,alice
+2024-06-04T10:00:01Z,bob
+2024-06-04T10:00:03Z,alice
+`
+	startTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var arrivals []time.Time
+	mockEngine := mocks.NewMockEngine(ctrl)
+	mockEngine.EXPECT().AllowAt(gomock.Any()).DoAndReturn(func(arriveAt time.Time) bool {
+		arrivals = append(arrivals, arriveAt)
+		return len(arrivals) < 3
+	}).Times(3)
+
+	reader, err := trace.NewReader(strings.NewReader(input), trace.CSV)
+	assert.NoError(t, err)
+
+	sim := newTestSimulator(t,
+		WithRateLimiter(mockEngine),
+		WithSpeed(2),
+		WithVirtualTime(startTime),
+	)
+	result, err := sim.Replay(reader)
+	assert.NoError(t, err)
+	assert.Len(t, result.Requests, 3)
+	assert.Equal(t, "bob", result.Requests[1].Key)
+	assert.False(t, result.Requests[2].Allowed)
+
+	assert.Equal(t, []time.Time{
+		startTime,
+		startTime.Add(500 * time.Millisecond),
+		startTime.Add(1500 * time.Millisecond),
+	}, arrivals)
+}
+
+// TestSimulator_ReplayInvalidTrace tests that trace errors are returned
+func TestSimulator_ReplayInvalidTrace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEngine := mocks.NewMockEngine(ctrl)
+	mockEngine.EXPECT().AllowAt(gomock.Any()).Return(true).Times(1)
+
+	reader, err := trace.NewReader(strings.NewReader("1,a\nnot-a-time,b\n"), trace.CSV)
+	assert.NoError(t, err)
+
+	sim := newTestSimulator(t,
+		WithRateLimiter(mockEngine),
+		WithVirtualTime(time.Now()),
+	)
+	result, err := sim.Replay(reader)
+	assert.Error(t, err)
+	assert.Len(t, result.Requests, 1, "Requests before the error should be returned")
+}
@@ -0,0 +1,204 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+)
+
+// Tenant is a source of traffic with its own key and arrival process, e.g. a customer of a multi-tenant API.
+type Tenant struct {
+	Key     string
+	Profile profile.Profile
+	Share   float64 // Share of the requests of the simulation sent by the tenant, relative to the other tenants
+}
+
+// ParseTenant parses a tenant written as "<key>[:param=value,...]", e.g. "noisy:share=8,profile=poisson,rate=100".
+// Params are share, profile and the profile params named after the flags of the run command without the
+// "profile-" prefix (rate, peak-rate, period, on, off), plus wait-time and jitter. Durations are in milliseconds.
+// Unset profile params are taken from the given defaults, the default share is 1.
+func ParseTenant(spec string, profileType profile.Type, defaults profile.Params) (Tenant, error) {
+	key, params, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	t := Tenant{Key: strings.TrimSpace(key), Share: 1}
+	if t.Key == "" {
+		return Tenant{}, fmt.Errorf("tenant key must not be empty")
+	}
+
+	p := defaults
+	for _, pair := range strings.Split(params, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return Tenant{}, fmt.Errorf("invalid tenant parameter %q, must be key=value", pair)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		if name == "profile" {
+			profileType = profile.Type(value)
+			continue
+		}
+
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			return Tenant{}, fmt.Errorf("invalid tenant parameter %q: must be a number greater than or equal to 0", name)
+		}
+		ms := time.Duration(f * float64(time.Millisecond))
+		switch name {
+		case "share":
+			t.Share = f
+		case "rate":
+			p.Rate = f
+		case "peak-rate":
+			p.PeakRate = f
+		case "period":
+			p.Period = ms
+		case "on":
+			p.On = ms
+		case "off":
+			p.Off = ms
+		case "wait-time":
+			p.Interval = ms
+		case "jitter":
+			p.Jitter = ms
+		default:
+			return Tenant{}, fmt.Errorf("invalid tenant parameter %q: unknown parameter", name)
+		}
+	}
+
+	var err error
+	t.Profile, err = profile.New(profileType, p)
+	if err != nil {
+		return Tenant{}, fmt.Errorf("tenant %q: invalid %s profile: %w", t.Key, profileType, err)
+	}
+
+	return t, nil
+}
+
+// splitRequests divides the requests between the tenants in proportion to their share
+func splitRequests(tenants []Tenant, numRequests int64) []int64 {
+	total := 0.0
+	for _, t := range tenants {
+		total += t.Share
+	}
+
+	counts := make([]int64, len(tenants))
+	if total <= 0 {
+		return counts
+	}
+
+	sent := int64(0)
+	cumulative := 0.0
+	for i, t := range tenants {
+		// Round the cumulative count so the counts add up to the number of requests
+		cumulative += t.Share
+		end := int64(math.Round(cumulative / total * float64(numRequests)))
+		counts[i] = end - sent
+		sent = end
+	}
+	return counts
+}
+
+// TenantSummary counts the decisions on the requests of a tenant.
+type TenantSummary struct {
+	Key        string  `json:"key"`
+	Total      int64   `json:"total"`
+	Allowed    int64   `json:"allowed"`
+	Denied     int64   `json:"denied"`
+	AdmitRate  float64 `json:"admit_rate"`
+	Throughput float64 `json:"throughput"` // Allowed requests/s over the whole simulation
+	// Allowed requests the tenant would get from a max-min fair split of all the allowed requests:
+	// tenants asking for less than an equal share get what they ask for, the rest is split equally.
+	FairShare float64 `json:"fair_share"`
+}
+
+// maxMinShares splits the capacity between demands with max-min fairness
+func maxMinShares(demands []float64, capacity float64) []float64 {
+	order := make([]int, len(demands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return demands[order[a]] < demands[order[b]]
+	})
+
+	shares := make([]float64, len(demands))
+	for n, i := range order {
+		equal := capacity / float64(len(order)-n)
+		shares[i] = math.Min(demands[i], equal)
+		capacity -= shares[i]
+	}
+	return shares
+}
+
+// jainIndex returns Jain's fairness index of the allocations, from 1/n when one gets everything to 1 when all are equal
+func jainIndex(x []float64) float64 {
+	sum, squares := 0.0, 0.0
+	for _, v := range x {
+		sum += v
+		squares += v * v
+	}
+	if squares == 0 {
+		return 1
+	}
+	return sum * sum / (float64(len(x)) * squares)
+}
+
+// tenantSummaries counts the decisions per tenant and returns the fairness of the allowed requests:
+// Jain's index of the allowed requests of each tenant relative to its max-min fair share.
+func (r *Result) tenantSummaries(duration time.Duration) ([]TenantSummary, float64) {
+	if len(r.Tenants) == 0 {
+		return nil, 0
+	}
+
+	summaries := make([]TenantSummary, len(r.Tenants))
+	index := map[string]int{}
+	for i, key := range r.Tenants {
+		summaries[i].Key = key
+		index[key] = i
+	}
+
+	totalAllowed := 0.0
+	for _, req := range r.Requests {
+		i, ok := index[req.Key]
+		if !ok {
+			continue
+		}
+		summaries[i].Total++
+		if req.Allowed {
+			summaries[i].Allowed++
+			totalAllowed++
+		} else {
+			summaries[i].Denied++
+		}
+	}
+
+	demands := make([]float64, len(summaries))
+	for i, t := range summaries {
+		demands[i] = float64(t.Total)
+	}
+	shares := maxMinShares(demands, totalAllowed)
+
+	var normalized []float64
+	for i := range summaries {
+		t := &summaries[i]
+		t.AdmitRate = admitRate(t.Allowed, t.Total)
+		if duration > 0 {
+			t.Throughput = float64(t.Allowed) / duration.Seconds()
+		}
+		t.FairShare = shares[i]
+		if t.FairShare > 0 {
+			normalized = append(normalized, float64(t.Allowed)/t.FairShare)
+		}
+	}
+
+	return summaries, jainIndex(normalized)
+}
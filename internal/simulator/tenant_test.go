@@ -0,0 +1,110 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/simulator/profile"
+)
+
+// TestParseTenant tests the parsing of tenants and their profile.
+func TestParseTenant(t *testing.T) {
+	defaults := profile.Params{Interval: 100 * time.Millisecond, Rate: 10}
+
+	tenant, err := ParseTenant("quiet", profile.Constant, defaults)
+	assert.NoError(t, err)
+	assert.Equal(t, "quiet", tenant.Key)
+	assert.Equal(t, 1.0, tenant.Share, "Default share should be 1")
+	assert.NotNil(t, tenant.Profile)
+
+	tenant, err = ParseTenant(" noisy : share=8, profile=poisson, rate=100 ", profile.Constant, defaults)
+	assert.NoError(t, err)
+	assert.Equal(t, "noisy", tenant.Key)
+	assert.Equal(t, 8.0, tenant.Share)
+
+	for _, spec := range []string{
+		"",
+		":share=1",
+		"a:share",
+		"a:share=-1",
+		"a:rate=fast",
+		"a:unknown=1",
+		"a:profile=unknown",
+		"a:profile=poisson,rate=0",
+	} {
+		_, err := ParseTenant(spec, profile.Constant, defaults)
+		assert.Error(t, err, "Tenant %q should be invalid", spec)
+	}
+}
+
+// TestSplitRequests tests that the requests are divided in proportion to the shares.
+func TestSplitRequests(t *testing.T) {
+	tenants := []Tenant{{Share: 8}, {Share: 1}, {Share: 1}}
+	assert.Equal(t, []int64{80, 10, 10}, splitRequests(tenants, 100))
+
+	counts := splitRequests([]Tenant{{Share: 1}, {Share: 1}, {Share: 1}}, 100)
+	assert.Equal(t, int64(100), counts[0]+counts[1]+counts[2], "Counts should add up to the number of requests")
+
+	assert.Equal(t, []int64{0, 0}, splitRequests([]Tenant{{Share: 0}, {Share: 0}}, 100))
+}
+
+// TestFairness tests the max-min fair shares and Jain's index.
+func TestFairness(t *testing.T) {
+	// The small demand is fully served, the rest is split equally
+	assert.Equal(t, []float64{45, 10, 45}, maxMinShares([]float64{100, 10, 100}, 100))
+	assert.Equal(t, []float64{10, 20}, maxMinShares([]float64{10, 20}, 100), "Demands below the capacity are fully served")
+
+	assert.Equal(t, 1.0, jainIndex([]float64{3, 3, 3}))
+	assert.Equal(t, 0.25, jainIndex([]float64{1, 0, 0, 0}), "One tenant getting everything should be 1/n")
+	assert.Equal(t, 1.0, jainIndex(nil))
+}
+
+// TestSimulator_Tenants tests that a noisy tenant starves the others on a shared limit but not on a per-key one.
+func TestSimulator_Tenants(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	run := func(perKey bool) Summary {
+		ratelimiter, err := engine.EngineFactory(
+			engine.WithEngineType(engine.TokenBucket),
+			engine.WithCapacity(10),
+			engine.WithFillRate(10.0/1000),
+			engine.WithConsumeRate(1),
+			engine.WithVirtualTime(start),
+			engine.WithPerKey(perKey),
+		)
+		assert.NoError(t, err)
+
+		noisy, err := ParseTenant("noisy:share=40,profile=poisson,rate=200", profile.Constant, profile.Params{})
+		assert.NoError(t, err)
+		quiet1, err := ParseTenant("quiet-1:profile=poisson,rate=5", profile.Constant, profile.Params{})
+		assert.NoError(t, err)
+		quiet2, err := ParseTenant("quiet-2:profile=poisson,rate=5", profile.Constant, profile.Params{})
+		assert.NoError(t, err)
+
+		result := newTestSimulator(t,
+			WithRateLimiter(ratelimiter),
+			WithNumRequests(1050),
+			WithTenants(noisy, quiet1, quiet2),
+			WithSeed(42),
+			WithVirtualTime(start),
+			WithQuiet(true),
+		).Run()
+		return result.Summary(time.Second)
+	}
+
+	shared := run(false)
+	assert.Len(t, shared.Tenants, 3)
+	assert.Equal(t, int64(1000), shared.Tenants[0].Total, "Noisy tenant should send its share of the requests")
+	assert.Equal(t, int64(25), shared.Tenants[1].Total)
+	assert.Empty(t, shared.Workers, "Tenants replace the workers summary")
+	assert.Less(t, shared.Tenants[1].AdmitRate, 0.5, "Noisy tenant should starve the quiet ones on a shared limit")
+	assert.Less(t, shared.Fairness, 0.6)
+
+	perKey := run(true)
+	assert.Greater(t, perKey.Tenants[1].AdmitRate, 0.95, "Quiet tenants should not be limited by the noisy one")
+	assert.Greater(t, perKey.Tenants[2].AdmitRate, 0.95)
+	assert.Greater(t, perKey.Fairness, 0.95)
+}
@@ -0,0 +1,290 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the format of a traffic trace.
+type Format string
+
+const (
+	CSV   Format = "csv"   // timestamp,key,cost with an optional header
+	JSONL Format = "jsonl" // {"timestamp": ..., "key": ..., "cost": ...} per line
+	CLF   Format = "clf"   // Common or Combined Log Format, as written by Apache and NGINX
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case CSV, JSONL, CLF:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("invalid trace format %q, must be one of csv, jsonl, clf", s)
+	}
+}
+
+// FormatFromPath guesses the format of a trace from its file extension,
+// access logs (.log or no extension) are read as CLF.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return CSV
+	case ".jsonl", ".ndjson", ".json":
+		return JSONL
+	default:
+		return CLF
+	}
+}
+
+// Event is a request recorded in a trace.
+type Event struct {
+	Time time.Time
+	Key  string // Client IP, API key, tenant... Empty if unknown
+	Cost uint64 // Number of requests the event counts for, at least 1
+}
+
+// Reader reads the events of a trace one by one, in the order of the file. The events must be sorted by time,
+// the engines can't go back in time.
+type Reader struct {
+	line   int
+	next   func() (Event, error)
+	peeked *Event
+	last   time.Time // Time of the last event read
+}
+
+func NewReader(r io.Reader, format Format) (*Reader, error) {
+	reader := &Reader{}
+
+	switch format {
+	case CSV:
+		reader.next = reader.csv(r)
+	case JSONL:
+		reader.next = reader.lines(r, parseJSON)
+	case CLF:
+		reader.next = reader.lines(r, parseCLF)
+	default:
+		return nil, fmt.Errorf("invalid trace format %q", format)
+	}
+
+	return reader, nil
+}
+
+// Read returns the next event of the trace, or io.EOF at the end of the trace
+func (r *Reader) Read() (Event, error) {
+	if r.peeked != nil {
+		e := *r.peeked
+		r.peeked = nil
+		return e, nil
+	}
+
+	e, err := r.next()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Event{}, fmt.Errorf("line %d: %w", r.line, err)
+	}
+	if err == nil {
+		if e.Time.Before(r.last) {
+			return Event{}, fmt.Errorf("line %d: event at %v is before the previous event at %v, the trace must be sorted by time", r.line, e.Time, r.last)
+		}
+		r.last = e.Time
+	}
+	return e, err
+}
+
+// Peek returns the next event without consuming it
+func (r *Reader) Peek() (Event, error) {
+	if r.peeked != nil {
+		return *r.peeked, nil
+	}
+
+	e, err := r.Read()
+	if err != nil {
+		return Event{}, err
+	}
+	r.peeked = &e
+	return e, nil
+}
+
+// lines reads a trace with one event per line, skipping empty lines
+func (r *Reader) lines(in io.Reader, parse func(line string) (Event, error)) func() (Event, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	return func() (Event, error) {
+		for scanner.Scan() {
+			r.line++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			return parse(line)
+		}
+
+		if err := scanner.Err(); err != nil {
+			return Event{}, err
+		}
+		return Event{}, io.EOF
+	}
+}
+
+// csv reads a trace of timestamp,key,cost records. Key and cost are optional.
+// If the first record is a header, the columns are matched by name instead.
+func (r *Reader) csv(in io.Reader) func() (Event, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"timestamp": 0, "key": 1, "cost": 2}
+	first := true
+
+	return func() (Event, error) {
+		for {
+			record, err := reader.Read()
+			if err != nil {
+				return Event{}, err
+			}
+			r.line, _ = reader.FieldPos(0)
+
+			if first {
+				first = false
+				if _, err := parseTimestamp(record[0]); err != nil {
+					columns, err = parseHeader(record)
+					if err != nil {
+						return Event{}, err
+					}
+					continue
+				}
+			}
+
+			field := func(name string) string {
+				i, ok := columns[name]
+				if !ok || i >= len(record) {
+					return ""
+				}
+				return strings.TrimSpace(record[i])
+			}
+
+			ts, err := parseTimestamp(field("timestamp"))
+			if err != nil {
+				return Event{}, err
+			}
+			cost, err := parseCost(field("cost"))
+			if err != nil {
+				return Event{}, err
+			}
+			return Event{Time: ts, Key: field("key"), Cost: cost}, nil
+		}
+	}
+}
+
+func parseHeader(record []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range record {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "timestamp", "time", "ts":
+			columns["timestamp"] = i
+		case "key":
+			columns["key"] = i
+		case "cost":
+			columns["cost"] = i
+		}
+	}
+
+	if _, ok := columns["timestamp"]; !ok {
+		return nil, fmt.Errorf("missing timestamp column")
+	}
+	return columns, nil
+}
+
+type jsonEvent struct {
+	Timestamp json.RawMessage `json:"timestamp"`
+	Key       string          `json:"key"`
+	Cost      *uint64         `json:"cost"`
+}
+
+func parseJSON(line string) (Event, error) {
+	var e jsonEvent
+	if err := json.Unmarshal([]byte(line), &e); err != nil {
+		return Event{}, err
+	}
+	if e.Timestamp == nil {
+		return Event{}, fmt.Errorf("missing timestamp")
+	}
+
+	// Timestamps are either RFC3339 strings or Unix times in seconds
+	var raw string
+	if err := json.Unmarshal(e.Timestamp, &raw); err != nil {
+		raw = string(e.Timestamp)
+	}
+	ts, err := parseTimestamp(raw)
+	if err != nil {
+		return Event{}, err
+	}
+
+	cost := uint64(1)
+	if e.Cost != nil {
+		cost = *e.Cost
+	}
+	if cost == 0 {
+		return Event{}, fmt.Errorf("cost must be at least 1")
+	}
+
+	return Event{Time: ts, Key: e.Key, Cost: cost}, nil
+}
+
+// clfPattern matches the Common Log Format, and the Combined Log Format which only adds fields at the end:
+// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "referer" "user agent"
+var clfPattern = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "[^"]*" \d{3} \S+`)
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// parseCLF reads an access log line, the key of the event is the client address
+func parseCLF(line string) (Event, error) {
+	match := clfPattern.FindStringSubmatch(line)
+	if match == nil {
+		return Event{}, fmt.Errorf("invalid access log line")
+	}
+
+	ts, err := time.Parse(clfTimeLayout, match[2])
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Time: ts, Key: match[1], Cost: 1}, nil
+}
+
+// parseTimestamp reads RFC3339 timestamps, or Unix times in seconds with an optional fraction
+func parseTimestamp(s string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return ts, nil
+	}
+
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q, must be RFC3339 or Unix seconds", s)
+	}
+
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC(), nil
+}
+
+func parseCost(s string) (uint64, error) {
+	if s == "" {
+		return 1, nil
+	}
+
+	cost, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || cost == 0 {
+		return 0, fmt.Errorf("invalid cost %q, must be an integer >= 1", s)
+	}
+	return cost, nil
+}
@@ -0,0 +1,149 @@
+package trace
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll reads all the events of a trace
+func readAll(t *testing.T, input string, format Format) ([]Event, error) {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(input), format)
+	assert.NoError(t, err)
+
+	var events []Event
+	for {
+		e, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// TestReader_CSV tests CSV traces with and without header.
+func TestReader_CSV(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "Positional columns",
+			input: "2025-01-01T00:00:00Z,alice,2\n1735689600.5,bob\n",
+		},
+		{
+			name:  "Header with reordered columns",
+			input: "key,cost,timestamp\nalice,2,2025-01-01T00:00:00Z\nbob,,1735689600.5\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := readAll(t, tc.input, CSV)
+			assert.NoError(t, err)
+			assert.Equal(t, []Event{
+				{Time: t0, Key: "alice", Cost: 2},
+				{Time: t0.Add(500 * time.Millisecond), Key: "bob", Cost: 1},
+			}, events)
+		})
+	}
+}
+
+// TestReader_JSONL tests JSONL traces with string and numeric timestamps.
+func TestReader_JSONL(t *testing.T) {
+	input := `{"timestamp": "2025-01-01T00:00:00Z", "key": "alice", "cost": 3}
+
+{"timestamp": 1735689601, "key": "bob"}
+`
+	events, err := readAll(t, input, JSONL)
+	assert.NoError(t, err)
+
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []Event{
+		{Time: t0, Key: "alice", Cost: 3},
+		{Time: t0.Add(time.Second), Key: "bob", Cost: 1},
+	}, events)
+}
+
+// TestReader_CLF tests Common and Combined Log Format traces.
+func TestReader_CLF(t *testing.T) {
+	input := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+10.0.0.2 - - [10/Oct/2000:13:55:37 -0700] "POST /login HTTP/1.1" 429 - "http://example.com/" "Mozilla/5.0"
+`
+	events, err := readAll(t, input, CLF)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, "127.0.0.1", events[0].Key)
+	assert.Equal(t, uint64(1), events[0].Cost)
+	assert.Equal(t, "2000-10-10T20:55:36Z", events[0].Time.UTC().Format(time.RFC3339))
+	assert.Equal(t, "10.0.0.2", events[1].Key)
+	assert.Equal(t, time.Second, events[1].Time.Sub(events[0].Time))
+}
+
+// TestReader_Invalid tests that invalid lines are reported with their line number.
+func TestReader_Invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		format Format
+		line   string
+	}{
+		{name: "CSV invalid timestamp", input: "timestamp,key\n2025-01-01T00:00:00Z,a\nyesterday,b\n", format: CSV, line: "line 3"},
+		{name: "CSV zero cost", input: "2025-01-01T00:00:00Z,a,0\n", format: CSV, line: "line 1"},
+		{name: "CSV header without timestamp", input: "key,cost\na,1\n", format: CSV, line: "line 1"},
+		{name: "JSONL missing timestamp", input: "{\"key\": \"a\"}\n", format: JSONL, line: "line 1"},
+		{name: "CLF garbage", input: "\nhello world\n", format: CLF, line: "line 2"},
+		{name: "CSV unsorted", input: "1,a\n3,b\n2,c\n", format: CSV, line: "line 3"},
+		{name: "JSONL unsorted", input: "{\"timestamp\": 2}\n\n{\"timestamp\": 1}\n", format: JSONL, line: "line 3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readAll(t, tc.input, tc.format)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.line)
+		})
+	}
+}
+
+// TestReader_SameTime tests that events at the same time are read in the order of the file.
+func TestReader_SameTime(t *testing.T) {
+	events, err := readAll(t, "1,a\n1,b\n2,c\n", CSV)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+}
+
+// TestReader_Peek tests that peeking doesn't consume the event.
+func TestReader_Peek(t *testing.T) {
+	reader, err := NewReader(strings.NewReader("1,a\n2,b\n"), CSV)
+	assert.NoError(t, err)
+
+	first, err := reader.Peek()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", first.Key)
+
+	e, _ := reader.Read()
+	assert.Equal(t, first, e)
+	e, _ = reader.Read()
+	assert.Equal(t, "b", e.Key)
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// TestFormatFromPath tests guessing the format from the file extension.
+func TestFormatFromPath(t *testing.T) {
+	assert.Equal(t, CSV, FormatFromPath("trace.CSV"))
+	assert.Equal(t, JSONL, FormatFromPath("/tmp/trace.jsonl"))
+	assert.Equal(t, CLF, FormatFromPath("/var/log/nginx/access.log"))
+}
@@ -0,0 +1,284 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// MaxSweepConfigs bounds the number of simulations of a sweep
+const MaxSweepConfigs = 10000
+
+// sweepParams are the engine parameters that can be swept, named like the engine configuration params,
+// true for the integer ones
+var sweepParams = map[string]bool{
+	"capacity":       true,
+	"fill-duration":  false,
+	"consume-rate":   false,
+	"window-size":    true,
+	"drain-duration": false,
+}
+
+// SweepParam is an engine parameter and the values to try.
+type SweepParam struct {
+	Name   string
+	Values []float64
+}
+
+// ParseSweepParam parses the values of a parameter written either as a list, e.g. "capacity=5,10,20",
+// or as a range "<start>..<end>:<step>", e.g. "capacity=5..50:5". A parameter has at most MaxSweepConfigs values.
+func ParseSweepParam(s string) (SweepParam, error) {
+	name, values, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok {
+		return SweepParam{}, fmt.Errorf("invalid sweep %q, must be param=values", s)
+	}
+	integer, ok := sweepParams[name]
+	if !ok {
+		return SweepParam{}, fmt.Errorf("invalid sweep parameter %q, must be capacity, fill-duration, consume-rate, window-size or drain-duration", name)
+	}
+
+	// Integer parameters are parsed as integers, so fractions are rejected before the sweep
+	parse := func(value string) (float64, error) {
+		value = strings.TrimSpace(value)
+		if integer {
+			i, err := strconv.ParseInt(value, 10, 64)
+			return float64(i), err
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+
+	p := SweepParam{Name: name}
+	if bounds, step, ok := strings.Cut(values, ":"); ok && strings.Contains(bounds, "..") {
+		startValue, endValue, _ := strings.Cut(bounds, "..")
+		start, err1 := parse(startValue)
+		end, err2 := parse(endValue)
+		inc, err3 := parse(step)
+		if err1 != nil || err2 != nil || err3 != nil || inc <= 0 || end < start {
+			return SweepParam{}, fmt.Errorf("invalid %s range %q, must be <start>..<end>:<step> with start <= end and step > 0", name, values)
+		}
+		// Count the steps instead of adding them up, so the rounding errors don't skip the end
+		steps := math.Floor((end-start)/inc+1e-9) + 1
+		if steps > MaxSweepConfigs {
+			return SweepParam{}, fmt.Errorf("%s range has more than %d values", name, MaxSweepConfigs)
+		}
+		for i := 0.0; i < steps; i++ {
+			p.Values = append(p.Values, start+i*inc)
+		}
+	} else {
+		fields := strings.Split(values, ",")
+		if len(fields) > MaxSweepConfigs {
+			return SweepParam{}, fmt.Errorf("%s has more than %d values", name, MaxSweepConfigs)
+		}
+		for _, field := range fields {
+			v, err := parse(field)
+			if err != nil {
+				return SweepParam{}, fmt.Errorf("invalid %s value %q", name, field)
+			}
+			p.Values = append(p.Values, v)
+		}
+	}
+
+	for _, v := range p.Values {
+		if v <= 0 {
+			return SweepParam{}, fmt.Errorf("%s values must be greater than 0", name)
+		}
+	}
+	return p, nil
+}
+
+// SweepSpecs returns the engine configuration of every combination of the parameter values,
+// e.g. "token-bucket:capacity=10,fill-duration=100".
+func SweepSpecs(engineType string, params []SweepParam) []string {
+	combinations := []string{""}
+	for _, p := range params {
+		var next []string
+		for _, c := range combinations {
+			for _, v := range p.Values {
+				pair := p.Name + "=" + strconv.FormatFloat(v, 'f', -1, 64)
+				if c != "" {
+					pair = c + "," + pair
+				}
+				next = append(next, pair)
+			}
+		}
+		combinations = next
+	}
+
+	specs := make([]string, len(combinations))
+	for i, c := range combinations {
+		specs[i] = engineType
+		if c != "" {
+			specs[i] += ":" + c
+		}
+	}
+	return specs
+}
+
+// Target is what a parameter sweep looks for: admitting the requests of the normal users,
+// while keeping the peak of allowed requests under a cap.
+type Target struct {
+	Keys         []string      `json:"keys,omitempty"` // Keys of the normal users, all the requests if empty
+	MinAdmitRate float64       `json:"min_admit_rate"` // Admit rate of the normal users to reach
+	MaxPeak      int64         `json:"max_peak"`       // Max requests allowed in any peak window, no cap if 0
+	PeakWindow   time.Duration `json:"peak_window_ns"`
+}
+
+// Candidate is the outcome of an engine configuration in a sweep.
+type Candidate struct {
+	Config          string  `json:"config"`
+	AdmitRate       float64 `json:"admit_rate"`        // All requests
+	TargetAdmitRate float64 `json:"target_admit_rate"` // Requests of the normal users
+	PeakAllowed     int64   `json:"peak_allowed"`
+	Throughput      float64 `json:"throughput"`
+	MeetsTarget     bool    `json:"meets_target"`
+}
+
+// Evaluate measures the result of an engine configuration against the target
+func (t Target) Evaluate(config string, r *Result) Candidate {
+	s := r.Summary(time.Second)
+	c := Candidate{
+		Config:      config,
+		AdmitRate:   s.AdmitRate,
+		PeakAllowed: r.PeakAllowed(t.PeakWindow),
+		Throughput:  s.Throughput,
+	}
+
+	keys := map[string]bool{}
+	for _, key := range t.Keys {
+		keys[key] = true
+	}
+	total, allowed := int64(0), int64(0)
+	for _, req := range r.Requests {
+		if len(keys) > 0 && !keys[req.Key] {
+			continue
+		}
+		total++
+		if req.Allowed {
+			allowed++
+		}
+	}
+	c.TargetAdmitRate = admitRate(allowed, total)
+
+	c.MeetsTarget = c.TargetAdmitRate >= t.MinAdmitRate && (t.MaxPeak == 0 || c.PeakAllowed <= t.MaxPeak)
+	return c
+}
+
+// dominates returns true if a is at least as good as b on both objectives, and better on one:
+// a higher admit rate of the normal users and a lower peak.
+func dominates(a, b Candidate) bool {
+	if a.TargetAdmitRate < b.TargetAdmitRate || a.PeakAllowed > b.PeakAllowed {
+		return false
+	}
+	return a.TargetAdmitRate > b.TargetAdmitRate || a.PeakAllowed < b.PeakAllowed
+}
+
+// ParetoFront returns the candidates no other candidate dominates, sorted by peak.
+// Candidates with the same outcome are all kept.
+func ParetoFront(candidates []Candidate) []Candidate {
+	front := []Candidate{}
+	for i, c := range candidates {
+		dominated := false
+		for j, other := range candidates {
+			if i != j && dominates(other, c) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			front = append(front, c)
+		}
+	}
+
+	sort.SliceStable(front, func(i, j int) bool {
+		return front[i].PeakAllowed < front[j].PeakAllowed
+	})
+	return front
+}
+
+// Tuning is the outcome of a parameter sweep.
+type Tuning struct {
+	Target    Target      `json:"target"`
+	Simulated int         `json:"simulated"` // Configurations simulated
+	Matching  int         `json:"matching"`  // Configurations meeting the target
+	Best      []Candidate `json:"best"`      // Pareto front of the configurations meeting the target, of all of them if none does
+}
+
+// NewTuning selects the best candidates of a sweep
+func NewTuning(target Target, candidates []Candidate) *Tuning {
+	t := &Tuning{Target: target, Simulated: len(candidates)}
+
+	var matching []Candidate
+	for _, c := range candidates {
+		if c.MeetsTarget {
+			matching = append(matching, c)
+		}
+	}
+	t.Matching = len(matching)
+
+	if len(matching) > 0 {
+		t.Best = ParetoFront(matching)
+	} else {
+		t.Best = ParetoFront(candidates)
+	}
+	return t
+}
+
+// Print writes the best configurations as a table
+func (t *Tuning) Print(w io.Writer) {
+	users := "all requests"
+	if len(t.Target.Keys) > 0 {
+		users = strings.Join(t.Target.Keys, ", ")
+	}
+	peak := "no cap"
+	if t.Target.MaxPeak > 0 {
+		peak = fmt.Sprintf("at most %d per %v", t.Target.MaxPeak, t.Target.PeakWindow)
+	}
+	fmt.Fprintf(w, "Target: admit %.1f%% of %s, peak %s\n", 100*t.Target.MinAdmitRate, users, peak)
+
+	if t.Matching > 0 {
+		fmt.Fprintf(w, "%d of %d configurations meet the target, Pareto-best:\n\n", t.Matching, t.Simulated)
+	} else {
+		fmt.Fprintf(w, "None of the %d configurations meets the target, Pareto-best of all:\n\n", t.Simulated)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "CONFIG\tTARGET ADMIT RATE\tADMIT RATE\tPEAK/%v\tTHROUGHPUT\tMEETS TARGET\n", t.Target.PeakWindow)
+	for _, c := range t.Best {
+		fmt.Fprintf(tw, "%s\t%.2f%%\t%.1f%%\t%d\t%.2f/s\t%v\n",
+			c.Config, 100*c.TargetAdmitRate, 100*c.AdmitRate, c.PeakAllowed, c.Throughput, c.MeetsTarget)
+	}
+	_ = tw.Flush()
+}
+
+// WriteJSON writes the tuning as JSON
+func (t *Tuning) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
+
+// WriteCSV writes one record per best configuration
+func (t *Tuning) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"config", "target_admit_rate", "admit_rate", "peak_allowed", "throughput", "meets_target"})
+	for _, c := range t.Best {
+		_ = writer.Write([]string{
+			c.Config,
+			strconv.FormatFloat(c.TargetAdmitRate, 'f', -1, 64),
+			strconv.FormatFloat(c.AdmitRate, 'f', -1, 64),
+			strconv.FormatInt(c.PeakAllowed, 10),
+			strconv.FormatFloat(c.Throughput, 'f', -1, 64),
+			strconv.FormatBool(c.MeetsTarget),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,118 @@
+package simulator
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseSweepParam tests the parsing of lists and ranges of values.
+func TestParseSweepParam(t *testing.T) {
+	p, err := ParseSweepParam("capacity=5,10, 20")
+	assert.NoError(t, err)
+	assert.Equal(t, SweepParam{Name: "capacity", Values: []float64{5, 10, 20}}, p)
+
+	p, err = ParseSweepParam("fill-duration=50..100:25")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{50, 75, 100}, p.Values)
+
+	p, err = ParseSweepParam("consume-rate=0.1..0.3:0.1")
+	assert.NoError(t, err)
+	assert.Len(t, p.Values, 3, "Rounding errors should not skip the end of the range")
+
+	for _, s := range []string{
+		"capacity",
+		"name=a,b",
+		"capacity=a",
+		"capacity=0,5",
+		"capacity=10..5:1",
+		"capacity=5..10:0",
+		"capacity=5..10:x",
+		"capacity=2.5",
+		"window-size=100..200:0.5",
+		"capacity=1..100000:1",
+		"fill-duration=1..100000:1",
+	} {
+		_, err := ParseSweepParam(s)
+		assert.Error(t, err, "Sweep %q should be invalid", s)
+	}
+}
+
+// TestSweepSpecs tests that every combination of values is tried.
+func TestSweepSpecs(t *testing.T) {
+	specs := SweepSpecs("token-bucket", []SweepParam{
+		{Name: "capacity", Values: []float64{5, 10}},
+		{Name: "fill-duration", Values: []float64{100, 12.5}},
+	})
+	assert.Equal(t, []string{
+		"token-bucket:capacity=5,fill-duration=100",
+		"token-bucket:capacity=5,fill-duration=12.5",
+		"token-bucket:capacity=10,fill-duration=100",
+		"token-bucket:capacity=10,fill-duration=12.5",
+	}, specs)
+
+	assert.Equal(t, []string{"fixed-window"}, SweepSpecs("fixed-window", nil))
+}
+
+// TestTarget_Evaluate tests that the admit rate of the target is measured on the requests of its keys.
+func TestTarget_Evaluate(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := &Result{Requests: []Request{
+		{Key: "normal", ArriveAt: start, Allowed: true},
+		{Key: "abuser", ArriveAt: start.Add(100 * time.Millisecond), Allowed: true},
+		{Key: "abuser", ArriveAt: start.Add(200 * time.Millisecond), Allowed: false},
+		{Key: "normal", ArriveAt: start.Add(300 * time.Millisecond), Allowed: true},
+		{Key: "abuser", ArriveAt: start.Add(2 * time.Second), Allowed: false},
+	}}
+
+	target := Target{Keys: []string{"normal"}, MinAdmitRate: 0.99, MaxPeak: 3, PeakWindow: time.Second}
+	c := target.Evaluate("a", result)
+	assert.Equal(t, 1.0, c.TargetAdmitRate)
+	assert.Equal(t, 0.6, c.AdmitRate)
+	assert.Equal(t, int64(3), c.PeakAllowed)
+	assert.True(t, c.MeetsTarget)
+
+	target.MaxPeak = 2
+	assert.False(t, target.Evaluate("a", result).MeetsTarget, "Peak above the cap should not meet the target")
+
+	target = Target{MinAdmitRate: 0.99, PeakWindow: time.Second}
+	c = target.Evaluate("a", result)
+	assert.Equal(t, 0.6, c.TargetAdmitRate, "Target should be all the requests without keys")
+	assert.False(t, c.MeetsTarget)
+}
+
+// TestNewTuning tests that the Pareto front of the configurations meeting the target is selected.
+func TestNewTuning(t *testing.T) {
+	target := Target{MinAdmitRate: 0.9, MaxPeak: 20, PeakWindow: time.Second}
+	candidates := []Candidate{
+		{Config: "a", TargetAdmitRate: 0.95, PeakAllowed: 10, MeetsTarget: true},
+		{Config: "b", TargetAdmitRate: 0.99, PeakAllowed: 15, MeetsTarget: true},
+		{Config: "c", TargetAdmitRate: 0.94, PeakAllowed: 12, MeetsTarget: true}, // Dominated by a
+		{Config: "d", TargetAdmitRate: 1, PeakAllowed: 30, MeetsTarget: false},
+		{Config: "e", TargetAdmitRate: 0.99, PeakAllowed: 15, MeetsTarget: true}, // Same as b
+	}
+
+	tuning := NewTuning(target, candidates)
+	assert.Equal(t, 5, tuning.Simulated)
+	assert.Equal(t, 4, tuning.Matching)
+
+	var best []string
+	for _, c := range tuning.Best {
+		best = append(best, c.Config)
+	}
+	assert.Equal(t, []string{"a", "b", "e"}, best, "Best configurations should be sorted by peak")
+
+	var buf bytes.Buffer
+	tuning.Print(&buf)
+	assert.Contains(t, buf.String(), "4 of 5 configurations meet the target")
+
+	tuning = NewTuning(target, candidates[3:4])
+	assert.Equal(t, 0, tuning.Matching)
+	assert.Len(t, tuning.Best, 1, "Best of all configurations should be returned if none meets the target")
+
+	buf.Reset()
+	assert.NoError(t, tuning.WriteCSV(&buf))
+	assert.Equal(t, "config,target_admit_rate,admit_rate,peak_allowed,throughput,meets_target\nd,1,0,30,0,false\n", buf.String())
+}
@@ -0,0 +1,46 @@
+package throttle
+
+import (
+	"net/http"
+	"time"
+)
+
+type options struct {
+	keyFunc func(r *http.Request) string
+	poll    time.Duration
+	verbose bool
+}
+
+type Option func(o *options)
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		keyFunc: func(r *http.Request) string { return r.URL.Host },
+		poll:    100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithKeyFunc sets the key of a request, e.g. an API key or a tenant, default is the host of the URL.
+func WithKeyFunc(keyFunc func(r *http.Request) string) Option {
+	return func(o *options) {
+		o.keyFunc = keyFunc
+	}
+}
+
+// WithPollInterval sets the max time between two checks of a waiting request, default is 100ms.
+func WithPollInterval(poll time.Duration) Option {
+	return func(o *options) {
+		o.poll = poll
+	}
+}
+
+// WithVerbose logs the pauses asked by the servers, default is false.
+func WithVerbose(verbose bool) Option {
+	return func(o *options) {
+		o.verbose = verbose
+	}
+}
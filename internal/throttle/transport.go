@@ -0,0 +1,133 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+	"github.com/minhthong582000/rate-limiter/internal/header"
+)
+
+// Transport is a http.RoundTripper throttling the outbound requests with an engine, keyed by host by default.
+// A request denied by the engine waits until it is allowed or its context is done, instead of failing.
+// When the server answers 429 Too Many Requests, or has no request remaining, the key is paused for the time
+// of its Retry-After or RateLimit headers. The response is returned as is, the following requests wait.
+type Transport struct {
+	next    http.RoundTripper
+	engine  engine.Engine
+	config  *engine.Config
+	keyFunc func(r *http.Request) string
+	poll    time.Duration
+	verbose bool
+
+	mutex  sync.Mutex
+	paused map[string]time.Time
+}
+
+// NewTransport creates the engine of the transport and wraps the next transport, http.DefaultTransport if nil.
+// Add engine.WithPerKey(true) to the engine options to give every key its own limit. The leaky bucket and the
+// fair queue are rejected: they admit the requests in their queue at once, so the requests would not wait.
+func NewTransport(next http.RoundTripper, engineOpts []engine.Option, opts ...Option) (*Transport, error) {
+	o := newOptions(opts...)
+	if o.poll <= 0 {
+		return nil, fmt.Errorf("poll interval must be greater than 0")
+	}
+
+	config := engine.NewConfig(engineOpts...)
+	if config.EngineType == engine.LeakyBucket || config.EngineType == engine.FairQueue {
+		return nil, fmt.Errorf("%s queues the requests instead of delaying them, it can't throttle a transport", config.EngineType)
+	}
+
+	e, err := engine.EngineFactory(engineOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Transport{
+		next:    next,
+		engine:  e,
+		config:  config,
+		keyFunc: o.keyFunc,
+		poll:    o.poll,
+		verbose: o.verbose,
+		paused:  map[string]time.Time{},
+	}, nil
+}
+
+// RoundTrip waits until the request is allowed, sends it and pauses its key if the server asks to wait
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := t.keyFunc(r)
+	if err := t.Wait(r.Context(), key); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	throttled := resp.StatusCode == http.StatusTooManyRequests
+	if pause, ok := header.Parse(resp.Header, time.Now()).Pause(throttled); ok && pause > 0 {
+		t.Pause(key, pause)
+	}
+	return resp, nil
+}
+
+// Wait blocks until a request of the key is allowed and the key is not paused, or the context is done,
+// see engine.Wait
+func (t *Transport) Wait(ctx context.Context, key string) error {
+	if err := t.waitPause(ctx, key); err != nil {
+		return err
+	}
+	if err := engine.Wait(ctx, t.engine, t.config, key, t.poll); err != nil {
+		return err
+	}
+	// The server may have asked to pause while the request was waiting
+	return t.waitPause(ctx, key)
+}
+
+// waitPause blocks until the pause of the key is over, or the context is done
+func (t *Transport) waitPause(ctx context.Context, key string) error {
+	for {
+		until := t.pausedUntil(key)
+		if until.IsZero() {
+			return nil
+		}
+		if err := engine.Sleep(ctx, time.Until(until)); err != nil {
+			return err
+		}
+	}
+}
+
+// Pause stops sending the requests of the key for the given duration, extending a longer pause
+func (t *Transport) Pause(key string, d time.Duration) {
+	until := time.Now().Add(d)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if until.After(t.paused[key]) {
+		t.paused[key] = until
+		if t.verbose {
+			fmt.Printf("Pausing %s for %v\n", key, d)
+		}
+	}
+}
+
+// pausedUntil returns the end of the pause of the key, zero if it is not paused
+func (t *Transport) pausedUntil(key string) time.Time {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	until, ok := t.paused[key]
+	if ok && !time.Now().Before(until) {
+		delete(t.paused, key)
+		return time.Time{}
+	}
+	return until
+}
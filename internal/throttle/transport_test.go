@@ -0,0 +1,145 @@
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/minhthong582000/rate-limiter/internal/engine"
+)
+
+func get(t *testing.T, client *http.Client, url string) *http.Response {
+	resp, err := client.Get(url)
+	assert.NoError(t, err)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp
+}
+
+// TestTransport_Wait tests that requests denied by the engine wait instead of failing
+func TestTransport_Wait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transport, err := NewTransport(nil, []engine.Option{
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(1),
+		engine.WithFillRate(1.0 / 50),
+		engine.WithConsumeRate(1),
+	})
+	assert.NoError(t, err)
+	client := &http.Client{Transport: transport}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, get(t, client, server.URL).StatusCode)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "Requests should wait for a token every 50ms")
+}
+
+// TestTransport_Context tests that a waiting request fails when its context is done
+func TestTransport_Context(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transport, err := NewTransport(nil, []engine.Option{
+		engine.WithEngineType(engine.FixedWindow),
+		engine.WithCapacity(1),
+		engine.WithWindowSize(3600000),
+	})
+	assert.NoError(t, err)
+	client := &http.Client{Transport: transport}
+	get(t, client, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// TestTransport_PerKey tests that every host has its own limit
+func TestTransport_PerKey(t *testing.T) {
+	a := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer a.Close()
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer b.Close()
+
+	transport, err := NewTransport(nil, []engine.Option{
+		engine.WithEngineType(engine.FixedWindow),
+		engine.WithCapacity(1),
+		engine.WithWindowSize(3600000),
+		engine.WithPerKey(true),
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, transport.Wait(ctx, "a"))
+	assert.NoError(t, transport.Wait(ctx, "b"), "Another key should have its own limit")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, transport.Wait(ctx, "a"))
+}
+
+// TestNewTransport tests that the invalid options and the engines queueing requests are rejected
+func TestNewTransport(t *testing.T) {
+	tokenBucket := []engine.Option{
+		engine.WithEngineType(engine.TokenBucket),
+		engine.WithCapacity(1),
+		engine.WithFillRate(1.0 / 50),
+		engine.WithConsumeRate(1),
+	}
+	_, err := NewTransport(nil, tokenBucket, WithPollInterval(0))
+	assert.Error(t, err)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	for _, engineType := range []engine.EngineType{engine.LeakyBucket, engine.FairQueue} {
+		_, err = NewTransport(nil, []engine.Option{
+			engine.WithEngineType(engineType),
+			engine.WithCapacity(1),
+			engine.WithLeakRate(time.Second),
+			engine.WithStopChannel(stopCh),
+		})
+		assert.Error(t, err, "%s should be rejected", engineType)
+	}
+}
+
+// TestTransport_Throttled tests that a 429 pauses the key for the Retry-After of the server
+func TestTransport_Throttled(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	transport, err := NewTransport(nil, []engine.Option{
+		engine.WithEngineType(engine.FixedWindow),
+		engine.WithCapacity(100),
+		engine.WithWindowSize(1000),
+	})
+	assert.NoError(t, err)
+	client := &http.Client{Transport: transport}
+
+	assert.Equal(t, http.StatusTooManyRequests, get(t, client, server.URL).StatusCode, "The 429 should be returned")
+	until := transport.pausedUntil(server.Listener.Addr().String())
+	assert.WithinDuration(t, time.Now().Add(time.Second), until, 100*time.Millisecond)
+
+	start := time.Now()
+	assert.Equal(t, http.StatusOK, get(t, client, server.URL).StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), 800*time.Millisecond, "The next request should wait for the pause")
+}
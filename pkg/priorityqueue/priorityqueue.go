@@ -0,0 +1,107 @@
+package priorityqueue
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// PriorityQueue is a bounded min-heap ordered by less.
+// Items with equal priority are popped in insertion order.
+type PriorityQueue[T any] struct {
+	capacity uint64
+	items    *items[T]
+}
+
+type entry[T any] struct {
+	value T
+	seq   uint64
+}
+
+type items[T any] struct {
+	entries []entry[T]
+	less    func(a, b T) bool
+	nextSeq uint64
+}
+
+func (h *items[T]) Len() int { return len(h.entries) }
+
+func (h *items[T]) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.less(a.value, b.value) {
+		return true
+	}
+	if h.less(b.value, a.value) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (h *items[T]) Swap(i, j int) { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+
+func (h *items[T]) Push(x any) { h.entries = append(h.entries, x.(entry[T])) }
+
+func (h *items[T]) Pop() any {
+	n := len(h.entries)
+	e := h.entries[n-1]
+	h.entries[n-1] = entry[T]{} // Clear the value
+	h.entries = h.entries[:n-1]
+	return e
+}
+
+func NewPriorityQueue[T any](capacity uint64, less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{
+		capacity: capacity,
+		items: &items[T]{
+			entries: make([]entry[T], 0, capacity),
+			less:    less,
+		},
+	}
+}
+
+func (p *PriorityQueue[T]) IsFull() bool {
+	return p.Size() >= p.capacity
+}
+
+func (p *PriorityQueue[T]) IsEmpty() bool {
+	return p.Size() == 0
+}
+
+func (p *PriorityQueue[T]) Capacity() uint64 {
+	return p.capacity
+}
+
+func (p *PriorityQueue[T]) Size() uint64 {
+	return uint64(p.items.Len())
+}
+
+func (p *PriorityQueue[T]) Push(value T) error {
+	if p.IsFull() {
+		return fmt.Errorf("queue is full")
+	}
+
+	heap.Push(p.items, entry[T]{value: value, seq: p.items.nextSeq})
+	p.items.nextSeq++
+
+	return nil
+}
+
+func (p *PriorityQueue[T]) Pop() (T, error) {
+	if p.IsEmpty() {
+		return *new(T), fmt.Errorf("queue is empty")
+	}
+
+	return heap.Pop(p.items).(entry[T]).value, nil
+}
+
+func (p *PriorityQueue[T]) Peek() (T, error) {
+	if p.IsEmpty() {
+		return *new(T), fmt.Errorf("queue is empty")
+	}
+
+	return p.items.entries[0].value, nil
+}
+
+func (p *PriorityQueue[T]) Clear() {
+	p.items.entries = p.items.entries[:0]
+	p.items.nextSeq = 0
+}
@@ -0,0 +1,89 @@
+package priorityqueue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lessInt(a, b int) bool { return a < b }
+
+// TestNewPriorityQueue tests queue initialization
+func TestNewPriorityQueue(t *testing.T) {
+	pq := NewPriorityQueue[int](5, lessInt)
+
+	assert.NotNil(t, pq)
+	assert.Equal(t, uint64(5), pq.Capacity())
+	assert.Equal(t, uint64(0), pq.Size())
+	assert.True(t, pq.IsEmpty())
+}
+
+// TestPushPop tests that values are popped in priority order
+func TestPushPop(t *testing.T) {
+	pq := NewPriorityQueue[int](5, lessInt)
+
+	for _, v := range []int{3, 1, 4, 1, 5} {
+		assert.NoError(t, pq.Push(v))
+	}
+	assert.True(t, pq.IsFull())
+	assert.Error(t, pq.Push(9), "Push beyond capacity should fail")
+
+	for _, expected := range []int{1, 1, 3, 4, 5} {
+		value, err := pq.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, value)
+	}
+
+	_, err := pq.Pop()
+	assert.Error(t, err, "Pop from an empty queue should fail")
+}
+
+// TestStableOrder tests that values with equal priority keep their insertion order
+func TestStableOrder(t *testing.T) {
+	type item struct {
+		priority int
+		name     string
+	}
+	pq := NewPriorityQueue[item](10, func(a, b item) bool { return a.priority < b.priority })
+
+	items := []item{
+		{1, "a"}, {0, "b"}, {1, "c"}, {0, "d"}, {1, "e"}, {0, "f"},
+	}
+	for _, it := range items {
+		assert.NoError(t, pq.Push(it))
+	}
+
+	var names []string
+	for !pq.IsEmpty() {
+		it, _ := pq.Pop()
+		names = append(names, it.name)
+	}
+	assert.Equal(t, []string{"b", "d", "f", "a", "c", "e"}, names)
+}
+
+// TestPeek tests peeking at the highest priority value
+func TestPeek(t *testing.T) {
+	pq := NewPriorityQueue[int](3, lessInt)
+
+	_, err := pq.Peek()
+	assert.Error(t, err)
+
+	assert.NoError(t, pq.Push(2))
+	assert.NoError(t, pq.Push(1))
+
+	value, err := pq.Peek()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, uint64(2), pq.Size(), "Peek should not remove the value")
+}
+
+// TestClear tests clearing the queue
+func TestClear(t *testing.T) {
+	pq := NewPriorityQueue[int](3, lessInt)
+	assert.NoError(t, pq.Push(1))
+	assert.NoError(t, pq.Push(2))
+
+	pq.Clear()
+	assert.True(t, pq.IsEmpty())
+	assert.NoError(t, pq.Push(3))
+}
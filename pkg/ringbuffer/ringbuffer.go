@@ -31,7 +31,8 @@ func (r *RingBuffer[T]) Capacity() uint64 {
 }
 
 func (r *RingBuffer[T]) Size() uint64 {
-	return r.end - r.start
+	// end wraps to the beginning before start does
+	return (r.end + r.capacity - r.start) % r.capacity
 }
 
 func (r *RingBuffer[T]) StartIndex() uint64 {
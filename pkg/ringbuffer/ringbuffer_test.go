@@ -143,3 +143,17 @@ func TestPeekFront(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), value)
 }
+
+// TestSizeAfterWrap tests the buffer size when the end index has wrapped around
+func TestSizeAfterWrap(t *testing.T) {
+	rb := NewRingBuffer[int](3)
+
+	assert.NoError(t, rb.PushBack(1))
+	assert.NoError(t, rb.PushBack(2))
+	assert.NoError(t, rb.PushBack(3))
+	_, _ = rb.PopFront()
+	_, _ = rb.PopFront()
+	assert.NoError(t, rb.PushBack(4))
+
+	assert.Equal(t, uint64(2), rb.Size())
+}